@@ -14,11 +14,14 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/hnrobert/feishu-github-tracker/internal/actions"
+	"github.com/hnrobert/feishu-github-tracker/internal/chatops"
 	"github.com/hnrobert/feishu-github-tracker/internal/config"
 	"github.com/hnrobert/feishu-github-tracker/internal/handler"
 	"github.com/hnrobert/feishu-github-tracker/internal/logger"
 	"github.com/hnrobert/feishu-github-tracker/internal/notifier"
 	"github.com/hnrobert/feishu-github-tracker/internal/panel"
+	"github.com/hnrobert/feishu-github-tracker/internal/store"
 )
 
 func main() {
@@ -63,6 +66,17 @@ func main() {
 		logDir = filepath.Join(filepath.Dir(execPath), "logs")
 	}
 
+	// Determine data directory (runtime state such as the message thread index)
+	dataDir := os.Getenv("DATA_DIR")
+	if dataDir == "" {
+		execPath, err := os.Executable()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to get executable path: %v\n", err)
+			os.Exit(1)
+		}
+		dataDir = filepath.Join(filepath.Dir(execPath), "data")
+	}
+
 	// Initialize logger
 	if err := logger.Init(cfg.Server.Server.LogLevel, logDir); err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
@@ -72,6 +86,7 @@ func main() {
 	logger.Info("Starting GitHub to Feishu webhook forwarder")
 	logger.Info("Config directory: %s", configDir)
 	logger.Info("Log directory: %s", logDir)
+	logger.Info("Data directory: %s", dataDir)
 	logger.Info("Hot reload enabled: %v", *enableReload)
 
 	// Create notifier
@@ -79,6 +94,68 @@ func main() {
 
 	// Create handler with hot reload support
 	h := handler.New(cfg, n)
+	retention := time.Duration(cfg.Server.State.MessageRetentionDays) * 24 * time.Hour
+	threads, err := store.OpenThreads(filepath.Join(dataDir, "threads.json"), retention)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load message threads: %v\n", err)
+		os.Exit(1)
+	}
+	h.SetThreads(threads)
+	tasks, err := store.OpenTasks(filepath.Join(dataDir, "tasks.json"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load issue tasks: %v\n", err)
+		os.Exit(1)
+	}
+	h.SetTasks(tasks)
+	digests, err := store.OpenDigests(filepath.Join(dataDir, "digests.json"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load digests: %v\n", err)
+		os.Exit(1)
+	}
+	h.SetDigests(digests)
+	historyRetention := time.Duration(cfg.Server.State.HistoryRetentionDays) * 24 * time.Hour
+	history, err := store.OpenHistory(filepath.Join(dataDir, "history.jsonl"), historyRetention)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load event history: %v\n", err)
+		os.Exit(1)
+	}
+	h.SetHistory(history)
+	pulls, err := store.OpenPullRequests(filepath.Join(dataDir, "pulls.json"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load pull request state: %v\n", err)
+		os.Exit(1)
+	}
+	h.SetPullRequests(pulls)
+	ciStates, err := store.OpenCIStates(filepath.Join(dataDir, "ci.json"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load CI state: %v\n", err)
+		os.Exit(1)
+	}
+	h.SetCIStates(ciStates)
+	commitStatuses, err := store.OpenCommitStatuses(filepath.Join(dataDir, "commits.json"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load commit statuses: %v\n", err)
+		os.Exit(1)
+	}
+	h.SetCommitStatuses(commitStatuses)
+	suppressions, err := store.OpenSuppressions(filepath.Join(dataDir, "suppressions.json"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load suppressions: %v\n", err)
+		os.Exit(1)
+	}
+	h.SetSuppressions(suppressions)
+	held, err := store.OpenHeld(filepath.Join(dataDir, "held.json"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load held deliveries: %v\n", err)
+		os.Exit(1)
+	}
+	h.SetHeld(held)
+	deployments, err := store.OpenDeployments(filepath.Join(dataDir, "deployments.json"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load deployments: %v\n", err)
+		os.Exit(1)
+	}
+	h.SetDeployments(deployments)
 	if *enableReload {
 		h.EnableHotReload(configDir)
 	}
@@ -102,6 +179,17 @@ func main() {
 	// Setup HTTP server
 	mux := http.NewServeMux()
 	mux.Handle("/webhook", h)
+	cardActions := actions.New(h.Config, actions.NewAuditLog(filepath.Join(logDir, "audit.log")))
+	cardActions.SetPullRequests(pulls)
+	mux.Handle("/feishu/card", cardActions)
+	chatCommands := chatops.New(chatops.Options{
+		ConfigDir:    configDir,
+		Config:       h.Config,
+		OnSave:       h.Reload, // reload running config after a command edits repos.yaml
+		PullRequests: pulls,
+		Suppressions: suppressions,
+	})
+	mux.Handle("/feishu/event", chatCommands)
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
@@ -111,10 +199,11 @@ func main() {
 	// keeps /webhook and /health routed to their handlers above). The panel
 	// resolves admin username/password from server.yaml + env on each login.
 	panelApp, err := panel.New(panel.Options{
-		ConfigDir: configDir,
-		LogDir:    logDir,
-		JWTSecret: resolvePanelSecret(cfg),
-		OnSave:    h.Reload, // reload running config after any panel edit
+		ConfigDir:    configDir,
+		LogDir:       logDir,
+		JWTSecret:    resolvePanelSecret(cfg),
+		OnSave:       h.Reload, // reload running config after any panel edit
+		Suppressions: suppressions,
 	})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to initialize panel: %v\n", err)
@@ -134,12 +223,27 @@ func main() {
 		}
 	}()
 
+	// Send digest windows (repos.yaml digest:) as they come due
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	go h.RunDigests(jobsCtx, 30*time.Second)
+	// Send scheduled reports (server.yaml reports:)
+	go h.RunReports(jobsCtx, 30*time.Second)
+	// Send review reminders for stale pull requests (repos.yaml stale_prs:)
+	go h.RunReminders(jobsCtx, time.Minute)
+	// Send per-commit status cards once their checks finish (repos.yaml commit_status:)
+	go h.RunCommitStatuses(jobsCtx, 15*time.Second)
+	// Send deliveries queued during maintenance mode once it is lifted
+	go h.RunSuppressions(jobsCtx, 15*time.Second)
+	// Send deliveries held during quiet hours once they end (quiet_hours:)
+	go h.RunQuietHours(jobsCtx, time.Minute)
+
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
 	logger.Info("Shutting down server...")
+	stopJobs() // buffered digest events stay in DATA_DIR and are sent after restart
 
 	// Graceful shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -148,6 +252,7 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		logger.Error("Server forced to shutdown: %v", err)
 	}
+	chatCommands.Wait() // finish the chat commands already acknowledged
 
 	logger.Info("Server stopped")
 }
@@ -0,0 +1,391 @@
+// Package actions serves the Feishu card callback endpoint: buttons on
+// tracker cards (approve a deployment, re-run a workflow, close or label an
+// issue) are turned into GitHub REST API calls, review reminders can be
+// snoozed, and every attempt is written to the audit log.
+package actions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hnrobert/feishu-github-tracker/internal/config"
+	"github.com/hnrobert/feishu-github-tracker/internal/feishu"
+	"github.com/hnrobert/feishu-github-tracker/internal/github"
+	"github.com/hnrobert/feishu-github-tracker/internal/logger"
+	"github.com/hnrobert/feishu-github-tracker/internal/matcher"
+	"github.com/hnrobert/feishu-github-tracker/internal/store"
+)
+
+// Supported card actions, used as the "action" key of a button's value.
+const (
+	ApproveDeployment = "approve_deployment"
+	RejectDeployment  = "reject_deployment"
+	RerunWorkflow     = "rerun_workflow"
+	CloseIssue        = "close_issue"
+	LabelIssue        = "label_issue"
+	SnoozeReminder    = "snooze_reminder"
+)
+
+// defaultSnooze is how long snooze_reminder holds reminders without "for".
+const defaultSnooze = 24 * time.Hour
+
+// Handler serves the card callback endpoint. The configuration is read on
+// every request, so hot reloads and panel edits apply immediately.
+type Handler struct {
+	config func() *config.Config
+	audit  *AuditLog
+	pulls  *store.PullRequests // review reminder state; nil disables snooze_reminder
+	http   *http.Client        // used by the GitHub client; nil for the default
+	now    func() time.Time
+
+	mu        sync.Mutex
+	client    *github.Client
+	clientCfg config.GitHubConfig
+}
+
+// New creates a Handler reading the live configuration from cfg and
+// appending audit records to audit.
+func New(cfg func() *config.Config, audit *AuditLog) *Handler {
+	return &Handler{config: cfg, audit: audit, now: time.Now}
+}
+
+// SetPullRequests enables the snooze_reminder action on the review reminder
+// state in pulls.
+func (h *Handler) SetPullRequests(pulls *store.PullRequests) {
+	h.pulls = pulls
+}
+
+// request is a decoded button value.
+type request struct {
+	Action      string
+	Repo        string
+	RunID       int64
+	Number      int
+	Environment string
+	Labels      []string
+	Comment     string
+	Duration    time.Duration
+}
+
+// ServeHTTP handles a card callback from Feishu.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cfg := h.config()
+	if cfg == nil || !cfg.Server.CardActions.Enabled {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	app := cfg.FeishuBots.FeishuApp
+	if app.VerificationToken == "" {
+		logger.Error("Card callback rejected: feishu_app.verification_token is not configured")
+		http.Error(w, "Card actions are not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	cb, err := feishu.ReadCallback(r, app.VerificationToken, app.EncryptKey, h.now())
+	if err != nil {
+		logger.Warn("Card callback rejected: %v", err)
+		status := http.StatusBadRequest
+		if errors.Is(err, feishu.ErrInvalidSignature) || errors.Is(err, feishu.ErrInvalidToken) {
+			status = http.StatusUnauthorized
+		}
+		http.Error(w, "Invalid callback", status)
+		return
+	}
+
+	if cb.Type == "url_verification" {
+		writeJSON(w, map[string]string{"challenge": cb.Challenge})
+		return
+	}
+
+	action, err := cb.CardAction()
+	if err != nil {
+		logger.Warn("Card callback rejected: %v", err)
+		http.Error(w, "Invalid card action", http.StatusBadRequest)
+		return
+	}
+
+	message, err := h.handle(cfg, action)
+	if !cb.Schema2 {
+		// Legacy card callbacks take a replacement card (or nothing) as
+		// the response; the result is only in the audit log.
+		writeJSON(w, map[string]any{})
+		return
+	}
+	toast := map[string]string{"type": "success", "content": message}
+	if err != nil {
+		toast = map[string]string{"type": "error", "content": err.Error()}
+	}
+	writeJSON(w, map[string]any{"toast": toast})
+}
+
+// handle authorizes and performs one card action, recording it in the
+// audit log. It returns a short result message for the operator.
+func (h *Handler) handle(cfg *config.Config, action *feishu.CardAction) (string, error) {
+	entry := AuditEntry{
+		Time:      h.now(),
+		OpenID:    action.OpenID,
+		UserID:    action.UserID,
+		MessageID: action.MessageID,
+		ChatID:    action.ChatID,
+	}
+	if user, ok := cfg.LookupFeishuUser(action.OpenID, action.UserID); ok {
+		entry.GitHubLogin = user.GitHub
+	}
+
+	req, err := parseRequest(action.Value)
+	entry.Action, entry.Repo, entry.Target = req.Action, req.Repo, req.target()
+	if err == nil {
+		err = h.authorize(cfg, req, action)
+		if err != nil {
+			entry.Result = "denied"
+		}
+	}
+	var message string
+	if err == nil {
+		message, err = h.perform(cfg, req, entry.GitHubLogin)
+	}
+	if entry.Result == "" {
+		entry.Result = "ok"
+		if err != nil {
+			entry.Result = "error"
+		}
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	h.audit.Record(entry)
+	return message, err
+}
+
+// authorize checks the action is enabled, the repository is tracked, and the
+// operator is allowed to act. Deployment reviews are denied to everyone
+// unless allowed_operators is set: anyone in the chat could press the button.
+func (h *Handler) authorize(cfg *config.Config, req request, action *feishu.CardAction) error {
+	actionsCfg := cfg.Server.CardActions
+	if len(actionsCfg.Actions) > 0 && !contains(actionsCfg.Actions, req.Action) {
+		return fmt.Errorf("action %s is not enabled", req.Action)
+	}
+	if len(actionsCfg.AllowedOperators) == 0 && (req.Action == ApproveDeployment || req.Action == RejectDeployment) {
+		return fmt.Errorf("action %s requires card_actions.allowed_operators", req.Action)
+	}
+	rule, err := matcher.MatchRepo(req.Repo, cfg.Repos.Repos)
+	if err != nil {
+		return err
+	}
+	if rule == nil {
+		return fmt.Errorf("repository %s is not tracked", req.Repo)
+	}
+	if !cfg.OperatorAllowed(actionsCfg.AllowedOperators, action.OpenID, action.UserID) {
+		return fmt.Errorf("you are not allowed to perform card actions")
+	}
+	return nil
+}
+
+// perform calls the GitHub API for req.
+func (h *Handler) perform(cfg *config.Config, req request, login string) (string, error) {
+	if req.Action == SnoozeReminder {
+		return h.snooze(req)
+	}
+	client, err := h.githubClient(cfg.Server.GitHub)
+	if err != nil {
+		return "", err
+	}
+	switch req.Action {
+	case ApproveDeployment, RejectDeployment:
+		state, verb := "approved", "Approved"
+		if req.Action == RejectDeployment {
+			state, verb = "rejected", "Rejected"
+		}
+		pending, err := client.PendingDeployments(req.Repo, req.RunID)
+		if err != nil {
+			return "", err
+		}
+		var ids []int64
+		for _, p := range pending {
+			if req.Environment == "" || strings.EqualFold(p.Environment.Name, req.Environment) {
+				ids = append(ids, p.Environment.ID)
+			}
+		}
+		if len(ids) == 0 {
+			return "", fmt.Errorf("no pending deployments for run %d", req.RunID)
+		}
+		comment := req.Comment
+		if comment == "" {
+			comment = verb + " from Feishu"
+			if login != "" {
+				comment += " by @" + login
+			}
+		}
+		if err := client.ReviewPendingDeployments(req.Repo, req.RunID, ids, state, comment); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s deployment for run %d", verb, req.RunID), nil
+	case RerunWorkflow:
+		if err := client.RerunFailedJobs(req.Repo, req.RunID); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Re-running failed jobs of run %d", req.RunID), nil
+	case CloseIssue:
+		if err := client.CloseIssue(req.Repo, req.Number); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Closed #%d", req.Number), nil
+	case LabelIssue:
+		if err := client.AddLabels(req.Repo, req.Number, req.Labels); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Labeled #%d: %s", req.Number, strings.Join(req.Labels, ", ")), nil
+	}
+	return "", fmt.Errorf("unknown action %q", req.Action)
+}
+
+// snooze holds the review reminders of a pull request for req.Duration.
+func (h *Handler) snooze(req request) (string, error) {
+	if h.pulls == nil {
+		return "", fmt.Errorf("review reminders are not enabled")
+	}
+	key := fmt.Sprintf("%s#%d", req.Repo, req.Number)
+	until := h.now().Add(req.Duration).Truncate(time.Second)
+	ok, err := h.pulls.Snooze(key, until)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("%s is not tracked for review reminders", key)
+	}
+	return fmt.Sprintf("Snoozed reminders for %s until %s", key, until.Local().Format("2006-01-02 15:04")), nil
+}
+
+// githubClient returns a client for cfg, reusing the previous one (and its
+// cached installation tokens) while the GitHub configuration is unchanged.
+// GITHUB_TOKEN in the environment takes precedence over github.token.
+func (h *Handler) githubClient(cfg config.GitHubConfig) (*github.Client, error) {
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		cfg.Token = token
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.client != nil && reflect.DeepEqual(h.clientCfg, cfg) {
+		return h.client, nil
+	}
+	client, err := github.NewClient(cfg, h.http)
+	if err != nil {
+		return nil, err
+	}
+	h.client, h.clientCfg = client, cfg
+	return client, nil
+}
+
+// parseRequest decodes and validates a button value. Numbers may be JSON
+// numbers or strings, since templates render placeholders as strings.
+func parseRequest(value map[string]any) (request, error) {
+	req := request{
+		Action:      stringValue(value["action"]),
+		Repo:        stringValue(value["repo"]),
+		Environment: stringValue(value["environment"]),
+		Comment:     stringValue(value["comment"]),
+	}
+	if req.Action == "" || req.Repo == "" {
+		return req, fmt.Errorf("card action requires action and repo")
+	}
+	var err error
+	switch req.Action {
+	case ApproveDeployment, RejectDeployment, RerunWorkflow:
+		req.RunID, err = strconv.ParseInt(stringValue(value["run_id"]), 10, 64)
+		if err != nil || req.RunID <= 0 {
+			return req, fmt.Errorf("%s requires a numeric run_id", req.Action)
+		}
+	case CloseIssue, LabelIssue, SnoozeReminder:
+		req.Number, err = strconv.Atoi(stringValue(value["number"]))
+		if err != nil || req.Number <= 0 {
+			return req, fmt.Errorf("%s requires a numeric number", req.Action)
+		}
+		if req.Action == LabelIssue {
+			req.Labels = listValue(value["labels"])
+			if len(req.Labels) == 0 {
+				return req, fmt.Errorf("%s requires labels", req.Action)
+			}
+		}
+		if req.Action == SnoozeReminder {
+			req.Duration = defaultSnooze
+			if d := stringValue(value["for"]); d != "" {
+				req.Duration, err = config.ParseDuration(d)
+				if err != nil || req.Duration <= 0 {
+					return req, fmt.Errorf("%s has an invalid duration %q", req.Action, d)
+				}
+			}
+		}
+	default:
+		return req, fmt.Errorf("unknown action %q", req.Action)
+	}
+	return req, nil
+}
+
+// target describes what the action applies to, for the audit log.
+func (r request) target() string {
+	switch {
+	case r.RunID > 0:
+		return fmt.Sprintf("run %d", r.RunID)
+	case r.Number > 0:
+		return fmt.Sprintf("#%d", r.Number)
+	}
+	return ""
+}
+
+func stringValue(v any) string {
+	switch t := v.(type) {
+	case string:
+		return strings.TrimSpace(t)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// listValue accepts a JSON array or a comma-separated string.
+func listValue(v any) []string {
+	var items []string
+	switch t := v.(type) {
+	case []any:
+		for _, item := range t {
+			items = append(items, stringValue(item))
+		}
+	case string:
+		items = strings.Split(t, ",")
+	}
+	var out []string
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,232 @@
+package actions
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hnrobert/feishu-github-tracker/internal/config"
+	"github.com/hnrobert/feishu-github-tracker/internal/logger"
+	"github.com/hnrobert/feishu-github-tracker/internal/store"
+)
+
+// mockGitHub records REST calls made by the card actions.
+type mockGitHub struct {
+	mu    sync.Mutex
+	calls []string
+	body  map[string]any
+}
+
+func (m *mockGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if r.Header.Get("Authorization") != "token ghs_test" {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"message":"Bad credentials"}`))
+		return
+	}
+	m.calls = append(m.calls, r.Method+" "+r.URL.Path)
+	data, _ := io.ReadAll(r.Body)
+	m.body = nil
+	_ = json.Unmarshal(data, &m.body)
+	if r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/pending_deployments") {
+		_, _ = w.Write([]byte(`[{"environment":{"id":11,"name":"staging"}},{"environment":{"id":12,"name":"production"}}]`))
+		return
+	}
+	_, _ = w.Write([]byte(`{}`))
+}
+
+func newTestHandler(t *testing.T, gh *mockGitHub) (*Handler, string) {
+	t.Helper()
+	_ = logger.Init("debug", t.TempDir())
+	t.Setenv("GITHUB_TOKEN", "")
+	srv := httptest.NewServer(gh)
+	t.Cleanup(srv.Close)
+
+	cfg := &config.Config{}
+	cfg.Server.CardActions = config.CardActionsConfig{Enabled: true, AllowedOperators: []string{"alice"}}
+	cfg.Server.GitHub = config.GitHubConfig{APIURL: srv.URL, Token: "ghs_test"}
+	cfg.Repos.Repos = []config.RepoPattern{{Pattern: "org/*"}}
+	cfg.FeishuBots.FeishuApp = config.FeishuAppConfig{VerificationToken: "vtoken"}
+	cfg.Users.Users = []config.UserMapping{{GitHub: "alice", OpenID: "ou_alice"}}
+
+	auditPath := filepath.Join(t.TempDir(), "audit.log")
+	h := New(func() *config.Config { return cfg }, NewAuditLog(auditPath))
+	h.http = srv.Client()
+	return h, auditPath
+}
+
+func cardEvent(openID string, value map[string]any) []byte {
+	body, _ := json.Marshal(map[string]any{
+		"schema": "2.0",
+		"header": map[string]any{"event_type": "card.action.trigger", "token": "vtoken"},
+		"event": map[string]any{
+			"operator": map[string]any{"open_id": openID},
+			"action":   map[string]any{"tag": "button", "value": value},
+			"context":  map[string]any{"open_message_id": "om_1", "open_chat_id": "oc_1"},
+		},
+	})
+	return body
+}
+
+func post(h http.Handler, body []byte, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/feishu/card", strings.NewReader(string(body)))
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestURLVerificationAndToken(t *testing.T) {
+	h, _ := newTestHandler(t, &mockGitHub{})
+
+	rec := post(h, []byte(`{"type":"url_verification","challenge":"c-1","token":"vtoken"}`), nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"challenge":"c-1"`) {
+		t.Fatalf("url_verification: %d %s", rec.Code, rec.Body.String())
+	}
+	rec = post(h, []byte(`{"type":"url_verification","challenge":"c-1","token":"wrong"}`), nil)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong token: got %d, want 401", rec.Code)
+	}
+}
+
+func TestApproveDeployment(t *testing.T) {
+	gh := &mockGitHub{}
+	h, auditPath := newTestHandler(t, gh)
+
+	rec := post(h, cardEvent("ou_alice", map[string]any{
+		"action": "approve_deployment", "repo": "org/app", "run_id": "4242", "environment": "production",
+	}), nil)
+	if !strings.Contains(rec.Body.String(), `"type":"success"`) {
+		t.Fatalf("unexpected response: %s", rec.Body.String())
+	}
+	want := []string{"GET /repos/org/app/actions/runs/4242/pending_deployments", "POST /repos/org/app/actions/runs/4242/pending_deployments"}
+	if strings.Join(gh.calls, ",") != strings.Join(want, ",") {
+		t.Fatalf("calls = %v", gh.calls)
+	}
+	if ids, _ := gh.body["environment_ids"].([]any); len(ids) != 1 || ids[0] != float64(12) || gh.body["state"] != "approved" {
+		t.Fatalf("unexpected review body: %v", gh.body)
+	}
+
+	data, err := os.ReadFile(auditPath)
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	var entry AuditEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("decode audit entry: %v", err)
+	}
+	if entry.Action != ApproveDeployment || entry.GitHubLogin != "alice" || entry.Result != "ok" || entry.Target != "run 4242" {
+		t.Fatalf("unexpected audit entry: %+v", entry)
+	}
+
+	// Without allowed_operators nobody may review deployments.
+	cfg := h.config()
+	cfg.Server.CardActions.AllowedOperators = nil
+	gh.calls = nil
+	rec = post(h, cardEvent("ou_alice", map[string]any{
+		"action": "reject_deployment", "repo": "org/app", "run_id": "4242",
+	}), nil)
+	if !strings.Contains(rec.Body.String(), `"type":"error"`) || len(gh.calls) != 0 {
+		t.Fatalf("deployment review without allowed_operators: %s, calls %v", rec.Body.String(), gh.calls)
+	}
+}
+
+func TestSnoozeReminder(t *testing.T) {
+	gh := &mockGitHub{}
+	h, _ := newTestHandler(t, gh)
+	now := time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)
+	h.now = func() time.Time { return now }
+	value := map[string]any{"action": "snooze_reminder", "repo": "org/app", "number": "7", "for": "2d"}
+
+	// Without review reminders the action fails.
+	if rec := post(h, cardEvent("ou_alice", value), nil); !strings.Contains(rec.Body.String(), `"type":"error"`) {
+		t.Fatalf("unexpected response: %s", rec.Body.String())
+	}
+
+	pulls, err := store.OpenPullRequests(filepath.Join(t.TempDir(), "pulls.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pulls.Update("org/app#7", func(pr *store.PullRequest, _ bool) { pr.Repo, pr.Number = "org/app", 7 }); err != nil {
+		t.Fatal(err)
+	}
+	h.SetPullRequests(pulls)
+	if rec := post(h, cardEvent("ou_alice", value), nil); !strings.Contains(rec.Body.String(), `"type":"success"`) {
+		t.Fatalf("unexpected response: %s", rec.Body.String())
+	}
+	if pr, _ := pulls.Get("org/app#7"); !pr.SnoozedUntil.Equal(now.Add(48 * time.Hour)) {
+		t.Fatalf("snoozed until %v", pr.SnoozedUntil)
+	}
+	if len(gh.calls) != 0 {
+		t.Fatalf("snooze called GitHub: %v", gh.calls)
+	}
+}
+
+func TestDeniedActionsAreAudited(t *testing.T) {
+	gh := &mockGitHub{}
+	h, auditPath := newTestHandler(t, gh)
+
+	cases := []struct {
+		openID string
+		value  map[string]any
+	}{
+		{"ou_mallory", map[string]any{"action": "close_issue", "repo": "org/app", "number": 3}},
+		{"ou_alice", map[string]any{"action": "close_issue", "repo": "other/app", "number": 3}},
+	}
+	for _, c := range cases {
+		rec := post(h, cardEvent(c.openID, c.value), nil)
+		if !strings.Contains(rec.Body.String(), `"type":"error"`) {
+			t.Fatalf("expected error toast, got %s", rec.Body.String())
+		}
+	}
+	if len(gh.calls) != 0 {
+		t.Fatalf("denied actions reached GitHub: %v", gh.calls)
+	}
+	data, _ := os.ReadFile(auditPath)
+	if n := strings.Count(string(data), `"result":"denied"`); n != 2 {
+		t.Fatalf("expected 2 denied audit entries, got %d:\n%s", n, data)
+	}
+}
+
+func TestLegacyCallbackWithSignature(t *testing.T) {
+	gh := &mockGitHub{}
+	h, _ := newTestHandler(t, gh)
+
+	body := []byte(`{"open_id":"ou_alice","open_message_id":"om_1","token":"vtoken",` +
+		`"action":{"tag":"button","value":{"action":"label_issue","repo":"org/app","number":"7","labels":"bug, triage"}}}`)
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	sum := sha1.Sum([]byte(ts + "nonce" + "vtoken" + string(body)))
+	header := http.Header{}
+	header.Set("X-Lark-Request-Timestamp", ts)
+	header.Set("X-Lark-Request-Nonce", "nonce")
+	header.Set("X-Lark-Signature", hex.EncodeToString(sum[:]))
+
+	rec := post(h, body, header)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("legacy callback: %d %s", rec.Code, rec.Body.String())
+	}
+	if len(gh.calls) != 1 || gh.calls[0] != "POST /repos/org/app/issues/7/labels" {
+		t.Fatalf("calls = %v", gh.calls)
+	}
+	if labels, _ := gh.body["labels"].([]any); len(labels) != 2 || labels[1] != "triage" {
+		t.Fatalf("unexpected labels body: %v", gh.body)
+	}
+
+	header.Set("X-Lark-Signature", "bad")
+	if rec := post(h, body, header); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("bad signature: got %d, want 401", rec.Code)
+	}
+}
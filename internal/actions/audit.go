@@ -0,0 +1,75 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hnrobert/feishu-github-tracker/internal/logger"
+)
+
+// AuditEntry records one card action attempt.
+type AuditEntry struct {
+	Time        time.Time `json:"time"`
+	Action      string    `json:"action"`
+	Repo        string    `json:"repo"`
+	Target      string    `json:"target,omitempty"` // "run 123" or "#45"
+	OpenID      string    `json:"open_id,omitempty"`
+	UserID      string    `json:"user_id,omitempty"`
+	GitHubLogin string    `json:"github_login,omitempty"` // operator, via users.yaml
+	MessageID   string    `json:"message_id,omitempty"`
+	ChatID      string    `json:"chat_id,omitempty"`
+	Result      string    `json:"result"` // ok, denied or error
+	Error       string    `json:"error,omitempty"`
+}
+
+// AuditLog appends entries as JSON lines to a file (audit.log in LOG_DIR)
+// and mirrors them to the application log.
+type AuditLog struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewAuditLog creates an audit log writing to path.
+func NewAuditLog(path string) *AuditLog {
+	return &AuditLog{path: path}
+}
+
+// Record appends e to the audit log. Failures to write are logged, never
+// returned: an action that already ran must still be reported.
+func (a *AuditLog) Record(e AuditEntry) {
+	operator := e.GitHubLogin
+	if operator == "" {
+		operator = e.OpenID + e.UserID
+	}
+	msg := fmt.Sprintf("Card action %s on %s %s by %s: %s", e.Action, e.Repo, e.Target, operator, e.Result)
+	if e.Error != "" {
+		logger.Warn("%s (%s)", msg, e.Error)
+	} else {
+		logger.Info("%s", msg)
+	}
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		logger.Error("Failed to encode audit entry: %v", err)
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(a.path), 0o755); err != nil {
+		logger.Error("Failed to write audit log: %v", err)
+		return
+	}
+	f, err := os.OpenFile(a.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		logger.Error("Failed to write audit log: %v", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		logger.Error("Failed to write audit log: %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+// Package chatops serves the Feishu event subscription endpoint and lets
+// chats talk to the tracker: "@tracker subscribe org/repo push,release",
+// "@tracker mute org/repo 2h", "@tracker status". Commands that change the
+// configuration edit repos.yaml with the panel's atomic writer.
+package chatops
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/hnrobert/feishu-github-tracker/internal/config"
+	"github.com/hnrobert/feishu-github-tracker/internal/feishu"
+	"github.com/hnrobert/feishu-github-tracker/internal/logger"
+	"github.com/hnrobert/feishu-github-tracker/internal/store"
+)
+
+// seenEventTTL is how long event IDs are remembered to drop Feishu's retries.
+const seenEventTTL = time.Hour
+
+// Options configures a Handler.
+type Options struct {
+	ConfigDir string
+	// Config returns the configuration currently in use.
+	Config func() *config.Config
+	// OnSave, if set, is called after repos.yaml was changed by a command
+	// (to hot-reload the running configuration).
+	OnSave func()
+	// HTTPClient is used for Open API replies; nil uses a 15s-timeout client.
+	HTTPClient *http.Client
+	// PullRequests is the review reminder state the snooze command edits;
+	// nil disables it.
+	PullRequests *store.PullRequests
+	// Suppressions lets mute silence a chat's targets of rules it shares
+	// with other targets; nil limits mute to the chat's own rules.
+	Suppressions *store.Suppressions
+}
+
+// Handler serves /feishu/event.
+type Handler struct {
+	configDir    string
+	config       func() *config.Config
+	onSave       func()
+	http         *http.Client
+	pulls        *store.PullRequests
+	suppressions *store.Suppressions
+	now          func() time.Time
+	running      sync.WaitGroup // commands acknowledged but still running
+
+	mu        sync.Mutex // guards the fields below and serializes repos.yaml edits
+	seen      map[string]time.Time
+	client    *feishu.Client
+	clientCfg config.FeishuAppConfig
+}
+
+// New creates a Handler.
+func New(opts Options) *Handler {
+	return &Handler{
+		configDir:    opts.ConfigDir,
+		config:       opts.Config,
+		onSave:       opts.OnSave,
+		http:         opts.HTTPClient,
+		pulls:        opts.PullRequests,
+		suppressions: opts.Suppressions,
+		now:          time.Now,
+		seen:         map[string]time.Time{},
+	}
+}
+
+// ServeHTTP handles an event callback from Feishu.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cfg := h.config()
+	if cfg == nil || !cfg.Server.ChatCommands.Enabled {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	app := cfg.FeishuBots.FeishuApp
+	if app.VerificationToken == "" {
+		logger.Error("Feishu event rejected: feishu_app.verification_token is not configured")
+		http.Error(w, "Chat commands are not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	cb, err := feishu.ReadCallback(r, app.VerificationToken, app.EncryptKey, h.now())
+	if err != nil {
+		logger.Warn("Feishu event rejected: %v", err)
+		status := http.StatusBadRequest
+		if errors.Is(err, feishu.ErrInvalidSignature) || errors.Is(err, feishu.ErrInvalidToken) {
+			status = http.StatusUnauthorized
+		}
+		http.Error(w, "Invalid callback", status)
+		return
+	}
+	if cb.Type == "url_verification" {
+		writeJSON(w, map[string]string{"challenge": cb.Challenge})
+		return
+	}
+
+	// Acknowledge everything else right away and run commands in the
+	// background: Feishu retries events not answered within 3 seconds, and
+	// the reply goes through the Open API anyway.
+	writeJSON(w, map[string]any{})
+	if cb.EventType != "im.message.receive_v1" {
+		logger.Debug("Ignoring Feishu event %s", cb.EventType)
+		return
+	}
+	if h.duplicate(cb.EventID) {
+		logger.Debug("Ignoring duplicate Feishu event %s", cb.EventID)
+		return
+	}
+	msg, err := cb.MessageEvent()
+	if err != nil {
+		logger.Warn("Invalid Feishu message event: %v", err)
+		return
+	}
+	if msg.SenderType != "user" || msg.MessageType != "text" || msg.Text == "" {
+		return
+	}
+
+	h.running.Add(1)
+	go func() {
+		defer h.running.Done()
+		reply := h.run(cfg, msg)
+		if err := h.reply(app, msg.MessageID, reply); err != nil {
+			logger.Error("Failed to reply to Feishu message %s: %v", msg.MessageID, err)
+		}
+	}()
+}
+
+// Wait blocks until the commands running in the background are done.
+func (h *Handler) Wait() {
+	h.running.Wait()
+}
+
+// duplicate reports whether eventID was already handled, remembering it.
+func (h *Handler) duplicate(eventID string) bool {
+	if eventID == "" {
+		return false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := h.now()
+	for id, at := range h.seen {
+		if now.Sub(at) > seenEventTTL {
+			delete(h.seen, id)
+		}
+	}
+	if _, ok := h.seen[eventID]; ok {
+		return true
+	}
+	h.seen[eventID] = now
+	return false
+}
+
+// reply answers a command message with a text reply.
+func (h *Handler) reply(app config.FeishuAppConfig, messageID, text string) error {
+	h.mu.Lock()
+	if h.client == nil || !reflect.DeepEqual(h.clientCfg, app) {
+		h.client = feishu.NewClient(app.AppID, app.AppSecret, app.BaseURL, h.http)
+		h.clientCfg = app
+	}
+	client := h.client
+	h.mu.Unlock()
+
+	content, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	_, err = client.ReplyMessage(messageID, "text", string(content), false)
+	return err
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,298 @@
+package chatops
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hnrobert/feishu-github-tracker/internal/config"
+	"github.com/hnrobert/feishu-github-tracker/internal/logger"
+	"github.com/hnrobert/feishu-github-tracker/internal/store"
+)
+
+// mockOpenAPI records the text replies sent by the command handler.
+type mockOpenAPI struct {
+	mu      sync.Mutex
+	replies []string
+}
+
+func (m *mockOpenAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/tenant_access_token/internal") {
+		_, _ = w.Write([]byte(`{"code":0,"tenant_access_token":"t","expire":7200}`))
+		return
+	}
+	var body map[string]any
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	var content map[string]string
+	_ = json.Unmarshal([]byte(body["content"].(string)), &content)
+	m.mu.Lock()
+	m.replies = append(m.replies, content["text"])
+	m.mu.Unlock()
+	_, _ = w.Write([]byte(`{"code":0,"data":{"message_id":"om_reply"}}`))
+}
+
+func (m *mockOpenAPI) last() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.replies) == 0 {
+		return ""
+	}
+	return m.replies[len(m.replies)-1]
+}
+
+func setup(t *testing.T, repos string, matchAll bool) (*Handler, *mockOpenAPI, string) {
+	t.Helper()
+	_ = logger.Init("debug", t.TempDir())
+	mock := &mockOpenAPI{}
+	srv := httptest.NewServer(mock)
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	server := "server:\n  port: 4594\n"
+	if matchAll {
+		server += "  match_all_rules: true\n"
+	}
+	server += "chat_commands:\n  enabled: true\n  allowed_operators: [ou_admin]\n"
+	files := map[string]string{
+		"server.yaml":      server,
+		"repos.yaml":       repos,
+		"events.yaml":      "events:\n  push:\n  release:\n",
+		"feishu-bots.yaml": "feishu_app:\n  app_id: cli_x\n  app_secret: s\n  verification_token: vt\n  encrypt_key: ek\n  base_url: " + srv.URL + "\nfeishu_bots: []\n",
+		"templates.jsonc":  `{"templates": {}}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := New(Options{
+		ConfigDir: dir,
+		Config: func() *config.Config {
+			cfg, err := config.Load(dir)
+			if err != nil {
+				t.Fatalf("config.Load() error = %v", err)
+			}
+			return cfg
+		},
+		HTTPClient: srv.Client(),
+	})
+	return h, mock, dir
+}
+
+// encrypt mirrors Feishu's event encryption.
+func encrypt(t *testing.T, plain, key string) string {
+	t.Helper()
+	k := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(k[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pad := aes.BlockSize - len(plain)%aes.BlockSize
+	data := append([]byte(plain), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	iv := []byte("fedcba9876543210")
+	out := make([]byte, len(data))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, data)
+	return base64.StdEncoding.EncodeToString(append(iv, out...))
+}
+
+func send(t *testing.T, h *Handler, plain string) *httptest.ResponseRecorder {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"encrypt": encrypt(t, plain, "ek")})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/feishu/event", bytes.NewReader(body)))
+	h.Wait()
+	return rec
+}
+
+var eventSeq int
+
+func message(openID, text string) string {
+	eventSeq++
+	content, _ := json.Marshal(map[string]string{"text": "@_user_1 " + text})
+	ev, _ := json.Marshal(map[string]any{
+		"schema": "2.0",
+		"header": map[string]any{"event_id": fmt.Sprintf("ev-%d", eventSeq), "event_type": "im.message.receive_v1", "token": "vt"},
+		"event": map[string]any{
+			"sender": map[string]any{"sender_id": map[string]any{"open_id": openID}, "sender_type": "user"},
+			"message": map[string]any{
+				"message_id": "om_cmd", "chat_id": "oc_1", "chat_type": "group", "message_type": "text",
+				"content": string(content), "mentions": []any{map[string]any{"key": "@_user_1"}},
+			},
+		},
+	})
+	return string(ev)
+}
+
+func TestURLVerificationEncrypted(t *testing.T) {
+	h, _, _ := setup(t, "repos: []\n", false)
+	rec := send(t, h, `{"type":"url_verification","challenge":"c-9","token":"vt"}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"challenge":"c-9"`) {
+		t.Fatalf("url_verification: %d %s", rec.Code, rec.Body.String())
+	}
+	rec = send(t, h, `{"type":"url_verification","challenge":"c-9","token":"other"}`)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong token: got %d, want 401", rec.Code)
+	}
+}
+
+func TestSubscribeMuteAndStatus(t *testing.T) {
+	h, mock, dir := setup(t, "repos: []\n", false)
+
+	send(t, h, message("ou_admin", "subscribe org/app push,release"))
+	if !strings.Contains(mock.last(), "Subscribed this chat to org/app: push, release") {
+		t.Fatalf("unexpected reply: %q", mock.last())
+	}
+	cfg, err := config.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Repos.Repos) != 1 || cfg.Repos.Repos[0].NotifyTo[0] != "chat:oc_1" || len(cfg.Repos.Repos[0].Events) != 2 {
+		t.Fatalf("unexpected repos.yaml: %+v", cfg.Repos.Repos)
+	}
+
+	send(t, h, message("ou_admin", "mute org/app 2h"))
+	cfg, _ = config.Load(dir)
+	if !cfg.Repos.Repos[0].Muted(time.Now().Add(time.Hour)) || cfg.Repos.Repos[0].Muted(time.Now().Add(3*time.Hour)) {
+		t.Fatalf("unexpected muted_until: %v", cfg.Repos.Repos[0].MutedUntil)
+	}
+
+	send(t, h, message("ou_someone", "status"))
+	if !strings.Contains(mock.last(), "- org/app: push, release (muted until") {
+		t.Fatalf("unexpected status reply: %q", mock.last())
+	}
+
+	send(t, h, message("ou_someone", "unsubscribe org/app"))
+	if !strings.Contains(mock.last(), "not allowed") {
+		t.Fatalf("expected denial, got %q", mock.last())
+	}
+	send(t, h, message("ou_admin", "unsubscribe org/app"))
+	cfg, _ = config.Load(dir)
+	if len(cfg.Repos.Repos) != 0 {
+		t.Fatalf("expected subscription removed, got %+v", cfg.Repos.Repos)
+	}
+}
+
+func TestWriteCommandsNeedAllowedOperators(t *testing.T) {
+	h, mock, dir := setup(t, "repos: []\n", false)
+	server := "server:\n  port: 4594\nchat_commands:\n  enabled: true\n"
+	if err := os.WriteFile(filepath.Join(dir, "server.yaml"), []byte(server), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	send(t, h, message("ou_admin", "subscribe org/private push"))
+	if !strings.Contains(mock.last(), "not allowed") {
+		t.Fatalf("expected denial without allowed_operators, got %q", mock.last())
+	}
+	send(t, h, message("ou_admin", "status"))
+	if !strings.Contains(mock.last(), "no subscriptions") {
+		t.Fatalf("unexpected status reply: %q", mock.last())
+	}
+}
+
+func TestMuteSharedRule(t *testing.T) {
+	repos := "repos:\n  - pattern: \"org/*\"\n    events:\n      push:\n    notify_to: [dev, \"chat:oc_1\"]\n"
+	h, mock, dir := setup(t, repos, false)
+	send(t, h, message("ou_admin", "mute org/app 2h"))
+	if !strings.Contains(mock.last(), "runtime suppressions are not enabled") {
+		t.Fatalf("unexpected reply: %q", mock.last())
+	}
+
+	suppressions, err := store.OpenSuppressions(filepath.Join(t.TempDir(), "suppressions.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.suppressions = suppressions
+	send(t, h, message("ou_admin", "mute org/app 2h"))
+	if !strings.Contains(mock.last(), "Muted org/app in this chat until") {
+		t.Fatalf("unexpected reply: %q", mock.last())
+	}
+	active := suppressions.Active(time.Now())
+	if len(active) != 1 || active[0].Repo != "org/app" || active[0].Target != "chat:oc_1" {
+		t.Fatalf("suppressions = %+v", active)
+	}
+	if cfg, _ := config.Load(dir); cfg.Repos.Repos[0].Muted(time.Now()) {
+		t.Fatal("muting one chat muted the shared rule")
+	}
+	send(t, h, message("ou_someone", "status"))
+	if !strings.Contains(mock.last(), "- org/app: muted in this chat until") {
+		t.Fatalf("unexpected status reply: %q", mock.last())
+	}
+
+	send(t, h, message("ou_admin", "unmute org/app"))
+	if mock.last() != "Unmuted org/app" || len(suppressions.Active(time.Now())) != 0 {
+		t.Fatalf("unmute: reply %q, suppressions %+v", mock.last(), suppressions.Active(time.Now()))
+	}
+	send(t, h, message("ou_admin", "mute other/app 2h"))
+	if !strings.Contains(mock.last(), "no subscription for other/app") {
+		t.Fatalf("unexpected reply: %q", mock.last())
+	}
+}
+
+func TestSubscribeRespectsExistingRules(t *testing.T) {
+	repos := "repos:\n  - pattern: \"org/*\"\n    events:\n      push:\n    notify_to: [dev]\n"
+	h, mock, _ := setup(t, repos, false)
+	send(t, h, message("ou_admin", "subscribe org/app push"))
+	if !strings.Contains(mock.last(), `already handled by rule "org/*"`) {
+		t.Fatalf("unexpected reply: %q", mock.last())
+	}
+
+	h, mock, dir := setup(t, repos, true)
+	send(t, h, message("ou_admin", "subscribe org/app push"))
+	cfg, _ := config.Load(dir)
+	if len(cfg.Repos.Repos) != 2 {
+		t.Fatalf("expected a second rule with match_all_rules, got %+v (reply %q)", cfg.Repos.Repos, mock.last())
+	}
+	send(t, h, message("ou_admin", "subscribe org/app deploy"))
+	if !strings.Contains(mock.last(), `unknown event "deploy"`) {
+		t.Fatalf("unexpected reply: %q", mock.last())
+	}
+}
+
+func TestSnoozeReminders(t *testing.T) {
+	h, mock, _ := setup(t, "repos:\n  - pattern: \"org/*\"\n    notify_to: [dev]\n", false)
+	send(t, h, message("ou_someone", "snooze org/app#7 1d"))
+	if !strings.Contains(mock.last(), "review reminders are not enabled") {
+		t.Fatalf("unexpected reply: %q", mock.last())
+	}
+
+	pulls, err := store.OpenPullRequests(filepath.Join(t.TempDir(), "pulls.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pulls.Update("org/app#7", func(pr *store.PullRequest, _ bool) {}); err != nil {
+		t.Fatal(err)
+	}
+	h.pulls = pulls
+	send(t, h, message("ou_someone", "snooze org/app#8 1d"))
+	if !strings.Contains(mock.last(), "org/app#8 is not tracked") {
+		t.Fatalf("unexpected reply: %q", mock.last())
+	}
+	send(t, h, message("ou_someone", "snooze org/app#7 1d"))
+	if !strings.Contains(mock.last(), "Snoozed review reminders for org/app#7") {
+		t.Fatalf("unexpected reply: %q", mock.last())
+	}
+	if pr, _ := pulls.Get("org/app#7"); pr.SnoozedUntil.Before(time.Now().Add(23 * time.Hour)) {
+		t.Fatalf("snoozed until %v", pr.SnoozedUntil)
+	}
+}
+
+func TestDuplicateEventsIgnored(t *testing.T) {
+	h, mock, _ := setup(t, "repos: []\n", false)
+	ev := message("ou_admin", "help")
+	send(t, h, ev)
+	send(t, h, ev)
+	if len(mock.replies) != 1 || !strings.Contains(mock.replies[0], "subscribe <owner/repo>") {
+		t.Fatalf("replies = %q", mock.replies)
+	}
+}
@@ -0,0 +1,420 @@
+package chatops
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hnrobert/feishu-github-tracker/internal/config"
+	"github.com/hnrobert/feishu-github-tracker/internal/feishu"
+	"github.com/hnrobert/feishu-github-tracker/internal/logger"
+	"github.com/hnrobert/feishu-github-tracker/internal/matcher"
+	"github.com/hnrobert/feishu-github-tracker/internal/panel"
+	"github.com/hnrobert/feishu-github-tracker/internal/store"
+)
+
+// maxMute caps "mute" durations so a typo cannot silence a repo for years.
+const maxMute = 30 * 24 * time.Hour
+
+// command is a parsed chat command.
+type command struct {
+	name   string
+	args   []string
+	chatID string
+	msg    *feishu.MessageEvent
+}
+
+// target is the notify_to entry that delivers to the command's chat.
+func (c command) target() string {
+	return "chat:" + c.chatID
+}
+
+type commandSpec struct {
+	usage  string
+	writes bool // changes repos.yaml: subject to chat_commands.allowed_operators
+	run    func(h *Handler, cfg *config.Config, c command) (string, error)
+}
+
+// commands is the command router, keyed by command name. It is filled in
+// init because the help command refers back to it.
+var commands map[string]commandSpec
+
+func init() {
+	commands = map[string]commandSpec{
+		"help":        {usage: "help", run: (*Handler).help},
+		"status":      {usage: "status", run: (*Handler).status},
+		"subscribe":   {usage: "subscribe <owner/repo> <event,event...>", writes: true, run: (*Handler).subscribe},
+		"unsubscribe": {usage: "unsubscribe <owner/repo>", writes: true, run: (*Handler).unsubscribe},
+		"mute":        {usage: "mute <owner/repo> <duration, e.g. 2h or 1d>", writes: true, run: (*Handler).mute},
+		"unmute":      {usage: "unmute <owner/repo>", writes: true, run: (*Handler).unmute},
+		"snooze":      {usage: "snooze <owner/repo#number> <duration, e.g. 4h or 1d>", run: (*Handler).snooze},
+	}
+}
+
+// run parses and executes a command message and returns the reply text.
+func (h *Handler) run(cfg *config.Config, msg *feishu.MessageEvent) string {
+	fields := strings.Fields(msg.Text)
+	if len(fields) == 0 {
+		return h.usage()
+	}
+	c := command{name: strings.ToLower(fields[0]), args: fields[1:], chatID: msg.ChatID, msg: msg}
+	spec, ok := commands[c.name]
+	if !ok {
+		return fmt.Sprintf("Unknown command %q.\n%s", c.name, h.usage())
+	}
+	// Write commands can route any tracked repo's events to any chat, so
+	// they are denied to everyone until allowed_operators names who may.
+	operators := cfg.Server.ChatCommands.AllowedOperators
+	if spec.writes && (len(operators) == 0 || !cfg.OperatorAllowed(operators, msg.OpenID, msg.UserID)) {
+		logger.Warn("Chat command %q from %s denied", msg.Text, msg.OpenID)
+		return "You are not allowed to change subscriptions."
+	}
+	reply, err := spec.run(h, cfg, c)
+	if err != nil {
+		logger.Info("Chat command %q from %s in %s failed: %v", msg.Text, msg.OpenID, msg.ChatID, err)
+		return "Error: " + err.Error()
+	}
+	logger.Info("Chat command %q from %s in %s done", msg.Text, msg.OpenID, msg.ChatID)
+	return reply
+}
+
+func (h *Handler) usage() string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString("Commands:")
+	for _, name := range names {
+		b.WriteString("\n  " + commands[name].usage)
+	}
+	return b.String()
+}
+
+func (h *Handler) help(_ *config.Config, _ command) (string, error) {
+	return h.usage(), nil
+}
+
+// status lists the rules that deliver to this chat.
+func (h *Handler) status(cfg *config.Config, c command) (string, error) {
+	var lines []string
+	for _, rule := range cfg.Repos.Repos {
+		delivers := false
+		for _, t := range rule.NotifyTo {
+			if deliversToChat(cfg, t, c.chatID) {
+				delivers = true
+			}
+		}
+		if !delivers {
+			continue
+		}
+		line := fmt.Sprintf("- %s: %s", rule.Pattern, strings.Join(eventNames(rule.Events), ", "))
+		if rule.Muted(h.now()) {
+			line += " (muted until " + rule.MutedUntil.Local().Format("2006-01-02 15:04") + ")"
+		}
+		lines = append(lines, line)
+	}
+	if h.suppressions != nil {
+		for _, sup := range h.suppressions.Active(h.now()) {
+			if sup.Repo != "" && sup.Event == "" && deliversToChat(cfg, sup.Target, c.chatID) {
+				lines = append(lines, "- "+sup.Repo+": muted in this chat until "+sup.Until.Local().Format("2006-01-02 15:04"))
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return "This chat has no subscriptions.", nil
+	}
+	return "Subscriptions for this chat:\n" + strings.Join(lines, "\n"), nil
+}
+
+func (h *Handler) subscribe(_ *config.Config, c command) (string, error) {
+	if len(c.args) < 2 || !strings.Contains(c.args[0], "/") {
+		return "", fmt.Errorf("usage: %s", commands["subscribe"].usage)
+	}
+	repo := c.args[0]
+	var events []string
+	for _, arg := range c.args[1:] {
+		for _, e := range strings.Split(arg, ",") {
+			if e = strings.TrimSpace(e); e != "" {
+				events = append(events, e)
+			}
+		}
+	}
+	return h.editRepos(func(cfg *config.Config) (string, error) {
+		for _, e := range events {
+			_, isEvent := cfg.Events.Events[e]
+			_, isSet := cfg.Events.EventSets[e]
+			if !isEvent && !isSet {
+				return "", fmt.Errorf("unknown event %q", e)
+			}
+		}
+		idx := chatRule(cfg, repo, c.chatID)
+		if idx < 0 {
+			// Without match_all_rules only the first matching rule fires, so
+			// a new rule would either never run or shadow an existing one.
+			if !cfg.Server.Server.MatchAllRules {
+				rule, err := matcher.MatchRepo(repo, cfg.Repos.Repos)
+				if err != nil {
+					return "", err
+				}
+				if rule != nil {
+					return "", fmt.Errorf("%s is already handled by rule %q; enable server.match_all_rules to add chat subscriptions next to it", repo, rule.Pattern)
+				}
+			}
+			cfg.Repos.Repos = append(cfg.Repos.Repos, config.RepoPattern{
+				Pattern:  repo,
+				Events:   map[string]any{},
+				NotifyTo: []string{c.target()},
+			})
+			idx = len(cfg.Repos.Repos) - 1
+		}
+		rule := &cfg.Repos.Repos[idx]
+		if rule.Events == nil {
+			rule.Events = map[string]any{}
+		}
+		for _, e := range events {
+			if _, ok := rule.Events[e]; !ok {
+				rule.Events[e] = nil
+			}
+		}
+		return fmt.Sprintf("Subscribed this chat to %s: %s", repo, strings.Join(eventNames(rule.Events), ", ")), nil
+	})
+}
+
+func (h *Handler) unsubscribe(_ *config.Config, c command) (string, error) {
+	if len(c.args) != 1 {
+		return "", fmt.Errorf("usage: %s", commands["unsubscribe"].usage)
+	}
+	repo := c.args[0]
+	return h.editRepos(func(cfg *config.Config) (string, error) {
+		idx := chatRule(cfg, repo, c.chatID)
+		if idx < 0 {
+			return "", fmt.Errorf("this chat has no subscription for %s", repo)
+		}
+		cfg.Repos.Repos = append(cfg.Repos.Repos[:idx], cfg.Repos.Repos[idx+1:]...)
+		return "Unsubscribed this chat from " + repo, nil
+	})
+}
+
+// mute silences repo for this chat: a rule of the chat's own is muted as a
+// whole, while rules shared with other targets get a runtime suppression for
+// the chat's targets only.
+func (h *Handler) mute(cfg *config.Config, c command) (string, error) {
+	if len(c.args) != 2 {
+		return "", fmt.Errorf("usage: %s", commands["mute"].usage)
+	}
+	repo := c.args[0]
+	d, err := config.ParseDuration(c.args[1])
+	if err != nil {
+		return "", err
+	}
+	if d > maxMute {
+		return "", fmt.Errorf("duration must be at most 30d")
+	}
+	until := h.now().Add(d).Truncate(time.Second)
+	if chatRule(cfg, repo, c.chatID) < 0 {
+		return h.muteChatTargets(cfg, repo, c.chatID, until)
+	}
+	return h.editRepos(func(cfg *config.Config) (string, error) {
+		idx := chatRule(cfg, repo, c.chatID)
+		if idx < 0 {
+			return "", fmt.Errorf("this chat has no subscription for %s", repo)
+		}
+		cfg.Repos.Repos[idx].MutedUntil = until
+		return fmt.Sprintf("Muted %s until %s", repo, until.Local().Format("2006-01-02 15:04")), nil
+	})
+}
+
+func (h *Handler) unmute(cfg *config.Config, c command) (string, error) {
+	if len(c.args) != 1 {
+		return "", fmt.Errorf("usage: %s", commands["unmute"].usage)
+	}
+	repo := c.args[0]
+	removed, err := h.unmuteChatTargets(cfg, repo, c.chatID)
+	if err != nil {
+		return "", err
+	}
+	if chatRule(cfg, repo, c.chatID) < 0 {
+		if removed == 0 {
+			return "", fmt.Errorf("%s is not muted in this chat", repo)
+		}
+		return "Unmuted " + repo, nil
+	}
+	return h.editRepos(func(cfg *config.Config) (string, error) {
+		idx := chatRule(cfg, repo, c.chatID)
+		if idx < 0 {
+			return "", fmt.Errorf("this chat has no subscription for %s", repo)
+		}
+		cfg.Repos.Repos[idx].MutedUntil = time.Time{}
+		return "Unmuted " + repo, nil
+	})
+}
+
+// muteChatTargets suppresses, until until, the targets delivering to chatID
+// of the rules matching repo.
+func (h *Handler) muteChatTargets(cfg *config.Config, repo, chatID string, until time.Time) (string, error) {
+	targets, err := chatTargets(cfg, repo, chatID)
+	if err != nil {
+		return "", err
+	}
+	if len(targets) == 0 {
+		return "", fmt.Errorf("this chat has no subscription for %s", repo)
+	}
+	if h.suppressions == nil {
+		return "", fmt.Errorf("%s is shared with other targets and runtime suppressions are not enabled", repo)
+	}
+	for _, target := range targets {
+		sup := store.Suppression{Repo: repo, Target: target, Reason: "muted from chat " + chatID, Until: until}
+		if _, err := h.suppressions.Add(sup); err != nil {
+			logger.Error("Failed to save suppression of %s for %s: %v", repo, target, err)
+			return "", fmt.Errorf("failed to save the mute")
+		}
+	}
+	return fmt.Sprintf("Muted %s in this chat until %s", repo, until.Local().Format("2006-01-02 15:04")), nil
+}
+
+// unmuteChatTargets removes the suppressions of repo for targets delivering
+// to chatID and returns how many there were.
+func (h *Handler) unmuteChatTargets(cfg *config.Config, repo, chatID string) (int, error) {
+	if h.suppressions == nil {
+		return 0, nil
+	}
+	removed := 0
+	for _, sup := range h.suppressions.Active(h.now()) {
+		if sup.Repo != repo || sup.Event != "" || !deliversToChat(cfg, sup.Target, chatID) {
+			continue
+		}
+		if _, err := h.suppressions.Remove(sup.ID); err != nil {
+			logger.Error("Failed to remove suppression %s: %v", sup.ID, err)
+			return removed, fmt.Errorf("failed to save the unmute")
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// snooze holds the review reminders of one pull request. It changes no
+// configuration, so it is open to everyone in the chat.
+func (h *Handler) snooze(cfg *config.Config, c command) (string, error) {
+	if len(c.args) != 2 {
+		return "", fmt.Errorf("usage: %s", commands["snooze"].usage)
+	}
+	if h.pulls == nil {
+		return "", fmt.Errorf("review reminders are not enabled")
+	}
+	key := c.args[0]
+	repo, number, ok := strings.Cut(key, "#")
+	if n, err := strconv.Atoi(number); !ok || err != nil || n <= 0 {
+		return "", fmt.Errorf("expected owner/repo#number, got %q", key)
+	}
+	if rule, err := matcher.MatchRepo(repo, cfg.Repos.Repos); err != nil || rule == nil {
+		return "", fmt.Errorf("repository %s is not tracked", repo)
+	}
+	d, err := config.ParseDuration(c.args[1])
+	if err != nil {
+		return "", err
+	}
+	if d > maxMute {
+		return "", fmt.Errorf("duration must be at most 30d")
+	}
+	until := h.now().Add(d).Truncate(time.Second)
+	tracked, err := h.pulls.Snooze(key, until)
+	if err != nil {
+		logger.Error("Failed to snooze %s: %v", key, err)
+		return "", fmt.Errorf("failed to save the snooze")
+	}
+	if !tracked {
+		return "", fmt.Errorf("%s is not tracked for review reminders", key)
+	}
+	return fmt.Sprintf("Snoozed review reminders for %s until %s", key, until.Local().Format("2006-01-02 15:04")), nil
+}
+
+// editRepos loads the configuration from disk, applies edit, and saves
+// repos.yaml with the panel's atomic writer. Edits are serialized.
+func (h *Handler) editRepos(edit func(cfg *config.Config) (string, error)) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	cfg, err := config.Load(h.configDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to load configuration")
+	}
+	reply, err := edit(cfg)
+	if err != nil {
+		return "", err
+	}
+	if err := panel.SaveYAML(filepath.Join(h.configDir, "repos.yaml"), cfg.Repos); err != nil {
+		logger.Error("Failed to save repos.yaml: %v", err)
+		return "", fmt.Errorf("failed to save repos.yaml")
+	}
+	if h.onSave != nil {
+		h.onSave()
+	}
+	return reply, nil
+}
+
+// chatRule returns the index of the rule for repo whose targets all deliver
+// to chatID (the rule "owned" by the chat), or -1.
+func chatRule(cfg *config.Config, repo, chatID string) int {
+	for i, rule := range cfg.Repos.Repos {
+		if rule.Pattern != repo || len(rule.NotifyTo) == 0 {
+			continue
+		}
+		owned := true
+		for _, t := range rule.NotifyTo {
+			if !deliversToChat(cfg, t, chatID) {
+				owned = false
+			}
+		}
+		if owned {
+			return i
+		}
+	}
+	return -1
+}
+
+// chatTargets returns the notify_to targets delivering to chatID of the
+// rules matching repo.
+func chatTargets(cfg *config.Config, repo, chatID string) ([]string, error) {
+	rules, err := matcher.MatchAllRepos(repo, cfg.Repos.Repos)
+	if err != nil {
+		return nil, err
+	}
+	var targets []string
+	for _, rule := range rules {
+		for _, t := range rule.NotifyTo {
+			if deliversToChat(cfg, t, chatID) && !slices.Contains(targets, t) {
+				targets = append(targets, t)
+			}
+		}
+	}
+	return targets, nil
+}
+
+// deliversToChat reports whether a notify_to target is the given chat, either
+// inline (chat:oc_xxx) or through an app bot alias.
+func deliversToChat(cfg *config.Config, target, chatID string) bool {
+	for _, bot := range cfg.FeishuBots.FeishuBots {
+		if bot.Alias == target && bot.Type == "app" {
+			target = bot.ReceiveID
+			break
+		}
+	}
+	idType, id, ok := feishu.ParseReceiveTarget(target)
+	return ok && idType == feishu.ReceiveIDChat && id == chatID
+}
+
+// eventNames returns the sorted event names of a rule.
+func eventNames(events map[string]any) []string {
+	names := make([]string, 0, len(events))
+	for name := range events {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
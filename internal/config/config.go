@@ -6,8 +6,12 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/hnrobert/feishu-github-tracker/internal/schedule"
 	"gopkg.in/yaml.v3"
 )
 
@@ -17,6 +21,8 @@ type Config struct {
 	Repos      ReposConfig
 	Events     EventsConfig
 	FeishuBots FeishuBotsConfig
+	Users      UsersConfig                // optional users.yaml
+	OnCall     OnCallConfig               // optional oncall.yaml
 	Templates  map[string]TemplatesConfig // Key: template name (e.g., "default", "cn")
 }
 
@@ -31,8 +37,131 @@ type ServerConfig struct {
 		MaxPayloadSize string `yaml:"max_payload_size"`
 		Timeout        int    `yaml:"timeout"`
 	} `yaml:"server"`
-	AllowedSources []string    `yaml:"allowed_sources"`
-	Panel          PanelConfig `yaml:"panel"`
+	AllowedSources []string           `yaml:"allowed_sources"`
+	Filters        SenderFilter       `yaml:"filters,omitempty"` // global sender filters, applied before every rule's own
+	State          StateConfig        `yaml:"state,omitempty"`
+	GitHub         GitHubConfig       `yaml:"github,omitempty"`
+	CardActions    CardActionsConfig  `yaml:"card_actions,omitempty"`
+	ChatCommands   ChatCommandsConfig `yaml:"chat_commands,omitempty"`
+	Reports        []ReportConfig     `yaml:"reports,omitempty"`
+	Panel          PanelConfig        `yaml:"panel"`
+}
+
+// GitHubConfig holds the credentials used to call the GitHub REST API (card
+// actions). Either a token or GitHub App credentials are required.
+type GitHubConfig struct {
+	APIURL string `yaml:"api_url,omitempty"` // defaults to https://api.github.com; GHES: https://host/api/v3
+	Token  string `yaml:"token,omitempty"`   // PAT or fine-grained token; GITHUB_TOKEN env overrides
+	// GitHub App authentication, used when no token is set. Without an
+	// installation_id the installation is looked up per repository.
+	AppID          int64  `yaml:"app_id,omitempty"`
+	InstallationID int64  `yaml:"installation_id,omitempty"`
+	PrivateKeyFile string `yaml:"private_key_file,omitempty"` // PEM file, relative to the config directory
+	PrivateKey     string `yaml:"-"`                          // contents of PrivateKeyFile, filled by Load
+}
+
+// CardActionsConfig controls the Feishu card button callback endpoint
+// (/feishu/card), which performs GitHub actions on behalf of chat members.
+type CardActionsConfig struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Actions lists the allowed actions; empty allows all of approve_deployment,
+	// reject_deployment, rerun_workflow, close_issue and label_issue.
+	Actions []string `yaml:"actions,omitempty"`
+	// AllowedOperators restricts who may click: Feishu open_id / user_id /
+	// email, or GitHub logins mapped in users.yaml. Empty allows anyone, except
+	// for approve_deployment and reject_deployment, which then nobody may use.
+	AllowedOperators []string `yaml:"allowed_operators,omitempty"`
+}
+
+// StateConfig controls the runtime state kept under DATA_DIR.
+type StateConfig struct {
+	// MessageRetentionDays is how long the PR/issue -> Feishu message index is
+	// kept after its last update; 0 uses the default of 30 days.
+	MessageRetentionDays int `yaml:"message_retention_days,omitempty"`
+	// HistoryRetentionDays is how long the event history aggregated by
+	// reports is kept; 0 uses the default of 35 days.
+	HistoryRetentionDays int `yaml:"history_retention_days,omitempty"`
+}
+
+// ReportConfig is a scheduled activity report (server.yaml `reports:`): a
+// summary of the PRs, issues, releases, failed workflows and contributors
+// recorded over a period, sent on a cron schedule.
+type ReportConfig struct {
+	Name     string `yaml:"name"`
+	Schedule string `yaml:"schedule"`           // cron spec, e.g. "0 9 * * 1" (Mondays 09:00) or "@daily"
+	Timezone string `yaml:"timezone,omitempty"` // IANA name, e.g. "Asia/Shanghai"; default local time
+	// Period is the span covered, e.g. "24h" or "7d"; by default the time
+	// since the previous scheduled run.
+	Period string `yaml:"period,omitempty"`
+	// Repos limits the report to repositories matching these globs; by
+	// default it covers the repos whose rules notify one of NotifyTo.
+	Repos           []string `yaml:"repos,omitempty"`
+	NotifyTo        []string `yaml:"notify_to"`
+	TopContributors int      `yaml:"top_contributors,omitempty"` // contributors listed; default 5
+}
+
+// Cron returns the parsed schedule; Load has validated it.
+func (r *ReportConfig) Cron() *schedule.Schedule {
+	s, _ := schedule.Parse(r.Schedule)
+	return s
+}
+
+// Location returns the report's timezone; Load has validated it.
+func (r *ReportConfig) Location() *time.Location {
+	if r.Timezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(r.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// PeriodDuration returns the parsed period, or 0 for "since the previous
+// run". Besides Go durations it accepts whole days such as "7d".
+func (r *ReportConfig) PeriodDuration() time.Duration {
+	d, _ := ParseDuration(r.Period)
+	return d
+}
+
+// ParseDuration accepts Go durations ("36h") and whole days ("7d"); the
+// empty string is 0.
+func ParseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+// SenderFilter drops webhooks by who triggered them. It is used globally
+// (server.yaml `filters:`) and inline on each repos.yaml rule.
+type SenderFilter struct {
+	ExcludeSenders []string `yaml:"exclude_senders,omitempty"` // login globs to ignore, e.g. "renovate*"
+	IncludeSenders []string `yaml:"include_senders,omitempty"` // if set, only these login globs notify
+	// SkipBots ignores senders with sender.type == "Bot" or a "[bot]" login
+	// suffix. A rule-level value overrides the global one.
+	SkipBots *bool `yaml:"skip_bots,omitempty"`
+}
+
+// ChatCommandsConfig controls the Feishu event endpoint (/feishu/event) that
+// accepts "@tracker subscribe org/repo push" style commands from chats.
+type ChatCommandsConfig struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	// AllowedOperators restricts who may run commands that change repos.yaml
+	// (same forms as card_actions.allowed_operators). Empty allows no one.
+	AllowedOperators []string `yaml:"allowed_operators,omitempty"`
 }
 
 // PanelConfig represents the optional `panel:` block in server.yaml, used to
@@ -56,6 +185,299 @@ type RepoPattern struct {
 	Events   map[string]any `yaml:"events"`
 	NotifyTo []string       `yaml:"notify_to"`
 	Secret   string         `yaml:"secret,omitempty"` // optional per-rule webhook secret; falls back to server.secret
+	// LabelRoutes adds extra targets for issues / pull_request events whose
+	// labels match one of the route's globs (e.g. "area/infra*" -> sre-team).
+	LabelRoutes []LabelRoute `yaml:"label_routes,omitempty"`
+	// Owners routes push / pull_request events to the teams owning the
+	// changed files. CodeOwners optionally names a CODEOWNERS-format file in
+	// the config dir whose entries are evaluated before Owners.
+	Owners     []OwnerRule `yaml:"owners,omitempty"`
+	CodeOwners string      `yaml:"codeowners,omitempty"`
+	// CodeOwnerRules holds the parsed CodeOwners file (filled in by Load).
+	CodeOwnerRules []OwnerRule `yaml:"-"`
+	// SenderFilter (exclude_senders / include_senders / skip_bots) applies on
+	// top of the global filters: exclude_senders adds to the global list,
+	// while a set skip_bots overrides the global value and a non-empty
+	// include_senders replaces the global list.
+	SenderFilter `yaml:",inline"`
+	// MutedUntil suppresses the rule's notifications until this time (set by
+	// the "mute" chat command).
+	MutedUntil time.Time `yaml:"muted_until,omitempty"`
+	// Digest buffers the listed events and sends them as one summary card
+	// per window instead of a card each.
+	Digest *DigestConfig `yaml:"digest,omitempty"`
+	// StalePRs sends review reminders for the rule's pull requests that
+	// have been waiting for review too long.
+	StalePRs *StalePRConfig `yaml:"stale_prs,omitempty"`
+	// CommitStatus groups the rule's check and job events by head commit
+	// and sends one status card per commit once its checks finish.
+	CommitStatus *CommitStatusConfig `yaml:"commit_status,omitempty"`
+	// QuietHours overrides the quiet hours of the bots notified for the
+	// repos this rule matches.
+	QuietHours *QuietHours `yaml:"quiet_hours,omitempty"`
+	// DeploymentLifecycle correlates the rule's deployment events by
+	// deployment and sends a summary card once each deployment finishes.
+	DeploymentLifecycle *DeploymentLifecycleConfig `yaml:"deployment_lifecycle,omitempty"`
+}
+
+// Muted reports whether the rule is muted at now.
+func (r *RepoPattern) Muted(now time.Time) bool {
+	return now.Before(r.MutedUntil)
+}
+
+// Digested reports whether the rule batches eventType into a digest.
+func (r *RepoPattern) Digested(eventType string) bool {
+	return r.Digest != nil && slices.Contains(r.Digest.Events, eventType)
+}
+
+// CoalescesCommitStatus reports whether the rule groups eventType into a
+// per-commit status card.
+func (r *RepoPattern) CoalescesCommitStatus(eventType string) bool {
+	return r.CommitStatus != nil && slices.Contains(r.CommitStatus.EventTypes(), eventType)
+}
+
+// DigestConfig is a rule's digest window, e.g.
+// `digest: {window: 15m, events: [push, watch, star]}`.
+type DigestConfig struct {
+	Window string   `yaml:"window"` // Go duration, e.g. "15m" or "1h"
+	Events []string `yaml:"events"` // event types (not event sets) to batch
+}
+
+// StalePRConfig is a rule's review reminder policy, e.g.
+// `stale_prs: {after: 2d, repeat: 1d, working_hours: {start: "09:00", end: "18:00"}}`.
+type StalePRConfig struct {
+	After  string `yaml:"after"`            // waiting time before the first reminder, e.g. "48h" or "2d"
+	Repeat string `yaml:"repeat,omitempty"` // time between reminders; default After
+	// WorkingHours, if set, holds reminders outside the window until it
+	// opens.
+	WorkingHours *TimeWindow `yaml:"working_hours,omitempty"`
+}
+
+func (s *StalePRConfig) validate() error {
+	if d, err := ParseDuration(s.After); err != nil || d <= 0 {
+		return fmt.Errorf("invalid after %q", s.After)
+	}
+	if _, err := ParseDuration(s.Repeat); err != nil {
+		return err
+	}
+	if s.WorkingHours != nil {
+		return s.WorkingHours.validate()
+	}
+	return nil
+}
+
+// AfterDuration returns the parsed waiting time; Load has validated it.
+func (s *StalePRConfig) AfterDuration() time.Duration {
+	d, _ := ParseDuration(s.After)
+	return d
+}
+
+// RepeatDuration returns the parsed reminder interval, defaulting to After.
+func (s *StalePRConfig) RepeatDuration() time.Duration {
+	if d, _ := ParseDuration(s.Repeat); d > 0 {
+		return d
+	}
+	return s.AfterDuration()
+}
+
+// commitStatusEvents are the events a commit status card can group.
+var commitStatusEvents = []string{"check_run", "check_suite", "workflow_job"}
+
+// CommitStatusConfig is a rule's per-commit check aggregation, e.g.
+// `commit_status: {timeout: 30m, events: [check_run, workflow_job]}`.
+type CommitStatusConfig struct {
+	// Timeout is the longest wait for unfinished checks, from the first
+	// event of the commit; default 30m.
+	Timeout string   `yaml:"timeout,omitempty"`
+	Events  []string `yaml:"events,omitempty"` // default check_run, check_suite, workflow_job
+}
+
+func (c *CommitStatusConfig) validate() error {
+	if _, err := ParseDuration(c.Timeout); err != nil {
+		return err
+	}
+	for _, event := range c.Events {
+		if !slices.Contains(commitStatusEvents, event) {
+			return fmt.Errorf("unsupported event %q (use %s)", event, strings.Join(commitStatusEvents, ", "))
+		}
+	}
+	return nil
+}
+
+// TimeoutDuration returns the parsed timeout, defaulting to 30 minutes.
+func (c *CommitStatusConfig) TimeoutDuration() time.Duration {
+	if d, _ := ParseDuration(c.Timeout); d > 0 {
+		return d
+	}
+	return 30 * time.Minute
+}
+
+// EventTypes returns the grouped events, defaulting to all supported ones.
+func (c *CommitStatusConfig) EventTypes() []string {
+	if len(c.Events) == 0 {
+		return commitStatusEvents
+	}
+	return c.Events
+}
+
+// deploymentEvents are the events a deployment lifecycle correlates.
+var deploymentEvents = []string{"deployment", "deployment_status", "deployment_review", "deployment_protection_rule"}
+
+// DeploymentLifecycleConfig is a rule's deployment tracking, e.g.
+// `deployment_lifecycle: {events: [deployment, deployment_status]}`.
+type DeploymentLifecycleConfig struct {
+	// Events are the deployment events whose own cards the summary card
+	// replaces; default deployment and deployment_status, so approval
+	// requests still get their card.
+	Events []string `yaml:"events,omitempty"`
+}
+
+func (c *DeploymentLifecycleConfig) validate() error {
+	for _, event := range c.Events {
+		if !slices.Contains(deploymentEvents, event) {
+			return fmt.Errorf("unsupported event %q (use %s)", event, strings.Join(deploymentEvents, ", "))
+		}
+	}
+	return nil
+}
+
+// Absorbs reports whether the summary card replaces the cards of
+// eventType.
+func (c *DeploymentLifecycleConfig) Absorbs(eventType string) bool {
+	if len(c.Events) == 0 {
+		return eventType == "deployment" || eventType == "deployment_status"
+	}
+	return slices.Contains(c.Events, eventType)
+}
+
+// weekdays maps TimeWindow day names to time.Weekday.
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// TimeWindow is a daily time window in a timezone, e.g. working hours
+// `{start: "09:00", end: "18:00", days: [mon, tue, wed, thu, fri], timezone: Asia/Shanghai}`.
+// An End before Start wraps past midnight.
+type TimeWindow struct {
+	Start    string   `yaml:"start"`              // HH:MM
+	End      string   `yaml:"end"`                // HH:MM
+	Days     []string `yaml:"days,omitempty"`     // mon ... sun; default every day
+	Timezone string   `yaml:"timezone,omitempty"` // IANA name; default local time
+}
+
+// Contains reports whether t falls inside the window; Load has validated it.
+// The day of a window that wraps past midnight is the day it started.
+func (w *TimeWindow) Contains(t time.Time) bool {
+	loc := time.Local
+	if w.Timezone != "" {
+		if l, err := time.LoadLocation(w.Timezone); err == nil {
+			loc = l
+		}
+	}
+	t = t.In(loc)
+	start, _ := parseClock(w.Start)
+	end, _ := parseClock(w.End)
+	minute := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+	switch {
+	case start <= end:
+		if minute < start || minute >= end {
+			return false
+		}
+	case minute >= start:
+	case minute < end:
+		day = (day + 6) % 7 // the early-morning part belongs to yesterday's window
+	default:
+		return false
+	}
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, name := range w.Days {
+		if weekdays[strings.ToLower(name)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *TimeWindow) validate() error {
+	if _, err := parseClock(w.Start); err != nil {
+		return err
+	}
+	if _, err := parseClock(w.End); err != nil {
+		return err
+	}
+	for _, name := range w.Days {
+		if _, ok := weekdays[strings.ToLower(name)]; !ok {
+			return fmt.Errorf("invalid day %q (use mon ... sun)", name)
+		}
+	}
+	if w.Timezone != "" {
+		if _, err := time.LoadLocation(w.Timezone); err != nil {
+			return fmt.Errorf("unknown timezone %q", w.Timezone)
+		}
+	}
+	return nil
+}
+
+// QuietHours holds a bot's messages inside the window and sends them once it
+// ends, e.g. `quiet_hours: {start: "22:00", end: "08:00", timezone: Europe/Berlin,
+// bypass: [severity:critical, broken]}`.
+type QuietHours struct {
+	TimeWindow `yaml:",inline"`
+	// Bypass lists event types or template tags (e.g. "severity:critical",
+	// "broken") that are sent even during quiet hours.
+	Bypass []string `yaml:"bypass,omitempty"`
+}
+
+// Bypassed reports whether an event with these tags is sent during quiet
+// hours.
+func (q *QuietHours) Bypassed(eventType string, tags []string) bool {
+	for _, b := range q.Bypass {
+		if b == eventType || slices.Contains(tags, b) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseClock parses "HH:MM" into minutes after midnight.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q (use HH:MM)", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// WindowDuration returns the parsed window; Load has validated it.
+func (d *DigestConfig) WindowDuration() time.Duration {
+	w, _ := time.ParseDuration(d.Window)
+	return w
+}
+
+// LabelRoute maps label globs to additional notification targets.
+type LabelRoute struct {
+	Labels   []string `yaml:"labels"`    // label name globs, matched case-insensitively
+	NotifyTo []string `yaml:"notify_to"` // bot aliases or webhook URLs
+}
+
+// OwnerRule maps CODEOWNERS-style path patterns to notification targets.
+// As in CODEOWNERS, the last rule matching a file decides its owners.
+type OwnerRule struct {
+	Paths    []string `yaml:"paths"`
+	NotifyTo []string `yaml:"notify_to"`
+}
+
+// OwnershipRules returns the rule's ownership entries in evaluation order:
+// the CODEOWNERS file first, then the inline owners (so inline entries win).
+func (r *RepoPattern) OwnershipRules() []OwnerRule {
+	if len(r.CodeOwnerRules) == 0 {
+		return r.Owners
+	}
+	return append(append([]OwnerRule(nil), r.CodeOwnerRules...), r.Owners...)
 }
 
 // EventsConfig represents events.yaml
@@ -66,20 +488,398 @@ type EventsConfig struct {
 
 // FeishuBotsConfig represents feishu-bots.yaml
 type FeishuBotsConfig struct {
-	FeishuBots []FeishuBot `yaml:"feishu_bots"`
+	FeishuApp  FeishuAppConfig `yaml:"feishu_app,omitempty"` // optional app credentials for app-bot (Open API) delivery
+	SMTP       SMTPConfig      `yaml:"smtp,omitempty"`       // optional mail server for email bots
+	FeishuBots []FeishuBot     `yaml:"feishu_bots"`
+}
+
+// SMTPConfig is the mail server used by bots with type: email.
+type SMTPConfig struct {
+	Host     string `yaml:"host,omitempty"`
+	Port     int    `yaml:"port,omitempty"` // defaults to 587, or 465 with tls: tls
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"` // SMTP_PASSWORD in the environment takes precedence
+	From     string `yaml:"from,omitempty"`
+	// TLS is "starttls" (default, required), "tls" (implicit TLS) or "none"
+	// (plain text, for local relays).
+	TLS     string `yaml:"tls,omitempty"`
+	Timeout int    `yaml:"timeout,omitempty"` // seconds, defaults to 15
+}
+
+// FeishuAppConfig holds the credentials of a Feishu custom app. When set,
+// targets of the form chat:<chat_id> / user:<email|open_id|user_id> (and bots
+// with type: app) are delivered through the Open API instead of a webhook.
+type FeishuAppConfig struct {
+	AppID     string `yaml:"app_id,omitempty"`
+	AppSecret string `yaml:"app_secret,omitempty"`
+	BaseURL   string `yaml:"base_url,omitempty"` // defaults to https://open.feishu.cn; use https://open.larksuite.com for Lark
+	// VerificationToken and EncryptKey come from the app's "Events &
+	// Callbacks" page and authenticate callbacks sent by Feishu.
+	VerificationToken string `yaml:"verification_token,omitempty"`
+	EncryptKey        string `yaml:"encrypt_key,omitempty"`
+	// Threading is the default threading mode for app bots: "" (a new
+	// message per event), "update" or "reply". See FeishuBot.Threading.
+	Threading string `yaml:"threading,omitempty"`
 }
 
 type FeishuBot struct {
 	Alias    string `yaml:"alias"`
-	URL      string `yaml:"url"`
+	URL      string `yaml:"url,omitempty"`
 	Template string `yaml:"template,omitempty"` // Optional: template name (e.g., "cn"), defaults to "default"
+	// Type selects the delivery sink: "webhook" (default, Feishu custom bot
+	// URL), "app" (Open API via feishu_app, sent to ReceiveID), "dingtalk" or
+	// "wecom" (DingTalk / WeCom group robot URL), "slack" (Slack incoming
+	// webhook), "teams" (Microsoft Teams workflow webhook), "generic" (any
+	// HTTP endpoint, see Body), "email" (SMTP to To, via the smtp block) or
+	// "bitable" (rows in a Feishu Bitable via feishu_app, see AppToken).
+	Type      string `yaml:"type,omitempty"`
+	ReceiveID string `yaml:"receive_id,omitempty"` // app bots: chat:oc_xxx, user:alice@corp, user:ou_xxx
+	// Secret signs requests when set: the DingTalk robot's "加签" secret, or
+	// the HMAC-SHA256 key of a generic target.
+	Secret string `yaml:"secret,omitempty"`
+	// Generic targets only. Body is "envelope" (default: the event type,
+	// tags, template data and raw payload) or "template" (a template file
+	// with format "generic"). Timeout is in seconds; Retries is the number
+	// of extra attempts after a network error, 429 or 5xx response, within
+	// 30 seconds per delivery.
+	Body    string            `yaml:"body,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	Timeout int               `yaml:"timeout,omitempty"`
+	Retries int               `yaml:"retries,omitempty"`
+	// To lists the recipients of an email bot.
+	To []string `yaml:"to,omitempty"`
+	// Bitable targets only. Each event writes one record to the table
+	// TableID of the Bitable app AppToken. Fields maps an event type (or
+	// "default" for the others) to field name -> template expression over the
+	// prepared template data; events without a mapping are not recorded.
+	// When UniqueField is set, a record whose UniqueField already has the
+	// rendered value is updated instead of adding a new one.
+	AppToken    string                       `yaml:"app_token,omitempty"`
+	TableID     string                       `yaml:"table_id,omitempty"`
+	UniqueField string                       `yaml:"unique_field,omitempty"`
+	Fields      map[string]map[string]string `yaml:"fields,omitempty"`
+	// Threading (app bots only) groups the cards of one PR or issue: "update"
+	// patches the first card in place, "reply" answers in its thread. Empty
+	// inherits feishu_app.threading.
+	Threading string `yaml:"threading,omitempty"`
+	// QuietHours holds the bot's messages inside the window (see QuietHours).
+	QuietHours *QuietHours `yaml:"quiet_hours,omitempty"`
+}
+
+// UsersConfig represents the optional users.yaml, mapping GitHub logins to
+// Feishu users so cards can @mention people.
+type UsersConfig struct {
+	Users    []UserMapping  `yaml:"users"`
+	Mentions MentionsConfig `yaml:"mentions,omitempty"`
+	Tasks    TasksConfig    `yaml:"tasks,omitempty"`
+}
+
+// UserMapping links a GitHub login to a Feishu identity. At least one of
+// OpenID, UserID or Email should be set; they are preferred in that order.
+type UserMapping struct {
+	GitHub string `yaml:"github"`
+	OpenID string `yaml:"open_id,omitempty"`
+	UserID string `yaml:"user_id,omitempty"`
+	Email  string `yaml:"email,omitempty"`
+}
+
+// MentionsConfig controls the automatic `mentions_at` template variable.
+type MentionsConfig struct {
+	ReviewRequested bool `yaml:"review_requested,omitempty"` // mention the requested reviewer(s) on review_requested
+	Assigned        bool `yaml:"assigned,omitempty"`         // mention the assignee on assigned
+	// Security lists the GitHub logins mentioned on critical security
+	// alerts (severity:critical), and Broken those mentioned when a workflow
+	// breaks (the broken CI transition). "oncall:<schedule>" entries mention
+	// whoever is on call in that oncall.yaml schedule.
+	Security []string `yaml:"security,omitempty"`
+	Broken   []string `yaml:"broken,omitempty"`
+}
+
+// TasksConfig controls Feishu Task sync for issues (needs feishu_app). When
+// enabled, assigning an issue in a tracked repo to a user mapped with an
+// open_id or user_id creates a task for them; closing and reopening the issue
+// completes and reopens it.
+type TasksConfig struct {
+	Enabled      bool   `yaml:"enabled,omitempty"`
+	TasklistGUID string `yaml:"tasklist_guid,omitempty"` // optional tasklist for the created tasks
+}
+
+// OnCallConfig represents the optional oncall.yaml: on-call rotations whose
+// current member is exposed to templates as oncall.<schedule>.
+type OnCallConfig struct {
+	Schedules []OnCallSchedule `yaml:"schedules"`
+}
+
+// Rotation lengths (OnCallSchedule.Rotation).
+const (
+	RotationDaily  = "daily"
+	RotationWeekly = "weekly"
+)
+
+// onCallTimeLayout is the format of the handoff and override times.
+const onCallTimeLayout = "2006-01-02 15:04"
+
+// OnCallSchedule rotates Members (GitHub logins, mapped in users.yaml) daily
+// or weekly. Start is the first handoff ("2026-01-05 09:00" in Timezone):
+// Members[0] is on call from then, and the next member takes over at the same
+// time of day every day or week. Shifts before Start count backwards.
+type OnCallSchedule struct {
+	Name      string           `yaml:"name"`
+	Rotation  string           `yaml:"rotation"` // daily or weekly
+	Start     string           `yaml:"start"`
+	Timezone  string           `yaml:"timezone,omitempty"` // IANA name; default local time
+	Members   []string         `yaml:"members"`
+	Overrides []OnCallOverride `yaml:"overrides,omitempty"`
+}
+
+// OnCallOverride puts User on call from Start to End (same format and
+// timezone as the schedule's start), e.g. to swap a shift.
+type OnCallOverride struct {
+	User  string `yaml:"user"`
+	Start string `yaml:"start"`
+	End   string `yaml:"end"`
+}
+
+// OnCallShift is one stretch of a schedule with the same person on call.
+type OnCallShift struct {
+	User     string
+	Start    time.Time
+	End      time.Time
+	Override bool
+}
+
+func (o *OnCallConfig) validate() error {
+	seen := map[string]bool{}
+	for _, s := range o.Schedules {
+		if s.Name == "" {
+			return fmt.Errorf("schedule without a name")
+		}
+		if seen[s.Name] {
+			return fmt.Errorf("duplicate schedule %q", s.Name)
+		}
+		seen[s.Name] = true
+		if s.Rotation != RotationDaily && s.Rotation != RotationWeekly {
+			return fmt.Errorf("schedule %s: invalid rotation %q (use daily or weekly)", s.Name, s.Rotation)
+		}
+		if len(s.Members) == 0 {
+			return fmt.Errorf("schedule %s: members is empty", s.Name)
+		}
+		if s.Timezone != "" {
+			if _, err := time.LoadLocation(s.Timezone); err != nil {
+				return fmt.Errorf("schedule %s: unknown timezone %q", s.Name, s.Timezone)
+			}
+		}
+		if _, err := s.parseTime(s.Start); err != nil {
+			return fmt.Errorf("schedule %s: %w", s.Name, err)
+		}
+		for _, o := range s.Overrides {
+			start, err := s.parseTime(o.Start)
+			if err != nil {
+				return fmt.Errorf("schedule %s: override: %w", s.Name, err)
+			}
+			end, err := s.parseTime(o.End)
+			if err != nil {
+				return fmt.Errorf("schedule %s: override: %w", s.Name, err)
+			}
+			if o.User == "" || !end.After(start) {
+				return fmt.Errorf("schedule %s: override needs a user and an end after its start", s.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// Schedule returns the schedule called name, or nil.
+func (o *OnCallConfig) Schedule(name string) *OnCallSchedule {
+	for i := range o.Schedules {
+		if o.Schedules[i].Name == name {
+			return &o.Schedules[i]
+		}
+	}
+	return nil
+}
+
+// Location returns the schedule's timezone; Load has validated it.
+func (s *OnCallSchedule) Location() *time.Location {
+	if s.Timezone != "" {
+		if loc, err := time.LoadLocation(s.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
+
+func (s *OnCallSchedule) parseTime(v string) (time.Time, error) {
+	t, err := time.ParseInLocation(onCallTimeLayout, v, s.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q (use YYYY-MM-DD HH:MM)", v)
+	}
+	return t, nil
+}
+
+// days returns the rotation length in days.
+func (s *OnCallSchedule) days() int {
+	if s.Rotation == RotationWeekly {
+		return 7
+	}
+	return 1
+}
+
+// regularShift returns the index of the rotation shift containing t and its
+// start and end. Shifts follow the calendar of the schedule's timezone, so a
+// handoff stays at the same local time across DST changes.
+func (s *OnCallSchedule) regularShift(t time.Time) (int, time.Time, time.Time) {
+	start, _ := s.parseTime(s.Start)
+	loc := s.Location()
+	t = t.In(loc)
+	handoff := func(days int) time.Time {
+		return time.Date(start.Year(), start.Month(), start.Day()+days, start.Hour(), start.Minute(), 0, 0, loc)
+	}
+	// whole days from the start date to t's date, minus one before the handoff time
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	first := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	days := int(day.Sub(first).Hours() / 24)
+	if t.Before(handoff(days)) {
+		days--
+	}
+	n := s.days()
+	shift := days / n
+	if days < 0 && days%n != 0 {
+		shift--
+	}
+	return shift, handoff(shift * n), handoff((shift + 1) * n)
+}
+
+// OnCall returns who is on call at t and whether an override applies.
+func (s *OnCallSchedule) OnCall(t time.Time) (string, bool) {
+	for _, o := range s.Overrides {
+		start, _ := s.parseTime(o.Start)
+		end, _ := s.parseTime(o.End)
+		if !t.Before(start) && t.Before(end) {
+			return o.User, true
+		}
+	}
+	shift, _, _ := s.regularShift(t)
+	n := len(s.Members)
+	return s.Members[((shift%n)+n)%n], false
+}
+
+// Shifts returns the current shift at from and the following ones, count in
+// total, merging consecutive stretches of the same person.
+func (s *OnCallSchedule) Shifts(from time.Time, count int) []OnCallShift {
+	var shifts []OnCallShift
+	t := from
+	for len(shifts) < count {
+		user, override := s.OnCall(t)
+		_, _, end := s.regularShift(t)
+		// the shift also ends where an override starts or ends
+		for _, o := range s.Overrides {
+			for _, v := range []string{o.Start, o.End} {
+				if b, _ := s.parseTime(v); b.After(t) && b.Before(end) {
+					end = b
+				}
+			}
+		}
+		if n := len(shifts); n > 0 && shifts[n-1].User == user && shifts[n-1].Override == override {
+			shifts[n-1].End = end
+		} else {
+			start := t
+			if len(shifts) == 0 {
+				start = s.shiftStart(t, user, override)
+			}
+			shifts = append(shifts, OnCallShift{User: user, Start: start, End: end, Override: override})
+		}
+		t = end
+	}
+	return shifts
+}
+
+// shiftStart returns when the stretch of user containing t began: the start
+// of the override, or of the regular shift (or of the override it follows).
+func (s *OnCallSchedule) shiftStart(t time.Time, user string, override bool) time.Time {
+	var start time.Time
+	if !override {
+		_, start, _ = s.regularShift(t)
+	}
+	for _, o := range s.Overrides {
+		oStart, _ := s.parseTime(o.Start)
+		oEnd, _ := s.parseTime(o.End)
+		switch {
+		case override && o.User == user && !t.Before(oStart) && t.Before(oEnd):
+			return oStart
+		case !override && oEnd.After(start) && !oEnd.After(t):
+			start = oEnd
+		}
+	}
+	return start
+}
+
+// Bot types (FeishuBot.Type). An empty type is a Feishu webhook.
+const (
+	BotTypeWebhook  = "webhook"
+	BotTypeApp      = "app"
+	BotTypeDingTalk = "dingtalk"
+	BotTypeWeCom    = "wecom"
+	BotTypeSlack    = "slack"
+	BotTypeTeams    = "teams"
+	BotTypeGeneric  = "generic"
+	BotTypeEmail    = "email"
+	BotTypeBitable  = "bitable"
+)
+
+// Message formats a template file can produce (TemplatesConfig.Format).
+const (
+	FormatFeishu   = "feishu"
+	FormatDingTalk = "dingtalk"
+	FormatWeCom    = "wecom"
+	FormatSlack    = "slack" // Block Kit
+	FormatTeams    = "teams" // Adaptive Card message
+	FormatGeneric  = "generic"
+	FormatEmail    = "email" // {"subject", "text", "html"}
+)
+
+// Format returns the message format the bot's sink accepts.
+func (b FeishuBot) Format() string {
+	switch b.Type {
+	case BotTypeDingTalk:
+		return FormatDingTalk
+	case BotTypeWeCom:
+		return FormatWeCom
+	case BotTypeSlack:
+		return FormatSlack
+	case BotTypeTeams:
+		return FormatTeams
+	case BotTypeGeneric:
+		return FormatGeneric
+	case BotTypeEmail:
+		return FormatEmail
+	}
+	return FormatFeishu
+}
+
+// SendsEnvelope reports whether the bot receives the normalized event
+// envelope instead of a rendered template. Bitable bots render their own
+// field mappings from it.
+func (b FeishuBot) SendsEnvelope() bool {
+	return (b.Type == BotTypeGeneric && b.Body != "template") || b.Type == BotTypeBitable
 }
 
 // TemplatesConfig represents templates.jsonc (JSONC)
 type TemplatesConfig struct {
+	// Format declares which sink the payloads are written for: "feishu"
+	// (default), "dingtalk", "wecom", "slack", "teams", "generic" or "email".
+	Format    string                   `yaml:"format,omitempty"`
 	Templates map[string]EventTemplate `yaml:"templates"`
 }
 
+// SinkFormat returns the declared format, defaulting to "feishu".
+func (t TemplatesConfig) SinkFormat() string {
+	if t.Format == "" {
+		return FormatFeishu
+	}
+	return t.Format
+}
+
 type EventTemplate struct {
 	Payloads []PayloadTemplate `yaml:"payloads"`
 }
@@ -105,6 +905,67 @@ func Load(configDir string) (*Config, error) {
 		return nil, fmt.Errorf("failed to load repos.yaml: %w", err)
 	}
 
+	// Load CODEOWNERS-format files referenced by repo rules
+	for i := range cfg.Repos.Repos {
+		rule := &cfg.Repos.Repos[i]
+		if rule.CodeOwners == "" {
+			continue
+		}
+		path := rule.CodeOwners
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(configDir, path)
+		}
+		rules, err := loadCodeOwners(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load codeowners %s for %s: %w", rule.CodeOwners, rule.Pattern, err)
+		}
+		rule.CodeOwnerRules = rules
+	}
+
+	for _, rule := range cfg.Repos.Repos {
+		if rule.Digest != nil {
+			if w, err := time.ParseDuration(rule.Digest.Window); err != nil || w <= 0 {
+				return nil, fmt.Errorf("repos.yaml: rule %s: invalid digest window %q", rule.Pattern, rule.Digest.Window)
+			}
+		}
+		if rule.StalePRs != nil {
+			if err := rule.StalePRs.validate(); err != nil {
+				return nil, fmt.Errorf("repos.yaml: rule %s: stale_prs: %w", rule.Pattern, err)
+			}
+		}
+		if rule.CommitStatus != nil {
+			if err := rule.CommitStatus.validate(); err != nil {
+				return nil, fmt.Errorf("repos.yaml: rule %s: commit_status: %w", rule.Pattern, err)
+			}
+		}
+		if rule.DeploymentLifecycle != nil {
+			if err := rule.DeploymentLifecycle.validate(); err != nil {
+				return nil, fmt.Errorf("repos.yaml: rule %s: deployment_lifecycle: %w", rule.Pattern, err)
+			}
+		}
+		if rule.QuietHours != nil {
+			if err := rule.QuietHours.validate(); err != nil {
+				return nil, fmt.Errorf("repos.yaml: rule %s: quiet_hours: %w", rule.Pattern, err)
+			}
+		}
+	}
+
+	if err := validateReports(cfg.Server.Reports); err != nil {
+		return nil, fmt.Errorf("server.yaml: %w", err)
+	}
+
+	// Load the GitHub App private key, if configured
+	if keyFile := cfg.Server.GitHub.PrivateKeyFile; keyFile != "" {
+		if !filepath.IsAbs(keyFile) {
+			keyFile = filepath.Join(configDir, keyFile)
+		}
+		key, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load github private key: %w", err)
+		}
+		cfg.Server.GitHub.PrivateKey = string(key)
+	}
+
 	// Load events.yaml
 	if err := loadConfigFile(filepath.Join(configDir, "events.yaml"), &cfg.Events); err != nil {
 		return nil, fmt.Errorf("failed to load events.yaml: %w", err)
@@ -115,12 +976,42 @@ func Load(configDir string) (*Config, error) {
 		return nil, fmt.Errorf("failed to load feishu-bots.yaml: %w", err)
 	}
 
+	for _, bot := range cfg.FeishuBots.FeishuBots {
+		if bot.QuietHours != nil {
+			if err := bot.QuietHours.validate(); err != nil {
+				return nil, fmt.Errorf("feishu-bots.yaml: bot %s: quiet_hours: %w", bot.Alias, err)
+			}
+		}
+	}
+
+	// Load users.yaml (optional)
+	usersPath := filepath.Join(configDir, "users.yaml")
+	if _, err := os.Stat(usersPath); err == nil {
+		if err := loadConfigFile(usersPath, &cfg.Users); err != nil {
+			return nil, fmt.Errorf("failed to load users.yaml: %w", err)
+		}
+	}
+
+	// Load oncall.yaml (optional)
+	onCallPath := filepath.Join(configDir, "oncall.yaml")
+	if _, err := os.Stat(onCallPath); err == nil {
+		if err := loadConfigFile(onCallPath, &cfg.OnCall); err != nil {
+			return nil, fmt.Errorf("failed to load oncall.yaml: %w", err)
+		}
+		if err := cfg.OnCall.validate(); err != nil {
+			return nil, fmt.Errorf("oncall.yaml: %w", err)
+		}
+	}
+
 	// Load templates.jsonc as default template (required)
 	defaultTemplatesPath := filepath.Join(configDir, "templates.jsonc")
 	var defaultTemplates TemplatesConfig
 	if err := loadConfigFile(defaultTemplatesPath, &defaultTemplates); err != nil {
 		return nil, fmt.Errorf("failed to load templates.jsonc: %w", err)
 	}
+	if err := checkTemplateFormat(defaultTemplates); err != nil {
+		return nil, fmt.Errorf("templates.jsonc: %w", err)
+	}
 	cfg.Templates["default"] = defaultTemplates
 
 	// Load additional template files (templates.*.jsonc)
@@ -144,6 +1035,9 @@ func Load(configDir string) (*Config, error) {
 			if err := loadConfigFile(templatePath, &tmpl); err != nil {
 				return nil, fmt.Errorf("failed to load %s: %w", entry.Name(), err)
 			}
+			if err := checkTemplateFormat(tmpl); err != nil {
+				return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+			}
 			cfg.Templates[templateName] = tmpl
 		}
 	}
@@ -151,20 +1045,140 @@ func Load(configDir string) (*Config, error) {
 	return cfg, nil
 }
 
+// checkTemplateFormat rejects unknown template formats.
+// validateReports checks every report's name, schedule, timezone, period and
+// targets.
+func validateReports(reports []ReportConfig) error {
+	seen := map[string]bool{}
+	for _, r := range reports {
+		if r.Name == "" {
+			return fmt.Errorf("report with schedule %q has no name", r.Schedule)
+		}
+		if seen[r.Name] {
+			return fmt.Errorf("duplicate report name %q", r.Name)
+		}
+		seen[r.Name] = true
+		if _, err := schedule.Parse(r.Schedule); err != nil {
+			return fmt.Errorf("report %s: %w", r.Name, err)
+		}
+		if r.Timezone != "" {
+			if _, err := time.LoadLocation(r.Timezone); err != nil {
+				return fmt.Errorf("report %s: unknown timezone %q", r.Name, r.Timezone)
+			}
+		}
+		if _, err := ParseDuration(r.Period); err != nil {
+			return fmt.Errorf("report %s: %w", r.Name, err)
+		}
+		if len(r.NotifyTo) == 0 {
+			return fmt.Errorf("report %s: notify_to is empty", r.Name)
+		}
+	}
+	return nil
+}
+
+func checkTemplateFormat(t TemplatesConfig) error {
+	switch t.SinkFormat() {
+	case FormatFeishu, FormatDingTalk, FormatWeCom, FormatSlack, FormatTeams, FormatGeneric, FormatEmail:
+		return nil
+	}
+	return fmt.Errorf("unknown template format %q", t.Format)
+}
+
 // GetBotTemplate returns the template name for a given bot alias
-// Returns "default" if the bot doesn't specify a template or if the bot is not found
+// Returns "default" if the bot doesn't specify a template or if the bot is not found.
+// Bots of other sink types (DingTalk, WeCom, Slack, Teams, generic, email) without a template
+// use templates.<type>.jsonc when it exists.
 func (c *Config) GetBotTemplate(botAlias string) string {
 	for _, bot := range c.FeishuBots.FeishuBots {
 		if bot.Alias == botAlias {
 			if bot.Template != "" {
 				return bot.Template
 			}
+			if format := bot.Format(); format != FormatFeishu {
+				if _, ok := c.Templates[format]; ok {
+					return format
+				}
+			}
 			return "default"
 		}
 	}
 	return "default"
 }
 
+// IsEnvelopeTarget reports whether a notify_to alias is a generic or
+// Bitable target that receives the normalized event envelope.
+func (c *Config) IsEnvelopeTarget(botAlias string) bool {
+	for _, bot := range c.FeishuBots.FeishuBots {
+		if bot.Alias == botAlias {
+			return bot.SendsEnvelope()
+		}
+	}
+	return false
+}
+
+// GetBotQuietHours returns the quiet hours of a notify_to target, or nil.
+func (c *Config) GetBotQuietHours(botAlias string) *QuietHours {
+	for _, bot := range c.FeishuBots.FeishuBots {
+		if bot.Alias == botAlias {
+			return bot.QuietHours
+		}
+	}
+	return nil
+}
+
+// GetBotFormat returns the message format accepted by a notify_to target.
+// Direct URLs and inline chat:/user: targets are Feishu.
+func (c *Config) GetBotFormat(botAlias string) string {
+	for _, bot := range c.FeishuBots.FeishuBots {
+		if bot.Alias == botAlias {
+			return bot.Format()
+		}
+	}
+	return FormatFeishu
+}
+
+// LookupUser returns the Feishu mapping for a GitHub login (case-insensitive).
+func (c *Config) LookupUser(login string) (UserMapping, bool) {
+	if login == "" {
+		return UserMapping{}, false
+	}
+	for _, u := range c.Users.Users {
+		if strings.EqualFold(u.GitHub, login) {
+			return u, true
+		}
+	}
+	return UserMapping{}, false
+}
+
+// LookupFeishuUser returns the users.yaml mapping for a Feishu user, matched
+// by open_id or user_id.
+func (c *Config) LookupFeishuUser(openID, userID string) (UserMapping, bool) {
+	for _, u := range c.Users.Users {
+		if (openID != "" && u.OpenID == openID) || (userID != "" && u.UserID == userID) {
+			return u, true
+		}
+	}
+	return UserMapping{}, false
+}
+
+// OperatorAllowed reports whether a Feishu user may act under an
+// allowed_operators list: entries match the open_id, user_id, or the GitHub
+// login / email mapped in users.yaml. An empty list allows everyone.
+func (c *Config) OperatorAllowed(allowed []string, openID, userID string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	user, _ := c.LookupFeishuUser(openID, userID)
+	for _, op := range allowed {
+		for _, candidate := range []string{openID, userID, user.GitHub, user.Email} {
+			if candidate != "" && strings.EqualFold(op, candidate) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // GetTemplateConfig returns the template configuration for a given template name
 // Returns the default template if the specified template is not found
 func (c *Config) GetTemplateConfig(templateName string) TemplatesConfig {
@@ -204,6 +1218,35 @@ func loadConfigFile(path string, out any) error {
 	return yaml.Unmarshal(data, out)
 }
 
+// loadCodeOwners parses a CODEOWNERS-format file: one "<pattern> <owner>..."
+// entry per line, # comments and blank lines ignored. Owners are notify_to
+// targets (bot aliases or URLs); a leading "@" is accepted and stripped so
+// GitHub-style "@team" entries can name bot aliases directly.
+func loadCodeOwners(path string) ([]OwnerRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []OwnerRule
+	for _, line := range strings.Split(string(data), "\n") {
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		rule := OwnerRule{Paths: []string{fields[0]}}
+		for _, owner := range fields[1:] {
+			if owner = strings.TrimPrefix(owner, "@"); owner != "" {
+				rule.NotifyTo = append(rule.NotifyTo, owner)
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
 // offsetToLineCol converts a 1-based byte offset into line and column numbers (1-based)
 func offsetToLineCol(b []byte, offset int64) (int, int) {
 	if offset <= 0 {
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestLoad(t *testing.T) {
@@ -358,3 +359,275 @@ feishu_bots:
 		t.Log("Successfully loaded complete config with real templates")
 	})
 }
+
+// writeTestConfig writes a minimal valid config directory, with files
+// overridden or added by name, and returns its path.
+func writeTestConfig(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	all := map[string]string{
+		"server.yaml":      "server:\n  port: 4594\n",
+		"repos.yaml":       "repos: []\n",
+		"events.yaml":      "events: {}\n",
+		"feishu-bots.yaml": "feishu_bots: []\n",
+		"templates.jsonc":  `{"templates": {}}`,
+	}
+	for name, content := range files {
+		all[name] = content
+	}
+	for name, content := range all {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func TestLoadCodeOwners(t *testing.T) {
+	dir := writeTestConfig(t, map[string]string{
+		"repos.yaml": `
+repos:
+  - pattern: "org/*"
+    notify_to: [all]
+    codeowners: CODEOWNERS
+    owners:
+      - paths: ["/web/"]
+        notify_to: [frontend]
+`,
+		"CODEOWNERS": `
+# comment line
+*           @core
+/deploy/    @sre ops-bot   # trailing comment
+/vendor/
+`,
+	})
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	rules := cfg.Repos.Repos[0].OwnershipRules()
+	if len(rules) != 4 {
+		t.Fatalf("Expected 3 CODEOWNERS entries + 1 inline owner, got %d: %#v", len(rules), rules)
+	}
+	if rules[0].Paths[0] != "*" || rules[0].NotifyTo[0] != "core" {
+		t.Errorf("Unexpected first rule: %#v", rules[0])
+	}
+	if len(rules[1].NotifyTo) != 2 || rules[1].NotifyTo[0] != "sre" || rules[1].NotifyTo[1] != "ops-bot" {
+		t.Errorf("Expected @ stripped from owners, got %#v", rules[1].NotifyTo)
+	}
+	if len(rules[2].NotifyTo) != 0 {
+		t.Errorf("Expected ownerless entry, got %#v", rules[2].NotifyTo)
+	}
+	if rules[3].NotifyTo[0] != "frontend" {
+		t.Errorf("Expected inline owners last, got %#v", rules[3])
+	}
+
+	if _, err := Load(writeTestConfig(t, map[string]string{
+		"repos.yaml": "repos:\n  - pattern: \"*\"\n    codeowners: missing\n",
+	})); err == nil {
+		t.Error("Expected error for missing codeowners file")
+	}
+}
+
+func TestLoadUsers(t *testing.T) {
+	// users.yaml is optional
+	cfg, err := Load(writeTestConfig(t, nil))
+	if err != nil {
+		t.Fatalf("Failed to load config without users.yaml: %v", err)
+	}
+	if _, ok := cfg.LookupUser("alice"); ok {
+		t.Error("Expected no user mapping without users.yaml")
+	}
+
+	cfg, err = Load(writeTestConfig(t, map[string]string{
+		"users.yaml": "users:\n  - github: Alice\n    open_id: ou_1\nmentions:\n  assigned: true\n",
+	}))
+	if err != nil {
+		t.Fatalf("Failed to load users.yaml: %v", err)
+	}
+	if u, ok := cfg.LookupUser("alice"); !ok || u.OpenID != "ou_1" {
+		t.Errorf("LookupUser(alice) = %#v, %v", u, ok)
+	}
+	if !cfg.Users.Mentions.Assigned || cfg.Users.Mentions.ReviewRequested {
+		t.Errorf("Unexpected mentions config: %#v", cfg.Users.Mentions)
+	}
+}
+
+func TestLoadReports(t *testing.T) {
+	cfg, err := Load(writeTestConfig(t, map[string]string{
+		"server.yaml": "reports:\n  - name: weekly\n    schedule: \"0 9 * * 1\"\n    timezone: Asia/Shanghai\n    period: 7d\n    notify_to: [managers]\n",
+	}))
+	if err != nil {
+		t.Fatalf("Failed to load reports: %v", err)
+	}
+	r := cfg.Server.Reports[0]
+	if r.PeriodDuration() != 7*24*time.Hour || r.Location().String() != "Asia/Shanghai" || r.Cron() == nil {
+		t.Errorf("Unexpected report: period=%v location=%v", r.PeriodDuration(), r.Location())
+	}
+
+	for _, report := range []string{
+		"name: bad\n    schedule: \"0 9 * *\"\n    notify_to: [a]",
+		"name: bad\n    schedule: \"@daily\"\n    timezone: Mars/Olympus\n    notify_to: [a]",
+		"name: bad\n    schedule: \"@daily\"\n    period: 1w\n    notify_to: [a]",
+		"name: bad\n    schedule: \"@daily\"",
+		"schedule: \"@daily\"\n    notify_to: [a]",
+	} {
+		if _, err := Load(writeTestConfig(t, map[string]string{"server.yaml": "reports:\n  - " + report + "\n"})); err == nil {
+			t.Errorf("Expected error for report %q", report)
+		}
+	}
+}
+
+func TestTimeWindowContains(t *testing.T) {
+	office := TimeWindow{Start: "09:00", End: "18:00", Days: []string{"mon", "tue", "wed", "thu", "fri"}, Timezone: "Asia/Shanghai"}
+	night := TimeWindow{Start: "22:00", End: "07:00", Days: []string{"fri"}, Timezone: "UTC"}
+	shanghai := time.FixedZone("CST", 8*3600)
+	tests := []struct {
+		window *TimeWindow
+		at     time.Time
+		want   bool
+	}{
+		{&office, time.Date(2026, 1, 5, 9, 0, 0, 0, shanghai), true},                                     // Monday
+		{&office, time.Date(2026, 1, 5, 18, 0, 0, 0, shanghai), false},                                   // end is exclusive
+		{&office, time.Date(2026, 1, 5, 2, 0, 0, 0, time.UTC), true},                                     // 10:00 in Shanghai
+		{&office, time.Date(2026, 1, 10, 10, 0, 0, 0, shanghai), false},                                  // Saturday
+		{&night, time.Date(2026, 1, 9, 23, 0, 0, 0, time.UTC), true},                                     // Friday night
+		{&night, time.Date(2026, 1, 10, 6, 59, 0, 0, time.UTC), true},                                    // Friday's window, Saturday morning
+		{&night, time.Date(2026, 1, 9, 6, 0, 0, 0, time.UTC), false},                                     // Thursday's window
+		{&TimeWindow{Start: "00:00", End: "23:59"}, time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC), true}, // every day
+	}
+	for i, tt := range tests {
+		if got := tt.window.Contains(tt.at); got != tt.want {
+			t.Errorf("case %d: Contains(%v) = %v, want %v", i, tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestLoadStalePRs(t *testing.T) {
+	cfg, err := Load(writeTestConfig(t, map[string]string{
+		"repos.yaml": "repos:\n  - pattern: \"org/*\"\n    stale_prs:\n      after: 2d\n      working_hours: {start: \"09:00\", end: \"18:00\", days: [mon, fri]}\n",
+	}))
+	if err != nil {
+		t.Fatalf("Failed to load stale_prs: %v", err)
+	}
+	s := cfg.Repos.Repos[0].StalePRs
+	if s.AfterDuration() != 48*time.Hour || s.RepeatDuration() != 48*time.Hour {
+		t.Errorf("after=%v repeat=%v", s.AfterDuration(), s.RepeatDuration())
+	}
+	for _, stale := range []string{"{after: soon}", "{after: 1h, repeat: x}", "{after: 1h, working_hours: {start: \"9\", end: \"18:00\"}}", "{after: 1h, working_hours: {start: \"09:00\", end: \"18:00\", days: [someday]}}"} {
+		if _, err := Load(writeTestConfig(t, map[string]string{"repos.yaml": "repos:\n  - pattern: \"*\"\n    stale_prs: " + stale + "\n"})); err == nil {
+			t.Errorf("Expected error for stale_prs %s", stale)
+		}
+	}
+}
+
+func TestLoadCommitStatus(t *testing.T) {
+	cfg, err := Load(writeTestConfig(t, map[string]string{
+		"repos.yaml": "repos:\n  - pattern: \"org/*\"\n    commit_status: {}\n",
+	}))
+	if err != nil {
+		t.Fatalf("Failed to load commit_status: %v", err)
+	}
+	rule := cfg.Repos.Repos[0]
+	if rule.CommitStatus.TimeoutDuration() != 30*time.Minute || !rule.CoalescesCommitStatus("workflow_job") || rule.CoalescesCommitStatus("push") {
+		t.Errorf("commit_status defaults = %+v", rule.CommitStatus)
+	}
+	for _, status := range []string{"{timeout: soon}", "{events: [push]}"} {
+		if _, err := Load(writeTestConfig(t, map[string]string{"repos.yaml": "repos:\n  - pattern: \"*\"\n    commit_status: " + status + "\n"})); err == nil {
+			t.Errorf("Expected error for commit_status %s", status)
+		}
+	}
+}
+
+func TestLoadQuietHours(t *testing.T) {
+	cfg, err := Load(writeTestConfig(t, map[string]string{
+		"feishu-bots.yaml": "feishu_bots:\n  - alias: eu\n    url: https://example.com/hook\n    quiet_hours: {start: \"22:00\", end: \"08:00\", timezone: Europe/Berlin, bypass: [severity:critical]}\n",
+	}))
+	if err != nil {
+		t.Fatalf("Failed to load quiet_hours: %v", err)
+	}
+	quiet := cfg.GetBotQuietHours("eu")
+	if quiet == nil || quiet.Timezone != "Europe/Berlin" {
+		t.Fatalf("quiet hours = %+v", quiet)
+	}
+	night := time.Date(2026, 1, 1, 2, 0, 0, 0, time.UTC) // 03:00 in Berlin
+	if !quiet.Contains(night) || quiet.Contains(night.Add(8*time.Hour)) {
+		t.Errorf("Contains() does not follow the bot's timezone")
+	}
+	if !quiet.Bypassed("dependabot_alert", []string{"created", "severity:critical"}) || quiet.Bypassed("push", []string{"push"}) {
+		t.Errorf("Bypassed() = wrong result")
+	}
+	if _, err := Load(writeTestConfig(t, map[string]string{
+		"repos.yaml": "repos:\n  - pattern: \"*\"\n    quiet_hours: {start: \"22\", end: \"08:00\"}\n",
+	})); err == nil {
+		t.Errorf("Expected error for an invalid rule quiet_hours")
+	}
+}
+
+func TestOnCallSchedule(t *testing.T) {
+	cfg, err := Load(writeTestConfig(t, map[string]string{
+		"oncall.yaml": `
+schedules:
+  - name: security
+    rotation: weekly
+    start: "2026-01-05 09:00"
+    timezone: Asia/Shanghai
+    members: [alice, bob, carol]
+    overrides:
+      - user: dave
+        start: "2026-01-20 00:00"
+        end: "2026-01-21 00:00"
+`,
+	}))
+	if err != nil {
+		t.Fatalf("Failed to load oncall.yaml: %v", err)
+	}
+	s := cfg.OnCall.Schedule("security")
+	loc := s.Location()
+	at := func(v string) time.Time {
+		tm, _ := time.ParseInLocation("2006-01-02 15:04", v, loc)
+		return tm
+	}
+	for _, tc := range []struct {
+		at   string
+		want string
+	}{
+		{"2026-01-05 09:00", "alice"},
+		{"2026-01-12 08:59", "alice"},
+		{"2026-01-12 09:00", "bob"},
+		{"2026-01-20 12:00", "dave"},
+		{"2026-01-26 10:00", "alice"},
+		{"2026-01-05 08:00", "carol"}, // before the start, counting backwards
+	} {
+		if got, _ := s.OnCall(at(tc.at)); got != tc.want {
+			t.Errorf("OnCall(%s) = %s, want %s", tc.at, got, tc.want)
+		}
+	}
+
+	shifts := s.Shifts(at("2026-01-13 10:00"), 4)
+	want := []OnCallShift{
+		{User: "bob", Start: at("2026-01-12 09:00"), End: at("2026-01-19 09:00")},
+		{User: "carol", Start: at("2026-01-19 09:00"), End: at("2026-01-20 00:00")},
+		{User: "dave", Start: at("2026-01-20 00:00"), End: at("2026-01-21 00:00"), Override: true},
+		{User: "carol", Start: at("2026-01-21 00:00"), End: at("2026-01-26 09:00")},
+	}
+	if len(shifts) != len(want) {
+		t.Fatalf("Shifts() = %+v", shifts)
+	}
+	for i := range want {
+		if shifts[i].User != want[i].User || !shifts[i].Start.Equal(want[i].Start) || !shifts[i].End.Equal(want[i].End) || shifts[i].Override != want[i].Override {
+			t.Errorf("Shifts()[%d] = %+v, want %+v", i, shifts[i], want[i])
+		}
+	}
+
+	for _, bad := range []string{
+		"schedules:\n  - {name: a, rotation: monthly, start: \"2026-01-05 09:00\", members: [x]}\n",
+		"schedules:\n  - {name: a, rotation: daily, start: \"2026-01-05\", members: [x]}\n",
+		"schedules:\n  - {name: a, rotation: daily, start: \"2026-01-05 09:00\", members: []}\n",
+	} {
+		if _, err := Load(writeTestConfig(t, map[string]string{"oncall.yaml": bad})); err == nil {
+			t.Errorf("Expected error for oncall.yaml %q", bad)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package feishu
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// bitableRecordsPath is the records collection of one Bitable table.
+func bitableRecordsPath(appToken, tableID string) string {
+	return "/open-apis/bitable/v1/apps/" + url.PathEscape(appToken) + "/tables/" + url.PathEscape(tableID) + "/records"
+}
+
+// FindRecord returns the record_id of the first record in the table whose
+// field equals value, or "" when there is none.
+func (c *Client) FindRecord(appToken, tableID, field, value string) (string, error) {
+	var out struct {
+		Items []struct {
+			RecordID string `json:"record_id"`
+		} `json:"items"`
+	}
+	body := map[string]any{
+		"filter": map[string]any{
+			"conjunction": "and",
+			"conditions": []map[string]any{
+				{"field_name": field, "operator": "is", "value": []string{value}},
+			},
+		},
+	}
+	query := url.Values{"page_size": {"1"}}
+	if err := c.Do("POST", bitableRecordsPath(appToken, tableID)+"/search", query, body, &out); err != nil {
+		return "", err
+	}
+	if len(out.Items) == 0 {
+		return "", nil
+	}
+	return out.Items[0].RecordID, nil
+}
+
+// CreateRecord adds a record with the given field values and returns its
+// record_id.
+func (c *Client) CreateRecord(appToken, tableID string, fields map[string]any) (string, error) {
+	var out struct {
+		Record struct {
+			RecordID string `json:"record_id"`
+		} `json:"record"`
+	}
+	body := map[string]any{"fields": fields}
+	if err := c.Do("POST", bitableRecordsPath(appToken, tableID), nil, body, &out); err != nil {
+		return "", err
+	}
+	if out.Record.RecordID == "" {
+		return "", fmt.Errorf("bitable create returned no record_id")
+	}
+	return out.Record.RecordID, nil
+}
+
+// UpdateRecord overwrites the given fields of an existing record; fields not
+// listed keep their values.
+func (c *Client) UpdateRecord(appToken, tableID, recordID string, fields map[string]any) error {
+	body := map[string]any{"fields": fields}
+	return c.Do("PUT", bitableRecordsPath(appToken, tableID)+"/"+url.PathEscape(recordID), nil, body, nil)
+}
@@ -0,0 +1,294 @@
+package feishu
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxCallbackSkew is how far a signed callback's X-Lark-Request-Timestamp
+// may be from the local clock before it is rejected as a replay.
+const maxCallbackSkew = 5 * time.Minute
+
+// maxCallbackSize bounds callback bodies; events and card actions are small.
+const maxCallbackSize = 1 << 20
+
+// Errors returned by ReadCallback for requests that fail authentication.
+var (
+	ErrInvalidSignature = errors.New("invalid callback signature")
+	ErrInvalidToken     = errors.New("callback verification token mismatch")
+)
+
+// ReadCallback reads, authenticates and decodes a callback request: the
+// X-Lark-Signature is checked when present, encrypted bodies are decrypted,
+// and the verification token in the body must match. Authentication
+// failures wrap ErrInvalidSignature or ErrInvalidToken.
+func ReadCallback(r *http.Request, verificationToken, encryptKey string, now time.Time) (*Callback, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxCallbackSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read callback body: %w", err)
+	}
+	if r.Header.Get("X-Lark-Signature") != "" && !VerifySignature(r.Header, body, verificationToken, encryptKey, now) {
+		return nil, ErrInvalidSignature
+	}
+	cb, err := ParseCallback(body, encryptKey)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(cb.Token), []byte(verificationToken)) != 1 {
+		return nil, ErrInvalidToken
+	}
+	return cb, nil
+}
+
+// Callback is a request sent by Feishu to an app's event or card callback
+// URL, after decryption. Both the legacy card format and the 2.0 event
+// schema are understood.
+type Callback struct {
+	Type      string // "url_verification" for the URL check, else ""
+	Challenge string // echoed back for url_verification
+	Token     string // verification token carried in the body
+	EventType string // 2.0 header.event_type, e.g. "card.action.trigger"
+	EventID   string
+	Event     json.RawMessage // 2.0 "event"; nil for legacy card callbacks
+	Schema2   bool
+
+	body []byte // the decrypted body, for legacy card callbacks
+}
+
+// ParseCallback decodes a callback body, decrypting {"encrypt": "..."}
+// bodies with encryptKey.
+func ParseCallback(body []byte, encryptKey string) (*Callback, error) {
+	var encrypted struct {
+		Encrypt string `json:"encrypt"`
+	}
+	if err := json.Unmarshal(body, &encrypted); err != nil {
+		return nil, fmt.Errorf("invalid callback body: %w", err)
+	}
+	if encrypted.Encrypt != "" {
+		if encryptKey == "" {
+			return nil, fmt.Errorf("encrypted callback but no encrypt_key configured")
+		}
+		plain, err := Decrypt(encrypted.Encrypt, encryptKey)
+		if err != nil {
+			return nil, err
+		}
+		body = plain
+	}
+
+	var raw struct {
+		Type      string `json:"type"`
+		Challenge string `json:"challenge"`
+		Token     string `json:"token"`
+		Schema    string `json:"schema"`
+		Header    struct {
+			EventID   string `json:"event_id"`
+			EventType string `json:"event_type"`
+			Token     string `json:"token"`
+		} `json:"header"`
+		Event json.RawMessage `json:"event"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("invalid callback body: %w", err)
+	}
+	cb := &Callback{Type: raw.Type, Challenge: raw.Challenge, Token: raw.Token, body: body}
+	if raw.Schema == "2.0" {
+		cb.Schema2 = true
+		cb.Token = raw.Header.Token
+		cb.EventType = raw.Header.EventType
+		cb.EventID = raw.Header.EventID
+		cb.Event = raw.Event
+	}
+	return cb, nil
+}
+
+// Decrypt decrypts an "encrypt" field: AES-256-CBC with the SHA-256 of the
+// encrypt key, the IV prepended to the ciphertext, PKCS#7 padded.
+func Decrypt(encrypted, encryptKey string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encrypted body: %w", err)
+	}
+	if len(data) < 2*aes.BlockSize || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("invalid encrypted body length %d", len(data))
+	}
+	key := sha256.Sum256([]byte(encryptKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	iv, plain := data[:aes.BlockSize], make([]byte, len(data)-aes.BlockSize)
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data[aes.BlockSize:])
+	pad := int(plain[len(plain)-1])
+	if pad == 0 || pad > aes.BlockSize || pad > len(plain) {
+		return nil, fmt.Errorf("invalid padding in encrypted body")
+	}
+	return plain[:len(plain)-pad], nil
+}
+
+// VerifySignature checks the X-Lark-Signature of a callback against the raw
+// body: sha256(timestamp+nonce+encryptKey+body) for event callbacks and
+// sha1(timestamp+nonce+verificationToken+body) for legacy card callbacks.
+// Stale timestamps are rejected.
+func VerifySignature(header http.Header, body []byte, verificationToken, encryptKey string, now time.Time) bool {
+	signature := header.Get("X-Lark-Signature")
+	timestamp := header.Get("X-Lark-Request-Timestamp")
+	nonce := header.Get("X-Lark-Request-Nonce")
+	if signature == "" || timestamp == "" {
+		return false
+	}
+	if ts, err := strconv.ParseInt(timestamp, 10, 64); err != nil || now.Sub(time.Unix(ts, 0)).Abs() > maxCallbackSkew {
+		return false
+	}
+	if encryptKey != "" {
+		sum := sha256.Sum256([]byte(timestamp + nonce + encryptKey + string(body)))
+		if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(signature)) == 1 {
+			return true
+		}
+	}
+	if verificationToken != "" {
+		sum := sha1.Sum([]byte(timestamp + nonce + verificationToken + string(body)))
+		if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(signature)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// CardAction is a button (or other interactive element) click on a card.
+type CardAction struct {
+	OpenID    string // operator
+	UserID    string
+	MessageID string // the card's message
+	ChatID    string
+	Tag       string         // element tag, e.g. "button"
+	Value     map[string]any // the element's "value" from the card JSON
+	Option    string         // selected option for select menus
+}
+
+// CardAction decodes the card action of a legacy card callback or a 2.0
+// card.action.trigger event.
+func (cb *Callback) CardAction() (*CardAction, error) {
+	type action struct {
+		Tag    string         `json:"tag"`
+		Value  map[string]any `json:"value"`
+		Option string         `json:"option"`
+	}
+	if cb.Schema2 {
+		if cb.EventType != "card.action.trigger" {
+			return nil, fmt.Errorf("unexpected event type %q", cb.EventType)
+		}
+		var ev struct {
+			Operator struct {
+				OpenID string `json:"open_id"`
+				UserID string `json:"user_id"`
+			} `json:"operator"`
+			Action  action `json:"action"`
+			Context struct {
+				OpenMessageID string `json:"open_message_id"`
+				OpenChatID    string `json:"open_chat_id"`
+			} `json:"context"`
+		}
+		if err := json.Unmarshal(cb.Event, &ev); err != nil {
+			return nil, fmt.Errorf("invalid card action: %w", err)
+		}
+		return &CardAction{
+			OpenID: ev.Operator.OpenID, UserID: ev.Operator.UserID,
+			MessageID: ev.Context.OpenMessageID, ChatID: ev.Context.OpenChatID,
+			Tag: ev.Action.Tag, Value: ev.Action.Value, Option: ev.Action.Option,
+		}, nil
+	}
+
+	var legacy struct {
+		OpenID        string `json:"open_id"`
+		UserID        string `json:"user_id"`
+		OpenMessageID string `json:"open_message_id"`
+		OpenChatID    string `json:"open_chat_id"`
+		Action        action `json:"action"`
+	}
+	if err := json.Unmarshal(cb.body, &legacy); err != nil {
+		return nil, fmt.Errorf("invalid card action: %w", err)
+	}
+	return &CardAction{
+		OpenID: legacy.OpenID, UserID: legacy.UserID,
+		MessageID: legacy.OpenMessageID, ChatID: legacy.OpenChatID,
+		Tag: legacy.Action.Tag, Value: legacy.Action.Value, Option: legacy.Action.Option,
+	}, nil
+}
+
+// MessageEvent is an im.message.receive_v1 event: a message sent to the app
+// in a private chat, or one that @mentions it in a group.
+type MessageEvent struct {
+	OpenID      string // sender
+	UserID      string
+	SenderType  string // "user" or "app"
+	MessageID   string
+	ChatID      string
+	ChatType    string // "p2p" or "group"
+	MessageType string // "text", "post", ...
+	Text        string // text messages only, with @mention placeholders removed
+}
+
+// MessageEvent decodes an im.message.receive_v1 event.
+func (cb *Callback) MessageEvent() (*MessageEvent, error) {
+	if cb.EventType != "im.message.receive_v1" {
+		return nil, fmt.Errorf("unexpected event type %q", cb.EventType)
+	}
+	var ev struct {
+		Sender struct {
+			SenderID struct {
+				OpenID string `json:"open_id"`
+				UserID string `json:"user_id"`
+			} `json:"sender_id"`
+			SenderType string `json:"sender_type"`
+		} `json:"sender"`
+		Message struct {
+			MessageID   string `json:"message_id"`
+			ChatID      string `json:"chat_id"`
+			ChatType    string `json:"chat_type"`
+			MessageType string `json:"message_type"`
+			Content     string `json:"content"`
+			Mentions    []struct {
+				Key string `json:"key"`
+			} `json:"mentions"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal(cb.Event, &ev); err != nil {
+		return nil, fmt.Errorf("invalid message event: %w", err)
+	}
+	msg := &MessageEvent{
+		OpenID:      ev.Sender.SenderID.OpenID,
+		UserID:      ev.Sender.SenderID.UserID,
+		SenderType:  ev.Sender.SenderType,
+		MessageID:   ev.Message.MessageID,
+		ChatID:      ev.Message.ChatID,
+		ChatType:    ev.Message.ChatType,
+		MessageType: ev.Message.MessageType,
+	}
+	if msg.MessageType == "text" {
+		var content struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal([]byte(ev.Message.Content), &content); err != nil {
+			return nil, fmt.Errorf("invalid text message content: %w", err)
+		}
+		text := content.Text
+		for _, m := range ev.Message.Mentions {
+			text = strings.ReplaceAll(text, m.Key, "")
+		}
+		msg.Text = strings.TrimSpace(text)
+	}
+	return msg, nil
+}
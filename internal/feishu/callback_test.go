@@ -0,0 +1,79 @@
+package feishu
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// encrypt mirrors Feishu's callback encryption for tests.
+func encrypt(t *testing.T, plain, key string) string {
+	t.Helper()
+	k := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(k[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pad := aes.BlockSize - len(plain)%aes.BlockSize
+	data := append([]byte(plain), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	iv := []byte("0123456789abcdef")
+	out := make([]byte, len(data))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, data)
+	return base64.StdEncoding.EncodeToString(append(iv, out...))
+}
+
+func TestParseCallbackEncrypted(t *testing.T) {
+	plain := `{"schema":"2.0","header":{"event_type":"card.action.trigger","token":"vt"},` +
+		`"event":{"operator":{"open_id":"ou_1"},"action":{"tag":"button","value":{"action":"x"}},"context":{"open_message_id":"om_1"}}}`
+	body := []byte(`{"encrypt":"` + encrypt(t, plain, "ek") + `"}`)
+
+	cb, err := ParseCallback(body, "ek")
+	if err != nil {
+		t.Fatalf("ParseCallback() error = %v", err)
+	}
+	if !cb.Schema2 || cb.Token != "vt" || cb.EventType != "card.action.trigger" {
+		t.Fatalf("unexpected callback: %+v", cb)
+	}
+	action, err := cb.CardAction()
+	if err != nil {
+		t.Fatalf("CardAction() error = %v", err)
+	}
+	if action.OpenID != "ou_1" || action.MessageID != "om_1" || action.Value["action"] != "x" {
+		t.Fatalf("unexpected action: %+v", action)
+	}
+
+	if _, err := ParseCallback(body, ""); err == nil {
+		t.Fatal("expected error without encrypt key")
+	}
+	if _, err := ParseCallback(body, "wrong"); err == nil {
+		t.Fatal("expected error with wrong encrypt key")
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"a":1}`)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	sum := sha256.Sum256([]byte(ts + "n" + "ek" + string(body)))
+	header := http.Header{}
+	header.Set("X-Lark-Request-Timestamp", ts)
+	header.Set("X-Lark-Request-Nonce", "n")
+	header.Set("X-Lark-Signature", hex.EncodeToString(sum[:]))
+
+	if !VerifySignature(header, body, "vt", "ek", now) {
+		t.Fatal("expected valid event signature")
+	}
+	if VerifySignature(header, []byte(`{"a":2}`), "vt", "ek", now) {
+		t.Fatal("expected tampered body to fail")
+	}
+	if VerifySignature(header, body, "vt", "ek", now.Add(10*time.Minute)) {
+		t.Fatal("expected stale timestamp to fail")
+	}
+}
@@ -0,0 +1,208 @@
+// Package feishu is a small client for the Feishu (Lark) Open API, used by the
+// app-bot and Bitable delivery modes and by issue task sync:
+// tenant_access_token management plus the im/v1 message, bitable/v1 record
+// and task/v2 endpoints. The base URL is configurable so the client can be
+// pointed at Lark (open.larksuite.com) or at a local mock server in tests.
+package feishu
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBaseURL is the Feishu Open API origin used when none is configured.
+const DefaultBaseURL = "https://open.feishu.cn"
+
+// tokenRefreshMargin refreshes the cached tenant_access_token this long before
+// it actually expires, so in-flight requests never race the expiry.
+const tokenRefreshMargin = 5 * time.Minute
+
+// Open API error codes meaning the tenant_access_token is missing or invalid;
+// the request is retried once with a fresh token.
+var invalidTokenCodes = map[int]bool{99991661: true, 99991663: true}
+
+// Client calls the Feishu Open API on behalf of one app (app_id/app_secret).
+// It is safe for concurrent use.
+type Client struct {
+	appID     string
+	appSecret string
+	baseURL   string
+	http      *http.Client
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+	now       func() time.Time
+}
+
+// NewClient creates a Client. An empty baseURL uses DefaultBaseURL and a nil
+// httpClient uses a client with a 15s timeout.
+func NewClient(appID, appSecret, baseURL string, httpClient *http.Client) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 15 * time.Second}
+	}
+	return &Client{
+		appID:     appID,
+		appSecret: appSecret,
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		http:      httpClient,
+		now:       time.Now,
+	}
+}
+
+// Is reports whether c is the client NewClient would create for these
+// credentials, so callers can keep it (and its cached token) across config
+// reloads.
+func (c *Client) Is(appID, appSecret, baseURL string) bool {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return c.appID == appID && c.appSecret == appSecret && c.baseURL == strings.TrimRight(baseURL, "/")
+}
+
+// APIError is a non-zero `code` returned in an Open API response envelope.
+type APIError struct {
+	Code int
+	Msg  string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("feishu api error %d: %s", e.Code, e.Msg)
+}
+
+// TenantAccessToken returns the cached tenant_access_token, fetching a new
+// one when there is none or it is about to expire.
+func (c *Client) TenantAccessToken() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && c.now().Before(c.expiresAt.Add(-tokenRefreshMargin)) {
+		return c.token, nil
+	}
+
+	body, err := json.Marshal(map[string]string{"app_id": c.appID, "app_secret": c.appSecret})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal token request: %w", err)
+	}
+	resp, err := c.http.Post(c.baseURL+"/open-apis/auth/v3/tenant_access_token/internal", "application/json; charset=utf-8", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to request tenant_access_token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var out struct {
+		Code              int    `json:"code"`
+		Msg               string `json:"msg"`
+		TenantAccessToken string `json:"tenant_access_token"`
+		Expire            int    `json:"expire"`
+	}
+	if err := decodeResponse(resp, &out); err != nil {
+		return "", fmt.Errorf("failed to request tenant_access_token: %w", err)
+	}
+	if out.Code != 0 {
+		return "", &APIError{Code: out.Code, Msg: out.Msg}
+	}
+	if out.TenantAccessToken == "" {
+		return "", fmt.Errorf("empty tenant_access_token in response")
+	}
+
+	c.token = out.TenantAccessToken
+	c.expiresAt = c.now().Add(time.Duration(out.Expire) * time.Second)
+	return c.token, nil
+}
+
+// invalidateToken drops the cached token so the next call fetches a new one.
+func (c *Client) invalidateToken() {
+	c.mu.Lock()
+	c.token = ""
+	c.mu.Unlock()
+}
+
+// Do performs an authenticated Open API call and decodes the envelope's
+// `data` into out (which may be nil). A non-zero `code` is returned as
+// *APIError; invalid-token errors are retried once with a fresh token.
+func (c *Client) Do(method, path string, query url.Values, body any, out any) error {
+	err := c.do(method, path, query, body, out)
+	if apiErr, ok := err.(*APIError); ok && invalidTokenCodes[apiErr.Code] {
+		c.invalidateToken()
+		err = c.do(method, path, query, body, out)
+	}
+	return err
+}
+
+func (c *Client) do(method, path string, query url.Values, body any, out any) error {
+	token, err := c.TenantAccessToken()
+	if err != nil {
+		return err
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		Code int             `json:"code"`
+		Msg  string          `json:"msg"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := decodeResponse(resp, &envelope); err != nil {
+		return err
+	}
+	if envelope.Code != 0 {
+		return &APIError{Code: envelope.Code, Msg: envelope.Msg}
+	}
+	if out != nil && len(envelope.Data) > 0 {
+		if err := json.Unmarshal(envelope.Data, out); err != nil {
+			return fmt.Errorf("failed to decode response data: %w", err)
+		}
+	}
+	return nil
+}
+
+// decodeResponse decodes a JSON response body. Open API errors usually come
+// with a JSON envelope even on 4xx, so the body is decoded before the status
+// is judged; non-JSON non-2xx responses are reported with their raw body.
+func decodeResponse(resp *http.Response, out any) error {
+	data, _ := io.ReadAll(resp.Body)
+	if err := json.Unmarshal(data, out); err != nil {
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("received non-2xx status code %d: %s", resp.StatusCode, string(data))
+		}
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package feishu
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockOpenAPI is a minimal stand-in for the Feishu Open API.
+type mockOpenAPI struct {
+	mu           sync.Mutex
+	tokenCalls   int
+	tokens       []string // tokens handed out in order
+	validToken   string
+	messages     []map[string]string
+	receiveTypes []string
+}
+
+func (m *mockOpenAPI) handler(t *testing.T) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/open-apis/auth/v3/tenant_access_token/internal", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req["app_id"] != "cli_test" || req["app_secret"] != "secret" {
+			_ = json.NewEncoder(w).Encode(map[string]any{"code": 10014, "msg": "app secret invalid"})
+			return
+		}
+		m.mu.Lock()
+		token := m.tokens[m.tokenCalls]
+		m.tokenCalls++
+		m.mu.Unlock()
+		_ = json.NewEncoder(w).Encode(map[string]any{"code": 0, "msg": "ok", "tenant_access_token": token, "expire": 7200})
+	})
+	mux.HandleFunc("/open-apis/im/v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer "+m.validToken {
+			_ = json.NewEncoder(w).Encode(map[string]any{"code": 99991663, "msg": "Invalid access token for authorization"})
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var msg map[string]string
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Errorf("invalid message body: %v", err)
+		}
+		m.messages = append(m.messages, msg)
+		m.receiveTypes = append(m.receiveTypes, r.URL.Query().Get("receive_id_type"))
+		_ = json.NewEncoder(w).Encode(map[string]any{"code": 0, "msg": "success", "data": map[string]any{"message_id": "om_1"}})
+	})
+	return mux
+}
+
+func TestClientTokenCachingAndRefresh(t *testing.T) {
+	mock := &mockOpenAPI{tokens: []string{"t-1", "t-2", "t-3"}, validToken: "t-1"}
+	srv := httptest.NewServer(mock.handler(t))
+	defer srv.Close()
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewClient("cli_test", "secret", srv.URL, srv.Client())
+	c.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		id, err := c.SendMessage(ReceiveIDChat, "oc_1", "text", `{"text":"hi"}`)
+		if err != nil {
+			t.Fatalf("SendMessage() error = %v", err)
+		}
+		if id != "om_1" {
+			t.Fatalf("message_id = %q, want om_1", id)
+		}
+	}
+	if mock.tokenCalls != 1 {
+		t.Fatalf("token fetched %d times, want 1 (cached)", mock.tokenCalls)
+	}
+
+	// Close to expiry the token is refreshed proactively.
+	now = now.Add(7200*time.Second - tokenRefreshMargin + time.Second)
+	mock.validToken = "t-2"
+	if _, err := c.SendMessage(ReceiveIDChat, "oc_1", "text", `{"text":"hi"}`); err != nil {
+		t.Fatalf("SendMessage() after expiry error = %v", err)
+	}
+	if mock.tokenCalls != 2 {
+		t.Fatalf("token fetched %d times, want 2 (refreshed)", mock.tokenCalls)
+	}
+
+	// A token revoked server-side is replaced and the call retried once.
+	mock.validToken = "t-3"
+	if _, err := c.SendMessage(ReceiveIDOpen, "ou_1", "text", `{"text":"hi"}`); err != nil {
+		t.Fatalf("SendMessage() with revoked token error = %v", err)
+	}
+	if mock.tokenCalls != 3 {
+		t.Fatalf("token fetched %d times, want 3 (retried)", mock.tokenCalls)
+	}
+	if len(mock.messages) != 4 || mock.messages[3]["receive_id"] != "ou_1" || mock.receiveTypes[3] != ReceiveIDOpen {
+		t.Fatalf("unexpected messages: %v %v", mock.messages, mock.receiveTypes)
+	}
+}
+
+func TestClientAPIError(t *testing.T) {
+	mock := &mockOpenAPI{tokens: []string{"t-1"}}
+	srv := httptest.NewServer(mock.handler(t))
+	defer srv.Close()
+
+	c := NewClient("cli_test", "wrong", srv.URL, srv.Client())
+	_, err := c.SendMessage(ReceiveIDChat, "oc_1", "text", `{}`)
+	apiErr, ok := err.(*APIError)
+	if !ok || apiErr.Code != 10014 {
+		t.Fatalf("expected APIError 10014, got %v", err)
+	}
+}
+
+func TestParseReceiveTarget(t *testing.T) {
+	tests := []struct {
+		target, idType, id string
+		ok                 bool
+	}{
+		{"chat:oc_abc", ReceiveIDChat, "oc_abc", true},
+		{"user:alice@corp.example", ReceiveIDEmail, "alice@corp.example", true},
+		{"user:ou_123", ReceiveIDOpen, "ou_123", true},
+		{"user:on_123", ReceiveIDUnion, "on_123", true},
+		{"user:5d9a", ReceiveIDUser, "5d9a", true},
+		{"open_id:ou_9", ReceiveIDOpen, "ou_9", true},
+		{"https://open.feishu.cn/open-apis/bot/v2/hook/x", "", "", false},
+		{"dev-team", "", "", false},
+		{"chat:", "", "", false},
+	}
+	for _, tt := range tests {
+		idType, id, ok := ParseReceiveTarget(tt.target)
+		if idType != tt.idType || id != tt.id || ok != tt.ok {
+			t.Errorf("ParseReceiveTarget(%q) = %q, %q, %v; want %q, %q, %v", tt.target, idType, id, ok, tt.idType, tt.id, tt.ok)
+		}
+	}
+}
+
+func TestConvertWebhookPayload(t *testing.T) {
+	msgType, content, err := ConvertWebhookPayload(map[string]any{
+		"msg_type": "interactive",
+		"card":     map[string]any{"header": map[string]any{"title": "x"}},
+	})
+	if err != nil || msgType != "interactive" || content != `{"header":{"title":"x"}}` {
+		t.Fatalf("interactive: %q %q %v", msgType, content, err)
+	}
+
+	msgType, content, err = ConvertWebhookPayload(map[string]any{
+		"msg_type": "post",
+		"content":  map[string]any{"post": map[string]any{"zh_cn": map[string]any{"title": "t"}}},
+	})
+	if err != nil || msgType != "post" || content != `{"zh_cn":{"title":"t"}}` {
+		t.Fatalf("post: %q %q %v", msgType, content, err)
+	}
+
+	if _, _, err := ConvertWebhookPayload(map[string]any{"card": map[string]any{}}); err == nil {
+		t.Fatal("expected error for payload without msg_type")
+	}
+}
@@ -0,0 +1,133 @@
+package feishu
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Receive ID types accepted by the im/v1 message API.
+const (
+	ReceiveIDChat  = "chat_id"
+	ReceiveIDOpen  = "open_id"
+	ReceiveIDUser  = "user_id"
+	ReceiveIDUnion = "union_id"
+	ReceiveIDEmail = "email"
+)
+
+// ParseReceiveTarget parses a notify_to style app target into the receive_id
+// type and value expected by im/v1/messages. Accepted forms:
+//
+//	chat:oc_xxx           -> chat_id
+//	user:alice@corp.com   -> email
+//	user:ou_xxx           -> open_id
+//	user:on_xxx           -> union_id
+//	user:<anything else>  -> user_id
+//	open_id:… / user_id:… / union_id:… / email:… / chat_id:… (explicit)
+func ParseReceiveTarget(target string) (idType, id string, ok bool) {
+	prefix, value, found := strings.Cut(strings.TrimSpace(target), ":")
+	if !found || value == "" {
+		return "", "", false
+	}
+	switch prefix {
+	case "chat", ReceiveIDChat:
+		return ReceiveIDChat, value, true
+	case "user":
+		switch {
+		case strings.Contains(value, "@"):
+			return ReceiveIDEmail, value, true
+		case strings.HasPrefix(value, "ou_"):
+			return ReceiveIDOpen, value, true
+		case strings.HasPrefix(value, "on_"):
+			return ReceiveIDUnion, value, true
+		default:
+			return ReceiveIDUser, value, true
+		}
+	case ReceiveIDOpen, ReceiveIDUser, ReceiveIDUnion, ReceiveIDEmail:
+		return prefix, value, true
+	}
+	return "", "", false
+}
+
+// ConvertWebhookPayload converts a custom-bot webhook payload (as rendered
+// from templates.jsonc, e.g. {"msg_type": "interactive", "card": {...}}) into
+// the msg_type and JSON-encoded content string used by im/v1/messages.
+func ConvertWebhookPayload(payload map[string]any) (msgType, content string, err error) {
+	msgType, _ = payload["msg_type"].(string)
+	var body any
+	switch msgType {
+	case "interactive":
+		body = payload["card"]
+	case "post":
+		// webhook: {"content": {"post": {"zh_cn": ...}}}; im/v1: {"zh_cn": ...}
+		if c, ok := payload["content"].(map[string]any); ok {
+			if post, ok := c["post"]; ok {
+				body = post
+				break
+			}
+		}
+		body = payload["content"]
+	case "":
+		return "", "", fmt.Errorf("payload has no msg_type")
+	default:
+		body = payload["content"]
+	}
+	if body == nil {
+		return "", "", fmt.Errorf("payload has no content for msg_type %s", msgType)
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to marshal message content: %w", err)
+	}
+	return msgType, string(data), nil
+}
+
+// SendMessage sends a message via im/v1/messages and returns its message_id.
+func (c *Client) SendMessage(receiveIDType, receiveID, msgType, content string) (string, error) {
+	var out struct {
+		MessageID string `json:"message_id"`
+	}
+	query := url.Values{"receive_id_type": {receiveIDType}}
+	body := map[string]string{"receive_id": receiveID, "msg_type": msgType, "content": content}
+	if err := c.Do("POST", "/open-apis/im/v1/messages", query, body, &out); err != nil {
+		return "", err
+	}
+	return out.MessageID, nil
+}
+
+// PatchMessage replaces the content of a card previously sent by the app.
+// Only interactive messages can be patched, and the card must have been sent
+// with "update_multi": true in its config to update for every viewer.
+func (c *Client) PatchMessage(messageID, content string) error {
+	body := map[string]string{"content": content}
+	return c.Do("PATCH", "/open-apis/im/v1/messages/"+url.PathEscape(messageID), nil, body, nil)
+}
+
+// ReplyMessage replies to messageID, in its thread when inThread is set, and
+// returns the reply's message_id.
+func (c *Client) ReplyMessage(messageID, msgType, content string, inThread bool) (string, error) {
+	var out struct {
+		MessageID string `json:"message_id"`
+	}
+	body := map[string]any{"msg_type": msgType, "content": content, "reply_in_thread": inThread}
+	if err := c.Do("POST", "/open-apis/im/v1/messages/"+url.PathEscape(messageID)+"/reply", nil, body, &out); err != nil {
+		return "", err
+	}
+	return out.MessageID, nil
+}
+
+// EnableSharedCard sets config.update_multi on an interactive webhook-style
+// payload so the card it produces can later be patched with PatchMessage.
+func EnableSharedCard(payload map[string]any) {
+	card, ok := payload["card"].(map[string]any)
+	if !ok {
+		return
+	}
+	cfg, ok := card["config"].(map[string]any)
+	if !ok {
+		cfg = map[string]any{}
+		card["config"] = cfg
+	}
+	cfg["update_multi"] = true
+}
@@ -0,0 +1,67 @@
+package feishu
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Task is a Feishu Task (task/v2) to create.
+type Task struct {
+	Summary     string
+	Description string
+	// AssigneeIDType is the ID type of AssigneeID: "open_id" or "user_id".
+	AssigneeIDType string
+	AssigneeID     string
+	// LinkURL and LinkTitle point the task back at its source (the GitHub
+	// issue); they are shown as the task's origin.
+	LinkURL      string
+	LinkTitle    string
+	TasklistGUID string // optional tasklist to add the task to
+}
+
+// CreateTask creates a task and returns its GUID.
+func (c *Client) CreateTask(task Task) (string, error) {
+	body := map[string]any{
+		"summary":     task.Summary,
+		"description": task.Description,
+		"members": []map[string]string{
+			{"id": task.AssigneeID, "type": "user", "role": "assignee"},
+		},
+		"origin": map[string]any{
+			"platform_i18n_name": map[string]string{"zh_cn": "GitHub", "en_us": "GitHub"},
+			"href":               map[string]string{"url": task.LinkURL, "title": task.LinkTitle},
+		},
+	}
+	if task.TasklistGUID != "" {
+		body["tasklists"] = []map[string]string{{"tasklist_guid": task.TasklistGUID}}
+	}
+	var out struct {
+		Task struct {
+			GUID string `json:"guid"`
+		} `json:"task"`
+	}
+	query := url.Values{"user_id_type": {task.AssigneeIDType}}
+	if err := c.Do("POST", "/open-apis/task/v2/tasks", query, body, &out); err != nil {
+		return "", err
+	}
+	if out.Task.GUID == "" {
+		return "", fmt.Errorf("task create returned no guid")
+	}
+	return out.Task.GUID, nil
+}
+
+// SetTaskCompleted completes a task at the given time, or reopens it when
+// completed is false.
+func (c *Client) SetTaskCompleted(guid string, completed bool, at time.Time) error {
+	completedAt := "0" // task/v2 reopens a task by resetting completed_at
+	if completed {
+		completedAt = strconv.FormatInt(at.UnixMilli(), 10)
+	}
+	body := map[string]any{
+		"task":          map[string]string{"completed_at": completedAt},
+		"update_fields": []string{"completed_at"},
+	}
+	return c.Do("PATCH", "/open-apis/task/v2/tasks/"+url.PathEscape(guid), nil, body, nil)
+}
@@ -0,0 +1,43 @@
+package github
+
+import "fmt"
+
+// PendingDeployment is an environment waiting for review on a workflow run.
+type PendingDeployment struct {
+	Environment struct {
+		ID   int64  `json:"id"`
+		Name string `json:"name"`
+	} `json:"environment"`
+	CurrentUserCanApprove bool `json:"current_user_can_approve"`
+}
+
+// PendingDeployments lists the environments waiting for review on a run.
+func (c *Client) PendingDeployments(repo string, runID int64) ([]PendingDeployment, error) {
+	var out []PendingDeployment
+	err := c.Do(repo, "GET", fmt.Sprintf("/repos/%s/actions/runs/%d/pending_deployments", repo, runID), nil, &out)
+	return out, err
+}
+
+// ReviewPendingDeployments approves ("approved") or rejects ("rejected") the
+// given environments of a run waiting for review.
+func (c *Client) ReviewPendingDeployments(repo string, runID int64, environmentIDs []int64, state, comment string) error {
+	body := map[string]any{"environment_ids": environmentIDs, "state": state, "comment": comment}
+	return c.Do(repo, "POST", fmt.Sprintf("/repos/%s/actions/runs/%d/pending_deployments", repo, runID), body, nil)
+}
+
+// RerunFailedJobs re-runs the failed jobs of a workflow run.
+func (c *Client) RerunFailedJobs(repo string, runID int64) error {
+	return c.Do(repo, "POST", fmt.Sprintf("/repos/%s/actions/runs/%d/rerun-failed-jobs", repo, runID), nil, nil)
+}
+
+// CloseIssue closes an issue or pull request.
+func (c *Client) CloseIssue(repo string, number int) error {
+	body := map[string]string{"state": "closed"}
+	return c.Do(repo, "PATCH", fmt.Sprintf("/repos/%s/issues/%d", repo, number), body, nil)
+}
+
+// AddLabels adds labels to an issue or pull request.
+func (c *Client) AddLabels(repo string, number int, labels []string) error {
+	body := map[string][]string{"labels": labels}
+	return c.Do(repo, "POST", fmt.Sprintf("/repos/%s/issues/%d/labels", repo, number), body, nil)
+}
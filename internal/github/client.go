@@ -0,0 +1,204 @@
+// Package github is a small GitHub REST API client used by card actions. It
+// authenticates with a token or as a GitHub App installation, and its base URL
+// is configurable so it can target GitHub Enterprise or a local stand-in.
+package github
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/hnrobert/feishu-github-tracker/internal/config"
+)
+
+// DefaultAPIURL is the GitHub REST API origin used when none is configured.
+const DefaultAPIURL = "https://api.github.com"
+
+// tokenRefreshMargin renews installation tokens this long before they expire.
+const tokenRefreshMargin = 5 * time.Minute
+
+// Client calls the GitHub REST API. It is safe for concurrent use.
+type Client struct {
+	apiURL string
+	http   *http.Client
+	token  string
+
+	// GitHub App credentials, used when token is empty.
+	appID          int64
+	installationID int64
+	privateKey     *rsa.PrivateKey
+
+	mu            sync.Mutex
+	installations map[string]int64            // "owner/repo" -> installation id
+	tokens        map[int64]installationToken // installation id -> token
+	now           func() time.Time
+}
+
+type installationToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+// APIError is a non-2xx response from the GitHub API.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("github api error %d: %s", e.StatusCode, e.Message)
+}
+
+// NewClient creates a Client from cfg. A nil httpClient uses a client with a
+// 15s timeout.
+func NewClient(cfg config.GitHubConfig, httpClient *http.Client) (*Client, error) {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 15 * time.Second}
+	}
+	apiURL := cfg.APIURL
+	if apiURL == "" {
+		apiURL = DefaultAPIURL
+	}
+	c := &Client{
+		apiURL:         strings.TrimRight(apiURL, "/"),
+		http:           httpClient,
+		token:          cfg.Token,
+		appID:          cfg.AppID,
+		installationID: cfg.InstallationID,
+		installations:  map[string]int64{},
+		tokens:         map[int64]installationToken{},
+		now:            time.Now,
+	}
+	if c.token == "" {
+		if cfg.AppID == 0 || cfg.PrivateKey == "" {
+			return nil, fmt.Errorf("github: either token or app_id and private_key_file are required")
+		}
+		key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(cfg.PrivateKey))
+		if err != nil {
+			return nil, fmt.Errorf("github: invalid app private key: %w", err)
+		}
+		c.privateKey = key
+	}
+	return c, nil
+}
+
+// Do performs an API call for repo ("owner/repo", used to pick the GitHub
+// App installation) and decodes the JSON response into out (may be nil).
+func (c *Client) Do(repo, method, path string, body any, out any) error {
+	token, err := c.repoToken(repo)
+	if err != nil {
+		return err
+	}
+	return c.request(method, path, "token "+token, body, out)
+}
+
+// repoToken returns the token to use for repo: the static token, or an
+// installation access token minted from the app credentials.
+func (c *Client) repoToken(repo string) (string, error) {
+	if c.token != "" {
+		return c.token, nil
+	}
+	installationID := c.installationID
+	if installationID == 0 {
+		c.mu.Lock()
+		installationID = c.installations[repo]
+		c.mu.Unlock()
+	}
+	if installationID == 0 {
+		var out struct {
+			ID int64 `json:"id"`
+		}
+		if err := c.appRequest("GET", "/repos/"+repo+"/installation", &out); err != nil {
+			return "", fmt.Errorf("failed to find app installation for %s: %w", repo, err)
+		}
+		installationID = out.ID
+		c.mu.Lock()
+		c.installations[repo] = installationID
+		c.mu.Unlock()
+	}
+
+	c.mu.Lock()
+	cached, ok := c.tokens[installationID]
+	c.mu.Unlock()
+	if ok && c.now().Before(cached.expiresAt.Add(-tokenRefreshMargin)) {
+		return cached.token, nil
+	}
+
+	var out struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := c.appRequest("POST", fmt.Sprintf("/app/installations/%d/access_tokens", installationID), &out); err != nil {
+		return "", fmt.Errorf("failed to create installation token: %w", err)
+	}
+	c.mu.Lock()
+	c.tokens[installationID] = installationToken{token: out.Token, expiresAt: out.ExpiresAt}
+	c.mu.Unlock()
+	return out.Token, nil
+}
+
+// appRequest calls an endpoint authenticated as the GitHub App itself.
+func (c *Client) appRequest(method, path string, out any) error {
+	now := c.now()
+	claims := jwt.RegisteredClaims{
+		Issuer:    fmt.Sprint(c.appID),
+		IssuedAt:  jwt.NewNumericDate(now.Add(-time.Minute)), // allow for clock drift
+		ExpiresAt: jwt.NewNumericDate(now.Add(9 * time.Minute)),
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(c.privateKey)
+	if err != nil {
+		return fmt.Errorf("failed to sign app jwt: %w", err)
+	}
+	return c.request(method, path, "Bearer "+signed, nil, out)
+}
+
+func (c *Client) request(method, path, authorization string, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.apiURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var e struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &e) != nil || e.Message == "" {
+			e.Message = string(data)
+		}
+		return &APIError{StatusCode: resp.StatusCode, Message: e.Message}
+	}
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package github
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/hnrobert/feishu-github-tracker/internal/config"
+)
+
+func TestClientAppInstallationToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	var minted, lookups int
+	mux := http.NewServeMux()
+	appAuth := func(r *http.Request) bool {
+		raw := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		claims := &jwt.RegisteredClaims{}
+		_, err := jwt.ParseWithClaims(raw, claims, func(*jwt.Token) (any, error) { return &key.PublicKey, nil })
+		return err == nil && claims.Issuer == "42"
+	}
+	mux.HandleFunc("/repos/org/app/installation", func(w http.ResponseWriter, r *http.Request) {
+		if !appAuth(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		lookups++
+		_, _ = w.Write([]byte(`{"id":9}`))
+	})
+	mux.HandleFunc("/app/installations/9/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+		if !appAuth(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		minted++
+		expires := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+		_, _ = w.Write([]byte(`{"token":"ghs_inst","expires_at":"` + expires + `"}`))
+	})
+	mux.HandleFunc("/repos/org/app/issues/1", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token ghs_inst" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"message":"Resource not accessible by integration"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	c, err := NewClient(config.GitHubConfig{APIURL: srv.URL, AppID: 42, PrivateKey: string(keyPEM)}, srv.Client())
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := c.CloseIssue("org/app", 1); err != nil {
+			t.Fatalf("CloseIssue() error = %v", err)
+		}
+	}
+	if lookups != 1 || minted != 1 {
+		t.Fatalf("lookups = %d, minted = %d; want installation and token cached", lookups, minted)
+	}
+
+	err = c.CloseIssue("org/app", 2)
+	if apiErr, ok := err.(*APIError); !ok || apiErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 APIError, got %v", err)
+	}
+}
+
+func TestNewClientRequiresCredentials(t *testing.T) {
+	if _, err := NewClient(config.GitHubConfig{}, nil); err == nil {
+		t.Fatal("expected error without token or app credentials")
+	}
+}
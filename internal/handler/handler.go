@@ -7,36 +7,60 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/hnrobert/feishu-github-tracker/internal/config"
 	"github.com/hnrobert/feishu-github-tracker/internal/logger"
 	"github.com/hnrobert/feishu-github-tracker/internal/matcher"
 	"github.com/hnrobert/feishu-github-tracker/internal/notifier"
+	"github.com/hnrobert/feishu-github-tracker/internal/store"
 	"github.com/hnrobert/feishu-github-tracker/internal/template"
 )
 
 // Handler handles GitHub webhook requests
 type Handler struct {
-	config    *config.Config
-	notifier  *notifier.Notifier
-	hotReload bool
-	configDir string
+	// live holds the configuration in use and the notifier built from it.
+	// Reload swaps both at once while webhooks and background jobs read
+	// them, so they are only accessed through Config and currentNotifier.
+	live         atomic.Pointer[liveConfig]
+	reloadMu     sync.Mutex            // serializes Reload
+	threads      *store.Threads        // nil unless SetThreads was called
+	tasks        *store.Tasks          // nil unless SetTasks was called
+	digests      *store.Digests        // nil unless SetDigests was called
+	history      *store.History        // nil unless SetHistory was called
+	pulls        *store.PullRequests   // nil unless SetPullRequests was called
+	ci           *store.CIStates       // nil unless SetCIStates was called
+	commits      *store.CommitStatuses // nil unless SetCommitStatuses was called
+	suppressions *store.Suppressions   // nil unless SetSuppressions was called
+	held         *store.Held           // nil unless SetHeld was called
+	deployments  *store.Deployments    // nil unless SetDeployments was called
+	hotReload    bool
+	configDir    string
 	// OnReload, if set, is invoked after a successful hot-reload of config (e.g.
 	// to run file-normalization side effects). It must not panic.
 	OnReload func(configDir string)
 }
 
+// liveConfig is a configuration and the notifier built from it.
+type liveConfig struct {
+	config   *config.Config
+	notifier *notifier.Notifier
+}
+
 // New creates a new Handler
 func New(cfg *config.Config, n *notifier.Notifier) *Handler {
-	return &Handler{
-		config:    cfg,
-		notifier:  n,
+	h := &Handler{
 		hotReload: false,
 		configDir: "",
 	}
+	h.live.Store(&liveConfig{config: cfg, notifier: n})
+	return h
 }
 
 // EnableHotReload enables configuration hot reload on each webhook request
@@ -46,23 +70,44 @@ func (h *Handler) EnableHotReload(configDir string) {
 	logger.Info("Hot reload enabled for config directory: %s", configDir)
 }
 
+// Config returns the configuration currently in use (it changes on reload).
+func (h *Handler) Config() *config.Config {
+	return h.live.Load().config
+}
+
+// currentNotifier returns the notifier for the configuration in use.
+func (h *Handler) currentNotifier() *notifier.Notifier {
+	return h.live.Load().notifier
+}
+
+// SetThreads enables PR/issue threading: the status of each pull request and
+// issue is tracked in threads, and app bots with threading configured update
+// or reply to the first card instead of sending a new one per event.
+func (h *Handler) SetThreads(threads *store.Threads) {
+	h.threads = threads
+	h.currentNotifier().SetThreads(threads)
+}
+
 // Reload re-reads the configuration from disk and swaps it into the handler
-// (rebuilding the notifier and running the OnReload hook). It is called on each
+// (renewing the notifier and running the OnReload hook). It is called on each
 // webhook when hot reload is enabled, and also by the management panel after a
 // configuration edit so that changes take effect immediately without a restart.
 func (h *Handler) Reload() {
 	if h.configDir == "" {
 		return
 	}
+	h.reloadMu.Lock()
+	defer h.reloadMu.Unlock()
 	logger.Debug("Reloading configuration from %s", h.configDir)
 	cfg, err := config.Load(h.configDir)
 	if err != nil {
 		logger.Error("Failed to reload configuration: %v", err)
 		return
 	}
+	old := h.live.Load()
 	changed := false
-	if h.config != nil {
-		oldB, _ := json.Marshal(h.config)
+	if old.config != nil {
+		oldB, _ := json.Marshal(old.config)
 		newB, _ := json.Marshal(cfg)
 		if string(oldB) != string(newB) {
 			logger.Info("Configuration changes detected, applying new configuration")
@@ -73,8 +118,13 @@ func (h *Handler) Reload() {
 		changed = true
 	}
 
-	h.config = cfg
-	h.notifier = notifier.New(cfg.FeishuBots)
+	h.live.Store(&liveConfig{config: cfg, notifier: old.notifier.Renew(cfg.FeishuBots)})
+	if h.threads != nil {
+		h.threads.SetRetention(time.Duration(cfg.Server.State.MessageRetentionDays) * 24 * time.Hour)
+	}
+	if h.history != nil {
+		h.history.SetRetention(time.Duration(cfg.Server.State.HistoryRetentionDays) * 24 * time.Hour)
+	}
 
 	if h.OnReload != nil {
 		h.OnReload(h.configDir)
@@ -195,7 +245,7 @@ func (h *Handler) candidateSecrets(payload map[string]any) []string {
 		out = append(out, s)
 	}
 
-	add(h.config.Server.Server.Secret)
+	add(h.Config().Server.Server.Secret)
 
 	if repo := h.extractRepoFullName(payload); repo != "" {
 		if rules, err := h.matchRepositoryRules(repo); err == nil {
@@ -204,7 +254,7 @@ func (h *Handler) candidateSecrets(payload map[string]any) []string {
 			}
 		}
 	} else if org := h.extractOrgName(payload); org != "" {
-		for _, rp := range h.config.Repos.Repos {
+		for _, rp := range h.Config().Repos.Repos {
 			if rp.Pattern == org+"/*" {
 				add(rp.Secret)
 			}
@@ -216,11 +266,11 @@ func (h *Handler) candidateSecrets(payload map[string]any) []string {
 // matchRepositoryRules returns either the first matching rule (the historical
 // default) or every matching rule when match_all_rules is enabled.
 func (h *Handler) matchRepositoryRules(fullName string) ([]*config.RepoPattern, error) {
-	if h.config.Server.Server.MatchAllRules {
-		return matcher.MatchAllRepos(fullName, h.config.Repos.Repos)
+	if h.Config().Server.Server.MatchAllRules {
+		return matcher.MatchAllRepos(fullName, h.Config().Repos.Repos)
 	}
 
-	rule, err := matcher.MatchRepo(fullName, h.config.Repos.Repos)
+	rule, err := matcher.MatchRepo(fullName, h.Config().Repos.Repos)
 	if err != nil || rule == nil {
 		return nil, err
 	}
@@ -251,8 +301,19 @@ func (h *Handler) processWebhook(eventType string, payload map[string]any) error
 
 	// Extract organization name (for org-level webhooks)
 	orgName := h.extractOrgName(payload)
-	if repoFullName != "" && h.config.Server.Server.MatchAllRules {
-		rules, err := matcher.MatchAllRepos(repoFullName, h.config.Repos.Repos)
+	h.syncIssueTasks(eventType, payload)
+	// extra holds the template variables the trackers derive for this
+	// webhook; the GitHub payload itself is left as received.
+	extra := map[string]any{}
+	maps.Copy(extra, h.buildReleaseChangelog(eventType, payload))
+	h.recordHistory(eventType, payload)
+	h.trackPullRequest(eventType, payload)
+	maps.Copy(extra, h.trackCIState(eventType, payload))
+	if lifecycle := h.trackDeployment(eventType, payload); lifecycle != nil {
+		extra["deployment_lifecycle"] = lifecycle
+	}
+	if repoFullName != "" && h.Config().Server.Server.MatchAllRules {
+		rules, err := matcher.MatchAllRepos(repoFullName, h.Config().Repos.Repos)
 		if err != nil {
 			return fmt.Errorf("failed to match repository: %w", err)
 		}
@@ -260,7 +321,7 @@ func (h *Handler) processWebhook(eventType string, payload map[string]any) error
 			logger.Debug("No matching repository pattern found for %s, skipping", repoFullName)
 			return nil
 		}
-		return h.processAllRepositoryRules(eventType, payload, rules)
+		return h.processAllRepositoryRules(eventType, payload, extra, rules)
 	}
 
 	// Determine target bots based on repository or organization
@@ -272,7 +333,7 @@ func (h *Handler) processWebhook(eventType string, payload map[string]any) error
 		// Repository-level webhook
 		logger.Debug("Processing %s event for repository: %s", eventType, repoFullName)
 
-		repoPattern, err = matcher.MatchRepo(repoFullName, h.config.Repos.Repos)
+		repoPattern, err = matcher.MatchRepo(repoFullName, h.Config().Repos.Repos)
 		if err != nil {
 			return fmt.Errorf("failed to match repository: %w", err)
 		}
@@ -289,7 +350,7 @@ func (h *Handler) processWebhook(eventType string, payload map[string]any) error
 		logger.Debug("Processing %s event for organization: %s", eventType, orgName)
 
 		// Find all repo patterns matching this organization (exact match for org/*)
-		for _, repo := range h.config.Repos.Repos {
+		for _, repo := range h.Config().Repos.Repos {
 			if repo.Pattern == orgName+"/*" {
 				targetBots = append(targetBots, repo.NotifyTo...)
 			}
@@ -322,8 +383,8 @@ func (h *Handler) processWebhook(eventType string, payload map[string]any) error
 		// Expand events (resolve templates)
 		expandedEvents := matcher.ExpandEvents(
 			repoPattern.Events,
-			h.config.Events.EventSets,
-			h.config.Events.Events,
+			h.Config().Events.EventSets,
+			h.Config().Events.Events,
 		)
 
 		// Extract event details
@@ -331,22 +392,67 @@ func (h *Handler) processWebhook(eventType string, payload map[string]any) error
 		ref := h.extractRef(payload)
 
 		// Match event
-		if !matcher.MatchEvent(eventType, action, ref, payload, expandedEvents) {
+		if !matcher.MatchEvent(eventType, action, ref, payload, extra, expandedEvents) {
 			logger.Debug("Event %s (action: %s, ref: %s) does not match configured events, skipping", eventType, action, ref)
 			return nil
 		}
+
+		if !h.senderAllowed(payload, repoPattern) {
+			logger.Debug("Event %s from sender %s is filtered out, skipping", eventType, h.extractSenderLogin(payload))
+			return nil
+		}
+
+		if repoPattern.Muted(time.Now()) {
+			logger.Debug("Rule %s is muted until %s, skipping", repoPattern.Pattern, repoPattern.MutedUntil.Format(time.RFC3339))
+			return nil
+		}
+
+		// Label routes add the owning teams' bots on top of the rule's targets.
+		if extra := h.labelRouteTargets(eventType, payload, repoPattern); len(extra) > 0 {
+			targetBots = uniqueStrings(append(append([]string(nil), targetBots...), extra...))
+		}
 	} else {
 		logger.Debug("Ping event - bypassing filter, will notify all matched bots")
 	}
 
-	logger.Info("Event matched: %s, sending notification", eventType)
-	return h.sendNotification(eventType, payload, targetBots)
+	seenTargets := make(map[string]struct{})
+	targetBots = uniqueUnseenTargets(targetBots, seenTargets)
+	return h.dispatch(eventType, payload, extra, repoPattern, targetBots, seenTargets)
+}
+
+// dispatch sends a matched event for rule to targets: through the rule's
+// deployment lifecycle, commit status coalescing or digest when it applies,
+// else as a card right away. Owners of the changed files get their own card
+// in every case, since digests and summaries do not list files.
+func (h *Handler) dispatch(eventType string, payload map[string]any, extra map[string]any, rule *config.RepoPattern, targets []string, seen map[string]struct{}) error {
+	var err error
+	switch {
+	case eventType == "ping":
+		err = h.sendNotificationWithData(eventType, payload, targets, extra)
+	case h.tracksDeployments(eventType, rule):
+		err = h.sendDeploymentEvent(eventType, payload, extra, rule, targets)
+	case h.coalesced(eventType, rule):
+		err = h.bufferCommitStatus(eventType, payload, rule, targets)
+	case h.digested(eventType, rule):
+		err = h.bufferDigest(eventType, payload, rule, targets)
+	default:
+		logger.Info("Event matched: %s, sending notification", eventType)
+		err = h.sendNotificationWithData(eventType, payload, targets, extra)
+	}
+	if ownerErr := h.sendOwnerNotifications(eventType, payload, extra, rule, seen); ownerErr != nil {
+		if err == nil {
+			return ownerErr
+		}
+		return fmt.Errorf("%v; %v", err, ownerErr)
+	}
+	return err
 }
 
 // processAllRepositoryRules evaluates every matching rule in configuration
 // order. A rule that does not subscribe to this event is skipped; failures in
 // one eligible rule do not prevent later eligible rules from being attempted.
-func (h *Handler) processAllRepositoryRules(eventType string, payload map[string]any, rules []*config.RepoPattern) error {
+// extra is merged into the template data of every card.
+func (h *Handler) processAllRepositoryRules(eventType string, payload map[string]any, extra map[string]any, rules []*config.RepoPattern) error {
 	isPingEvent := eventType == "ping"
 	action := h.extractAction(payload)
 	ref := h.extractRef(payload)
@@ -356,21 +462,31 @@ func (h *Handler) processAllRepositoryRules(eventType string, payload map[string
 	for _, rule := range rules {
 		logger.Debug("Matched repository pattern: %s", rule.Pattern)
 		if !isPingEvent {
-			expandedEvents := matcher.ExpandEvents(rule.Events, h.config.Events.EventSets, h.config.Events.Events)
-			if !matcher.MatchEvent(eventType, action, ref, payload, expandedEvents) {
+			expandedEvents := matcher.ExpandEvents(rule.Events, h.Config().Events.EventSets, h.Config().Events.Events)
+			if !matcher.MatchEvent(eventType, action, ref, payload, extra, expandedEvents) {
 				logger.Debug("Event %s (action: %s, ref: %s) does not match rule %s, skipping", eventType, action, ref, rule.Pattern)
 				continue
 			}
+			if !h.senderAllowed(payload, rule) {
+				logger.Debug("Event %s from sender %s is filtered out by rule %s, skipping", eventType, h.extractSenderLogin(payload), rule.Pattern)
+				continue
+			}
+			if rule.Muted(time.Now()) {
+				logger.Debug("Rule %s is muted until %s, skipping", rule.Pattern, rule.MutedUntil.Format(time.RFC3339))
+				continue
+			}
 		}
 
-		targets := uniqueUnseenTargets(rule.NotifyTo, seenTargets)
-		if len(targets) == 0 {
+		ruleTargets := rule.NotifyTo
+		if extra := h.labelRouteTargets(eventType, payload, rule); len(extra) > 0 {
+			ruleTargets = append(append([]string(nil), ruleTargets...), extra...)
+		}
+		targets := uniqueUnseenTargets(ruleTargets, seenTargets)
+		if len(targets) == 0 && len(rule.OwnershipRules()) == 0 {
 			logger.Debug("Rule %s has no new notification targets, skipping", rule.Pattern)
 			continue
 		}
-
-		logger.Info("Event matched: %s (rule: %s), sending notification", eventType, rule.Pattern)
-		if err := h.sendNotification(eventType, payload, targets); err != nil {
+		if err := h.dispatch(eventType, payload, extra, rule, targets, seenTargets); err != nil {
 			logger.Error("Failed to send notifications for rule %s: %v", rule.Pattern, err)
 			errs = append(errs, fmt.Sprintf("rule %s: %v", rule.Pattern, err))
 		}
@@ -396,14 +512,156 @@ func uniqueUnseenTargets(targets []string, seen map[string]struct{}) []string {
 	return result
 }
 
+// senderAllowed applies the global (server.yaml filters) and rule-level sender
+// filters to the webhook's sender. Webhooks without a sender are allowed.
+func (h *Handler) senderAllowed(payload map[string]any, rule *config.RepoPattern) bool {
+	sender, ok := payload["sender"].(map[string]any)
+	if !ok {
+		return true
+	}
+	login, _ := sender["login"].(string)
+	senderType, _ := sender["type"].(string)
+	isBot := senderType == "Bot" || strings.HasSuffix(login, "[bot]")
+
+	var ruleFilter config.SenderFilter
+	if rule != nil {
+		ruleFilter = rule.SenderFilter
+	}
+	return matcher.SenderAllowed(login, isBot, h.Config().Server.Filters, ruleFilter)
+}
+
+// labelRouteTargets returns the extra targets selected by the rule's
+// label_routes. Only issues and pull_request events are routed by label; for
+// labeled/unlabeled actions just the label that changed is considered, so
+// adding an unrelated label doesn't re-notify every owning team.
+func (h *Handler) labelRouteTargets(eventType string, payload map[string]any, rule *config.RepoPattern) []string {
+	if rule == nil || len(rule.LabelRoutes) == 0 {
+		return nil
+	}
+	labels := h.extractLabels(eventType, payload)
+	if len(labels) == 0 {
+		return nil
+	}
+	targets, err := matcher.MatchLabelRoutes(labels, rule.LabelRoutes)
+	if err != nil {
+		logger.Warn("Failed to match label routes for rule %s: %v", rule.Pattern, err)
+		return nil
+	}
+	if len(targets) > 0 {
+		logger.Debug("Label routes of rule %s matched %v, adding targets %v", rule.Pattern, labels, targets)
+	}
+	return targets
+}
+
+// sendOwnerNotifications sends one card per owning target of the files changed
+// by a push or pull request, listing only that target's files (exposed to
+// templates as owned_files / owned_files_md / owned_files_count). Targets that
+// already received the regular card for this webhook are skipped. The owner
+// variables are added to extra.
+func (h *Handler) sendOwnerNotifications(eventType string, payload map[string]any, extra map[string]any, rule *config.RepoPattern, seen map[string]struct{}) error {
+	if rule == nil {
+		return nil
+	}
+	ownership := rule.OwnershipRules()
+	if len(ownership) == 0 {
+		return nil
+	}
+	files := h.extractChangedFiles(eventType, payload)
+	if len(files) == 0 {
+		return nil
+	}
+	owned, err := matcher.MatchOwners(files, ownership)
+	if err != nil {
+		logger.Warn("Failed to match owners for rule %s: %v", rule.Pattern, err)
+		return nil
+	}
+
+	var errs []string
+	for _, o := range owned {
+		if _, ok := seen[o.Target]; ok {
+			continue
+		}
+		seen[o.Target] = struct{}{}
+		logger.Debug("Owner %s of rule %s owns %d changed file(s)", o.Target, rule.Pattern, len(o.Files))
+		ownerExtra := maps.Clone(extra)
+		if ownerExtra == nil {
+			ownerExtra = map[string]any{}
+		}
+		ownerExtra["owned_files"] = o.Files
+		ownerExtra["owned_files_count"] = len(o.Files)
+		ownerExtra["owned_files_md"] = "- " + strings.Join(o.Files, "\n- ")
+		ownerExtra["owner_target"] = o.Target
+		if err := h.sendNotificationWithData(eventType, payload, []string{o.Target}, ownerExtra); err != nil {
+			errs = append(errs, fmt.Sprintf("owner %s: %v", o.Target, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to notify some owners: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
 func (h *Handler) sendNotification(eventType string, payload map[string]any, targets []string) error {
-	tags := template.DetermineTags(eventType, payload)
+	return h.sendNotificationWithData(eventType, payload, targets, nil)
+}
+
+// sendNotificationWithData renders and sends the event card to targets, with
+// extra merged over the prepared template data (for per-target variables).
+// Maintenance mode, suppressions and quiet hours may hold or drop targets
+// first.
+func (h *Handler) sendNotificationWithData(eventType string, payload map[string]any, targets []string, extra map[string]any) error {
+	targets = h.holdDelivery(eventType, payload, targets, extra)
+	if len(targets) == 0 {
+		return nil
+	}
+	tags := eventTags(eventType, payload, extra)
+	if targets = h.holdQuiet(eventType, payload, targets, extra, tags); len(targets) == 0 {
+		return nil
+	}
+	return h.deliver(eventType, payload, targets, extra, tags)
+}
+
+// deliver renders and sends the event to targets without holding it.
+func (h *Handler) deliver(eventType string, payload map[string]any, targets []string, extra map[string]any, tags []string) error {
 	data := h.prepareTemplateData(eventType, payload)
-	targetsByTemplate := h.groupTargetsByTemplate(targets)
+	for k, v := range extra {
+		data[k] = v
+	}
+	h.prepareBrokenMentionData(eventType, data)
+	threadKey := h.prepareThreadData(eventType, data, payload, targets)
 	var errs []string
+	targets, envelopeTargets := h.splitEnvelopeTargets(targets)
+	if len(envelopeTargets) > 0 {
+		envelope := map[string]any{
+			"event":   eventType,
+			"tags":    tags,
+			"data":    data,
+			"payload": payload,
+		}
+		if err := h.currentNotifier().Send(envelopeTargets, envelope); err != nil {
+			logger.Error("Failed to send event envelope: %v", err)
+			errs = append(errs, fmt.Sprintf("envelope: %v", err))
+		}
+	}
+	targetsByTemplate := h.groupTargetsByTemplate(targets)
 	for templateName, templateTargets := range targetsByTemplate {
 		logger.Debug("Processing %d target(s) with template: %s", len(templateTargets), templateName)
-		templatesConfig := h.config.GetTemplateConfig(templateName)
+		templatesConfig := h.Config().GetTemplateConfig(templateName)
+		templateTargets, mismatched := h.splitTargetsByFormat(templateTargets, templatesConfig.SinkFormat())
+		for _, target := range mismatched {
+			logger.Error("Template %s produces %s payloads, but %s expects %s; skipping it", templateName, templatesConfig.SinkFormat(), target, h.Config().GetBotFormat(target))
+			errs = append(errs, fmt.Sprintf("target %s: template %s has format %s", target, templateName, templatesConfig.SinkFormat()))
+		}
+		if len(templateTargets) == 0 {
+			continue
+		}
+		if _, ok := templatesConfig.Templates[eventType]; !ok {
+			// Sink template files need not cover every event: targets using
+			// one without this event are skipped rather than failing the
+			// delivery for the others.
+			logger.Warn("Template %s has no %s template, not sending it to %v", templateName, eventType, templateTargets)
+			continue
+		}
 		tmpl, err := template.SelectTemplate(eventType, tags, templatesConfig)
 		if err != nil {
 			logger.Error("Failed to select template for %s: %v", templateName, err)
@@ -416,7 +674,11 @@ func (h *Handler) sendNotification(eventType string, payload map[string]any, tar
 			errs = append(errs, fmt.Sprintf("template %s: %v", templateName, err))
 			continue
 		}
-		if err := h.notifier.Send(templateTargets, filledPayload); err != nil {
+		var status map[string]any
+		if threadKey != "" {
+			status = threadStatusCard(templatesConfig, data)
+		}
+		if err := h.currentNotifier().SendThread(templateTargets, filledPayload, threadKey, status); err != nil {
 			logger.Error("Failed to send notifications for template %s: %v", templateName, err)
 			errs = append(errs, fmt.Sprintf("template %s: %v", templateName, err))
 		}
@@ -432,13 +694,39 @@ func (h *Handler) groupTargetsByTemplate(targets []string) map[string][]string {
 	result := make(map[string][]string)
 
 	for _, target := range targets {
-		templateName := h.config.GetBotTemplate(target)
+		templateName := h.Config().GetBotTemplate(target)
 		result[templateName] = append(result[templateName], target)
 	}
 
 	return result
 }
 
+// splitEnvelopeTargets separates the generic targets that receive the event
+// envelope from those that receive a rendered template.
+func (h *Handler) splitEnvelopeTargets(targets []string) (rendered, envelope []string) {
+	for _, target := range targets {
+		if h.Config().IsEnvelopeTarget(target) {
+			envelope = append(envelope, target)
+		} else {
+			rendered = append(rendered, target)
+		}
+	}
+	return rendered, envelope
+}
+
+// splitTargetsByFormat separates the targets whose sink accepts the given
+// template format from those that do not.
+func (h *Handler) splitTargetsByFormat(targets []string, format string) (matching, mismatched []string) {
+	for _, target := range targets {
+		if h.Config().GetBotFormat(target) == format {
+			matching = append(matching, target)
+		} else {
+			mismatched = append(mismatched, target)
+		}
+	}
+	return matching, mismatched
+}
+
 func (h *Handler) extractRepoFullName(payload map[string]any) string {
 	if repo, ok := payload["repository"].(map[string]any); ok {
 		if fullName, ok := repo["full_name"].(string); ok {
@@ -457,6 +745,15 @@ func (h *Handler) extractOrgName(payload map[string]any) string {
 	return ""
 }
 
+func (h *Handler) extractSenderLogin(payload map[string]any) string {
+	if sender, ok := payload["sender"].(map[string]any); ok {
+		if login, ok := sender["login"].(string); ok {
+			return login
+		}
+	}
+	return ""
+}
+
 func (h *Handler) extractAction(payload map[string]any) string {
 	if action, ok := payload["action"].(string); ok {
 		return action
@@ -464,6 +761,97 @@ func (h *Handler) extractAction(payload map[string]any) string {
 	return ""
 }
 
+// extractLabels returns the label names relevant for routing an issues or
+// pull_request event: the changed label for labeled/unlabeled actions,
+// otherwise every label currently on the issue or pull request.
+func (h *Handler) extractLabels(eventType string, payload map[string]any) []string {
+	var object string
+	switch eventType {
+	case "issues":
+		object = "issue"
+	case "pull_request":
+		object = "pull_request"
+	default:
+		return nil
+	}
+
+	action := h.extractAction(payload)
+	if action == "labeled" || action == "unlabeled" {
+		if label, ok := payload["label"].(map[string]any); ok {
+			if name, ok := label["name"].(string); ok && name != "" {
+				return []string{name}
+			}
+		}
+	}
+
+	var names []string
+	if obj, ok := payload[object].(map[string]any); ok {
+		if labels, ok := obj["labels"].([]any); ok {
+			for _, l := range labels {
+				if lm, ok := l.(map[string]any); ok {
+					if name, ok := lm["name"].(string); ok && name != "" {
+						names = append(names, name)
+					}
+				}
+			}
+		}
+	}
+	return names
+}
+
+// extractChangedFiles returns the files touched by a push (added, modified and
+// removed across all commits) or, when the payload carries one, the file list
+// of a pull request. Paths are deduplicated in first-seen order.
+func (h *Handler) extractChangedFiles(eventType string, payload map[string]any) []string {
+	seen := make(map[string]struct{})
+	var files []string
+	add := func(v any) {
+		var path string
+		switch f := v.(type) {
+		case string:
+			path = f
+		case map[string]any:
+			path, _ = f["filename"].(string)
+		}
+		if path == "" {
+			return
+		}
+		if _, ok := seen[path]; ok {
+			return
+		}
+		seen[path] = struct{}{}
+		files = append(files, path)
+	}
+
+	switch eventType {
+	case "push":
+		commits, _ := payload["commits"].([]any)
+		for _, c := range commits {
+			cm, ok := c.(map[string]any)
+			if !ok {
+				continue
+			}
+			for _, key := range []string{"added", "modified", "removed"} {
+				list, _ := cm[key].([]any)
+				for _, f := range list {
+					add(f)
+				}
+			}
+		}
+	case "pull_request":
+		// GitHub doesn't include the file list in pull_request webhooks; honor
+		// it when a relay (or a future API lookup) attached one.
+		list, _ := payload["files"].([]any)
+		if pr, ok := payload["pull_request"].(map[string]any); ok && list == nil {
+			list, _ = pr["files"].([]any)
+		}
+		for _, f := range list {
+			add(f)
+		}
+	}
+	return files
+}
+
 func (h *Handler) extractRef(payload map[string]any) string {
 	if ref, ok := payload["ref"].(string); ok {
 		return ref
@@ -490,7 +878,7 @@ func (h *Handler) prepareTemplateData(eventType string, payload map[string]any)
 	switch eventType {
 	case "push":
 		preparePushData(data, payload)
-	case "pull_request":
+	case "pull_request", "stale_pr":
 		preparePullRequestData(data, payload)
 	case "pull_request_review":
 		preparePullRequestReviewData(data, payload)
@@ -642,6 +1030,12 @@ func (h *Handler) prepareTemplateData(eventType string, payload map[string]any)
 		// unknown event types: nothing extra to do
 	}
 
+	// severity / ecosystem of security alerts
+	prepareAlertSeverityData(eventType, data, payload)
+
+	// Feishu @mentions for users mapped in users.yaml
+	h.prepareMentionData(eventType, data, payload)
+
 	return data
 }
 
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"github.com/hnrobert/feishu-github-tracker/internal/logger"
+	"github.com/hnrobert/feishu-github-tracker/internal/matcher"
+	"github.com/hnrobert/feishu-github-tracker/internal/store"
+	"github.com/hnrobert/feishu-github-tracker/internal/template"
+)
+
+// SetCIStates enables CI transition tracking: the last outcome of every
+// workflow per branch is kept in states, and completed workflow runs carry
+// what the run changed into their cards (see trackCIState).
+func (h *Handler) SetCIStates(states *store.CIStates) {
+	h.ci = states
+}
+
+// trackCIState records the outcome of a completed workflow run of a tracked
+// repo per (repo, workflow, branch) and returns the ci_transition /
+// ci_failures template variables for it. The transition also becomes a
+// broken / fixed / still_failing tag (see eventTags) and is what the
+// `transitions` event filter reads. Runs that neither passed nor failed
+// (cancelled, skipped, ...) leave the state alone and return nil. Failures
+// are only logged.
+func (h *Handler) trackCIState(eventType string, payload map[string]any) map[string]any {
+	if h.ci == nil || eventType != "workflow_run" || h.extractAction(payload) != "completed" {
+		return nil
+	}
+	var failing bool
+	switch firstString(payload, "workflow_run.conclusion") {
+	case "success":
+	case "failure", "timed_out", "startup_failure":
+		failing = true
+	default:
+		return nil
+	}
+	repo := h.extractRepoFullName(payload)
+	if repo == "" {
+		return nil
+	}
+	if rule, err := matcher.MatchRepo(repo, h.Config().Repos.Repos); err != nil || rule == nil {
+		return nil
+	}
+	key := repo + "|" + firstString(payload, "workflow_run.name", "workflow.name") + "|" + firstString(payload, "workflow_run.head_branch")
+	runID, _ := valueAt(payload, "workflow_run.id").(float64)
+	st, err := h.ci.Record(key, int64(runID), max(intAt(payload, "workflow_run.run_attempt"), 1), failing)
+	if err != nil {
+		logger.Warn("Failed to record CI state of %s: %v", key, err)
+	}
+	if st.Transition != "" {
+		logger.Debug("Workflow %s: %s (%d consecutive failure(s))", key, st.Transition, st.Failures)
+	}
+	return map[string]any{
+		"ci_transition": st.Transition,
+		"ci_failures":   st.Failures,
+	}
+}
+
+// eventTags returns the template tags of the event: template.DetermineTags
+// plus the CI transition trackCIState put in extra.
+func eventTags(eventType string, payload, extra map[string]any) []string {
+	tags := template.DetermineTags(eventType, payload)
+	if transition, _ := extra["ci_transition"].(string); transition != "" {
+		tags = append(tags, transition)
+	}
+	return tags
+}
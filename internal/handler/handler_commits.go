@@ -0,0 +1,262 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hnrobert/feishu-github-tracker/internal/config"
+	"github.com/hnrobert/feishu-github-tracker/internal/logger"
+	"github.com/hnrobert/feishu-github-tracker/internal/store"
+)
+
+// commitSettle is how long a commit whose checks have all completed waits
+// for more checks when no check suite reported completion.
+const commitSettle = time.Minute
+
+// SetCommitStatuses enables commit status rules (repos.yaml
+// `commit_status:`), collecting their check events in commits. Without it
+// those events notify one by one.
+func (h *Handler) SetCommitStatuses(commits *store.CommitStatuses) {
+	h.commits = commits
+}
+
+// coalesced reports whether rule groups this event into a commit status card.
+func (h *Handler) coalesced(eventType string, rule *config.RepoPattern) bool {
+	return h.commits != nil && rule != nil && rule.CoalescesCommitStatus(eventType)
+}
+
+// bufferCommitStatus adds a check_run, check_suite or workflow_job event to
+// the status of its head commit instead of notifying. Workflow jobs and
+// their check runs are the same job and share one entry.
+func (h *Handler) bufferCommitStatus(eventType string, payload map[string]any, rule *config.RepoPattern, targets []string) error {
+	obj, _ := payload[eventType].(map[string]any)
+	sha := firstString(obj, "head_sha")
+	if sha == "" {
+		logger.Debug("Event %s has no head_sha, sending it as is", eventType)
+		return h.sendNotification(eventType, payload, targets)
+	}
+	repo := h.extractRepoFullName(payload)
+	key := rule.Pattern + "|" + repo + "@" + sha
+
+	var id string
+	var job store.CommitJob
+	switch eventType {
+	case "check_run":
+		id = "check:" + idString(obj["id"])
+		job.Name = firstString(obj, "name")
+	case "workflow_job":
+		id = "job:" + idString(obj["id"])
+		if url := firstString(obj, "check_run_url"); url != "" {
+			id = "check:" + url[strings.LastIndex(url, "/")+1:]
+		}
+		job.Name = firstString(obj, "name")
+		if workflow := firstString(obj, "workflow_name"); workflow != "" {
+			job.Name = workflow + " / " + job.Name
+		}
+	}
+	job.Status = firstString(obj, "status")
+	job.Conclusion = firstString(obj, "conclusion")
+	job.URL = firstString(obj, "html_url", "details_url")
+	job.StartedAt = timeAt(obj, "started_at")
+	job.CompletedAt = timeAt(obj, "completed_at")
+
+	logger.Info("Event matched: %s (rule: %s), collecting status of %s@%.7s", eventType, rule.Pattern, repo, sha)
+	err := h.commits.Update(key, rule.CommitStatus.TimeoutDuration(), targets, func(s *store.CommitStatus) {
+		s.Rule, s.Repo, s.SHA = rule.Pattern, repo, sha
+		s.RepoURL = firstString(payload, "repository.html_url")
+		if branch := firstString(obj, "head_branch", "check_suite.head_branch"); branch != "" {
+			s.Branch = branch
+		}
+		if eventType == "check_suite" {
+			if s.Suites == nil {
+				s.Suites = map[string]*store.CommitSuite{}
+			}
+			s.Suites[idString(obj["id"])] = &store.CommitSuite{
+				Name:       firstString(obj, "app.name"),
+				Status:     job.Status,
+				Conclusion: job.Conclusion,
+			}
+			return
+		}
+		if prev, ok := s.Jobs[id]; ok {
+			// check_run and workflow_job deliveries of one job: keep the
+			// richer name and the latest state
+			if strings.Contains(prev.Name, " / ") && !strings.Contains(job.Name, " / ") {
+				job.Name = prev.Name
+			}
+			if job.StartedAt.IsZero() {
+				job.StartedAt = prev.StartedAt
+			}
+			if prev.Completed() && !job.Completed() {
+				return
+			}
+		}
+		s.Jobs[id] = &job
+	})
+	if err != nil {
+		return fmt.Errorf("failed to collect commit status for rule %s: %w", rule.Pattern, err)
+	}
+	return nil
+}
+
+// idString renders a JSON number id without an exponent.
+func idString(v any) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return fmt.Sprint(v)
+}
+
+// timeAt parses the RFC 3339 timestamp at a dotted path, or returns zero.
+func timeAt(data map[string]any, path string) time.Time {
+	t, _ := time.Parse(time.RFC3339, firstString(data, path))
+	return t
+}
+
+// RunCommitStatuses sends finished commit statuses every interval until ctx
+// is done.
+func (h *Handler) RunCommitStatuses(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			h.FlushCommitStatuses(now)
+		}
+	}
+}
+
+// FlushCommitStatuses sends one "commit_status" card for every commit whose
+// checks finished or timed out at now. The summary is exposed to templates
+// as commit_* variables.
+func (h *Handler) FlushCommitStatuses(now time.Time) {
+	if h.commits == nil {
+		return
+	}
+	done, err := h.commits.TakeDone(now, commitSettle)
+	if err != nil {
+		logger.Warn("Failed to save commit statuses: %v", err)
+	}
+	for _, s := range done {
+		payload, extra := commitStatusData(s)
+		logger.Info("Sending status of %s@%.7s: %s", s.Repo, s.SHA, extra["commit_conclusion"])
+		if err := h.sendNotificationWithData("commit_status", payload, s.Targets, extra); err != nil {
+			logger.Error("Failed to send status of %s@%.7s: %v", s.Repo, s.SHA, err)
+		}
+	}
+}
+
+// commitStatusData builds the webhook-like payload and the commit_*
+// template variables of a collected commit.
+func commitStatusData(s store.CommitStatus) (map[string]any, map[string]any) {
+	var passed, failed, pending, other int
+	var first, last time.Time
+	var md, text, table []string
+	table = append(table, "| Job | Result | Duration |", "| --- | --- | --- |")
+	jobs := s.SortedJobs()
+	for _, job := range jobs {
+		result := job.Conclusion
+		icon := "⚪"
+		switch {
+		case !job.Completed():
+			pending++
+			result, icon = "pending", "⏳"
+		case job.Conclusion == "success":
+			passed++
+			icon = "✅"
+		case failingConclusion(job.Conclusion):
+			failed++
+			icon = "❌"
+		default:
+			other++
+		}
+		duration := "—"
+		if !job.StartedAt.IsZero() && !job.CompletedAt.IsZero() {
+			duration = formatDuration(job.CompletedAt.Sub(job.StartedAt))
+			if first.IsZero() || job.StartedAt.Before(first) {
+				first = job.StartedAt
+			}
+			if job.CompletedAt.After(last) {
+				last = job.CompletedAt
+			}
+		}
+		name := job.Name
+		if job.URL != "" {
+			name = fmt.Sprintf("[%s](%s)", job.Name, job.URL)
+		}
+		md = append(md, fmt.Sprintf("%s %s · %s · %s", icon, name, result, duration))
+		text = append(text, strings.TrimSpace(fmt.Sprintf("%s %s: %s (%s) %s", icon, job.Name, result, duration, job.URL)))
+		table = append(table, fmt.Sprintf("| %s | %s %s | %s |", name, icon, result, duration))
+	}
+
+	// A suite can fail or still run although every job listed passed
+	// (checks of apps the rule does not collect).
+	var suiteFailed, suitePending bool
+	for _, suite := range s.Suites {
+		suitePending = suitePending || suite.Status != "completed"
+		suiteFailed = suiteFailed || failingConclusion(suite.Conclusion)
+	}
+	conclusion := "success"
+	switch {
+	case failed > 0 || suiteFailed:
+		conclusion = "failure"
+	case pending > 0 || suitePending || len(jobs) == 0:
+		conclusion = "pending"
+	}
+	duration := "—"
+	if !first.IsZero() {
+		duration = formatDuration(last.Sub(first))
+	}
+	commitURL := ""
+	if s.RepoURL != "" {
+		commitURL = s.RepoURL + "/commit/" + s.SHA
+	}
+	short := s.SHA
+	if len(short) > 7 {
+		short = short[:7]
+	}
+
+	payload := map[string]any{
+		"repository":    map[string]any{"full_name": s.Repo, "html_url": s.RepoURL},
+		"commit_status": map[string]any{"sha": s.SHA, "conclusion": conclusion},
+	}
+	extra := map[string]any{
+		"commit_sha":           s.SHA,
+		"commit_sha_short":     short,
+		"commit_branch":        s.Branch,
+		"commit_url":           commitURL,
+		"commit_conclusion":    conclusion,
+		"commit_total":         len(jobs),
+		"commit_passed":        passed,
+		"commit_failed":        failed,
+		"commit_pending":       pending,
+		"commit_other":         other,
+		"commit_duration":      duration,
+		"commit_jobs_md":       joinOrDash(md, "\n"),
+		"commit_jobs_text":     joinOrDash(text, "\n"),
+		"commit_jobs_table_md": strings.Join(table, "\n"),
+	}
+	return payload, extra
+}
+
+// failingConclusion reports whether a check conclusion counts as failed.
+func failingConclusion(conclusion string) bool {
+	return conclusion == "failure" || conclusion == "timed_out" || conclusion == "startup_failure"
+}
+
+// formatDuration renders a job duration as "45s", "2m 13s" or "1h 5m".
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	switch {
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh %dm", int(d/time.Hour), int(d%time.Hour/time.Minute))
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm %ds", int(d/time.Minute), int(d%time.Minute/time.Second))
+	}
+	return fmt.Sprintf("%ds", int(d/time.Second))
+}
@@ -0,0 +1,20 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/hnrobert/feishu-github-tracker/internal/matcher"
+)
+
+// prepareAlertSeverityData exposes the normalized severity, ecosystem and
+// advisory ids of security alert events (the same values the severity
+// filters and severity:* tags use): alert_severity, alert_ecosystem and
+// alert_ids.
+func prepareAlertSeverityData(eventType string, data map[string]any, payload map[string]any) {
+	if !matcher.IsSecurityAlert(eventType) {
+		return
+	}
+	data["alert_severity"] = matcher.AlertSeverity(eventType, payload)
+	data["alert_ecosystem"] = matcher.AlertEcosystem(eventType, payload)
+	data["alert_ids"] = strings.Join(matcher.AlertIdentifiers(eventType, payload), ", ")
+}
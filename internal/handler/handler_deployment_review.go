@@ -22,6 +22,11 @@ func prepareDeploymentReviewData(data map[string]any, payload map[string]any) {
 
 	// Extract workflow run info
 	if workflowRun, ok := payload["workflow_run"].(map[string]any); ok {
+		// normalize id so {{workflow_run.id}} renders as an integer (e.g. in
+		// card action button values) rather than in float notation
+		if idv, okid := workflowRun["id"].(float64); okid && float64(int64(idv)) == idv {
+			workflowRun["id"] = int64(idv)
+		}
 		data["workflow_run"] = workflowRun
 	}
 
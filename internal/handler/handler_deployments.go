@@ -0,0 +1,270 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hnrobert/feishu-github-tracker/internal/config"
+	"github.com/hnrobert/feishu-github-tracker/internal/logger"
+	"github.com/hnrobert/feishu-github-tracker/internal/matcher"
+	"github.com/hnrobert/feishu-github-tracker/internal/store"
+)
+
+// SetDeployments enables deployment lifecycle rules (repos.yaml
+// `deployment_lifecycle:`), tracking the deployments of matched repos in
+// deployments. Without it deployment events notify one by one.
+func (h *Handler) SetDeployments(deployments *store.Deployments) {
+	h.deployments = deployments
+}
+
+// isDeploymentEvent reports whether eventType is part of a deployment
+// lifecycle.
+func isDeploymentEvent(eventType string) bool {
+	switch eventType {
+	case "deployment", "deployment_status", "deployment_review", "deployment_protection_rule":
+		return true
+	}
+	return false
+}
+
+// trackDeployment correlates a deployment event of a tracked repo with the
+// earlier events of the same deployment, by deployment id or, for reviews
+// that carry none, by environment and workflow run, and returns a
+// "deployment_lifecycle" object ({state, finished, vars}) holding the summary
+// template variables, or nil if the event is not tracked. Failures are only
+// logged.
+func (h *Handler) trackDeployment(eventType string, payload map[string]any) map[string]any {
+	if h.deployments == nil || !isDeploymentEvent(eventType) {
+		return nil
+	}
+	repo := h.extractRepoFullName(payload)
+	if repo == "" {
+		return nil
+	}
+	if rule, err := matcher.MatchRepo(repo, h.Config().Repos.Repos); err != nil || rule == nil {
+		return nil
+	}
+
+	action := h.extractAction(payload)
+	env := firstString(payload, "deployment.environment", "deployment_status.environment", "environment", "workflow_job_run.environment")
+	var id int64
+	if v, ok := valueAt(payload, "deployment.id").(float64); ok {
+		id = int64(v)
+	}
+	run := ""
+	if v := valueAt(payload, "workflow_run.id"); v != nil && env != "" {
+		run = env + "|" + idString(v)
+	}
+	if id == 0 && run == "" {
+		return nil
+	}
+	now := time.Now()
+
+	dep, finished, previous, err := h.deployments.Update(repo, id, run, func(d *store.Deployment) {
+		if d.Environment == "" {
+			d.Environment = env
+		}
+		if d.RepoURL == "" {
+			d.RepoURL = firstString(payload, "repository.html_url")
+		}
+		if d.Ref == "" {
+			d.Ref = firstString(payload, "deployment.ref", "workflow_run.head_branch")
+		}
+		if d.SHA == "" {
+			d.SHA = firstString(payload, "deployment.sha", "workflow_run.head_sha")
+		}
+		if d.Creator == "" {
+			d.Creator = firstString(payload, "deployment.creator.login")
+		}
+		if d.State == "" {
+			d.State = store.DeploymentRequested
+		}
+		if t := timeAt(payload, "deployment.created_at"); !t.IsZero() && t.Before(d.Requested) {
+			d.Requested = t
+		}
+
+		switch eventType {
+		case "deployment_protection_rule":
+			markWaiting(d, now)
+		case "deployment_review":
+			switch action {
+			case "requested":
+				markWaiting(d, now)
+			case "approved", "rejected":
+				d.Reviewed = now
+				d.Approver = firstString(payload, "approver.login", "sender.login")
+				d.ApprovalComment = firstString(payload, "comment")
+				if action == "rejected" {
+					d.State = store.DeploymentRejected
+				}
+			}
+		case "deployment_status":
+			state := firstString(payload, "deployment_status.state")
+			if url := firstString(payload, "deployment_status.log_url", "deployment_status.target_url"); url != "" {
+				d.URL = url
+			}
+			switch state {
+			case "":
+			case "waiting":
+				markWaiting(d, now)
+			case "in_progress":
+				if d.Started.IsZero() {
+					d.Started = now
+				}
+				d.State = state
+			case "queued", "pending":
+				if d.State == store.DeploymentRequested {
+					d.State = state
+				}
+			default:
+				d.State = state
+			}
+		}
+	})
+	if err != nil {
+		logger.Warn("Failed to save deployment of %s: %v", repo, err)
+	}
+	if dep.Repo == "" {
+		return nil
+	}
+	if finished {
+		logger.Debug("Deployment %d of %s to %s finished: %s", dep.ID, repo, dep.Environment, dep.State)
+	}
+	return map[string]any{
+		"state":    dep.State,
+		"finished": finished,
+		"vars":     deploymentData(dep, previous),
+	}
+}
+
+// markWaiting records that d waits for an approval or protection rule.
+func markWaiting(d *store.Deployment, now time.Time) {
+	if d.Waiting.IsZero() {
+		d.Waiting = now
+	}
+	if d.State == store.DeploymentRequested || d.State == "queued" || d.State == "pending" {
+		d.State = store.DeploymentWaiting
+	}
+}
+
+// sendDeploymentEvent sends a deployment event for a rule with
+// deployment_lifecycle: the event's own card unless the summary replaces it,
+// and the "deployment_lifecycle" summary card when the event finished its
+// deployment. extra carries the lifecycle returned by trackDeployment.
+func (h *Handler) sendDeploymentEvent(eventType string, payload map[string]any, extra map[string]any, rule *config.RepoPattern, targets []string) error {
+	var errs []string
+	if !rule.DeploymentLifecycle.Absorbs(eventType) {
+		if err := h.sendNotificationWithData(eventType, payload, targets, extra); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	lifecycle, _ := extra["deployment_lifecycle"].(map[string]any)
+	if finished, _ := lifecycle["finished"].(bool); finished {
+		vars, _ := lifecycle["vars"].(map[string]any)
+		summary := map[string]any{
+			"repository":           payload["repository"],
+			"sender":               payload["sender"],
+			"deployment_lifecycle": map[string]any{"state": lifecycle["state"]},
+		}
+		logger.Info("Sending deployment summary of %s: %s", h.extractRepoFullName(payload), lifecycle["state"])
+		if err := h.sendNotificationWithData("deployment_lifecycle", summary, targets, vars); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// tracksDeployments reports whether rule turns this event into part of a
+// deployment lifecycle.
+func (h *Handler) tracksDeployments(eventType string, rule *config.RepoPattern) bool {
+	return h.deployments != nil && rule != nil && rule.DeploymentLifecycle != nil && isDeploymentEvent(eventType)
+}
+
+// deploymentData builds the deploy_* template variables of a deployment and
+// the previous successful deployment to the same environment.
+func deploymentData(d store.Deployment, previous store.Deployment) map[string]any {
+	short := d.SHA
+	if len(short) > 7 {
+		short = short[:7]
+	}
+	span := func(from, to time.Time) string {
+		if from.IsZero() || to.IsZero() || to.Before(from) {
+			return "—"
+		}
+		return formatDuration(to.Sub(from))
+	}
+	wait := "—"
+	if !d.Waiting.IsZero() {
+		end := d.Reviewed
+		if end.IsZero() {
+			end = d.Started
+		}
+		wait = span(d.Waiting, end)
+	}
+
+	var timeline []string
+	step := func(t time.Time, what string) {
+		if !t.IsZero() {
+			timeline = append(timeline, fmt.Sprintf("+%s · %s", span(d.Requested, t), what))
+		}
+	}
+	step(d.Requested, "requested")
+	step(d.Waiting, "waiting for approval")
+	if !d.Reviewed.IsZero() {
+		verdict := "approved"
+		if d.State == store.DeploymentRejected {
+			verdict = "rejected"
+		}
+		if d.Approver != "" {
+			verdict += " by " + d.Approver
+		}
+		step(d.Reviewed, verdict)
+	}
+	step(d.Started, "in progress")
+	step(d.Finished, d.State)
+
+	previousURL, previousAt, previousSHA := "", "", previous.SHA
+	if !previous.Finished.IsZero() {
+		previousAt = previous.Finished.UTC().Format("2006-01-02 15:04 UTC")
+		previousURL = previous.URL
+		if previousURL == "" && previous.RepoURL != "" && previous.SHA != "" {
+			previousURL = previous.RepoURL + "/commit/" + previous.SHA
+		}
+	}
+	if len(previousSHA) > 7 {
+		previousSHA = previousSHA[:7]
+	}
+	url := d.URL
+	if url == "" && d.RepoURL != "" {
+		url = d.RepoURL + "/deployments"
+	}
+
+	id := ""
+	if d.ID != 0 {
+		id = strconv.FormatInt(d.ID, 10)
+	}
+	return map[string]any{
+		"deploy_id":               id,
+		"deploy_environment":      d.Environment,
+		"deploy_ref":              d.Ref,
+		"deploy_sha_short":        short,
+		"deploy_state":            d.State,
+		"deploy_creator":          d.Creator,
+		"deploy_approver":         d.Approver,
+		"deploy_approval_comment": d.ApprovalComment,
+		"deploy_url":              url,
+		"deploy_wait":             wait,
+		"deploy_run":              span(d.Started, d.Finished),
+		"deploy_total":            span(d.Requested, d.Finished),
+		"deploy_timeline_md":      joinOrDash(timeline, "\n"),
+		"deploy_previous_url":     previousURL,
+		"deploy_previous_ref":     previous.Ref,
+		"deploy_previous_sha":     previousSHA,
+		"deploy_previous_at":      previousAt,
+	}
+}
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hnrobert/feishu-github-tracker/internal/config"
+	"github.com/hnrobert/feishu-github-tracker/internal/logger"
+	"github.com/hnrobert/feishu-github-tracker/internal/store"
+)
+
+// SetDigests enables digest rules (repos.yaml `digest:`), buffering their
+// events in digests. Without it digest rules notify immediately.
+func (h *Handler) SetDigests(digests *store.Digests) {
+	h.digests = digests
+}
+
+// digested reports whether rule batches this event into a digest.
+func (h *Handler) digested(eventType string, rule *config.RepoPattern) bool {
+	return h.digests != nil && rule != nil && rule.Digested(eventType)
+}
+
+// bufferDigest adds the event to the rule's digest instead of notifying.
+func (h *Handler) bufferDigest(eventType string, payload map[string]any, rule *config.RepoPattern, targets []string) error {
+	item := digestItem(eventType, h.prepareTemplateData(eventType, payload))
+	logger.Info("Event matched: %s (rule: %s), buffering for digest", eventType, rule.Pattern)
+	if err := h.digests.Add(rule.Pattern, rule.Digest.WindowDuration(), targets, item); err != nil {
+		return fmt.Errorf("failed to buffer digest for rule %s: %w", rule.Pattern, err)
+	}
+	return nil
+}
+
+// digestItem summarizes an event's template data as one digest line.
+func digestItem(eventType string, data map[string]any) store.DigestItem {
+	item := store.DigestItem{
+		Event:   eventType,
+		Action:  firstString(data, "action"),
+		Repo:    firstString(data, "repo_full_name"),
+		RepoURL: firstString(data, "repo_url"),
+		Sender:  firstString(data, "sender_name"),
+		URL: firstString(data, "compare_url", "pr_url", "issue_url", "release_url", "discussion_url",
+			"workflow_run_url", "check_run.html_url", "forkee_url", "repo_url"),
+		At: time.Now(),
+	}
+	if eventType == "push" {
+		item.Title = fmt.Sprintf("%v commit(s) to %s", data["commits_count"], firstString(data, "branch_name", "ref"))
+	} else {
+		item.Title = firstString(data, "pr_title", "issue_title", "release_name", "release_tag", "discussion_title",
+			"workflow_name", "check_run.name", "check_suite.app.name", "forkee_full_name")
+	}
+	return item
+}
+
+// firstString returns the first non-empty string among the dotted paths.
+func firstString(data map[string]any, paths ...string) string {
+	for _, path := range paths {
+		if s, ok := valueAt(data, path).(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// valueAt returns the value at a dotted path such as "pull_request.user.login",
+// or nil.
+func valueAt(data map[string]any, path string) any {
+	var cur any = data
+	for _, part := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil
+		}
+		cur = m[part]
+	}
+	return cur
+}
+
+// RunDigests flushes due digests every interval until ctx is done.
+func (h *Handler) RunDigests(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			h.FlushDigests(now)
+		}
+	}
+}
+
+// FlushDigests sends one "digest" card for every buffer due at now. The
+// summary is exposed to templates as digest_* variables.
+func (h *Handler) FlushDigests(now time.Time) {
+	if h.digests == nil {
+		return
+	}
+	due, err := h.digests.TakeDue(now)
+	if err != nil {
+		logger.Warn("Failed to save digests: %v", err)
+	}
+	for _, b := range due {
+		payload, extra := digestData(b)
+		logger.Info("Sending digest of %d event(s) for rule %s", len(b.Items)+b.Dropped, b.Rule)
+		if err := h.sendNotificationWithData("digest", payload, b.Targets, extra); err != nil {
+			logger.Error("Failed to send digest for rule %s: %v", b.Rule, err)
+		}
+	}
+}
+
+// digestData builds the webhook-like payload (the repository, when all
+// items share one) and the digest_* template variables of a buffer.
+func digestData(b store.DigestBuffer) (map[string]any, map[string]any) {
+	payload := map[string]any{}
+	repos := map[string]bool{}
+	var md, text []string
+	for _, it := range b.Items {
+		repos[it.Repo] = true
+		event := it.Event
+		if it.Action != "" {
+			event += "/" + it.Action
+		}
+		who := it.Repo
+		if it.Sender != "" {
+			who += " · " + it.Sender
+		}
+		title := it.Title
+		text = append(text, fmt.Sprintf("- %s · %s%s", event, who, summarySuffix(title)))
+		if it.URL != "" {
+			if title == "" {
+				title = it.URL
+			}
+			title = fmt.Sprintf("[%s](%s)", title, it.URL)
+		}
+		md = append(md, fmt.Sprintf("- **%s** · %s%s", event, who, summarySuffix(title)))
+	}
+	if len(repos) == 1 && len(b.Items) > 0 {
+		payload["repository"] = map[string]any{"full_name": b.Items[0].Repo, "html_url": b.Items[0].RepoURL}
+	}
+
+	events := make([]string, 0, len(b.Counts))
+	total := 0
+	for event, n := range b.Counts {
+		events = append(events, fmt.Sprintf("%s ×%d", event, n))
+		total += n
+	}
+	sort.Strings(events)
+	if b.Dropped > 0 {
+		more := fmt.Sprintf("… and %d more", b.Dropped)
+		md, text = append(md, more), append(text, more)
+	}
+
+	extra := map[string]any{
+		"digest_rule":       b.Rule,
+		"digest_count":      total,
+		"digest_summary":    strings.Join(events, ", "),
+		"digest_items_md":   strings.Join(md, "\n"),
+		"digest_items_text": strings.Join(text, "\n"),
+		"digest_dropped":    b.Dropped,
+		"digest_start":      b.Start.Local().Format("2006-01-02 15:04"),
+		"digest_end":        b.Due.Local().Format("2006-01-02 15:04"),
+	}
+	return payload, extra
+}
+
+func summarySuffix(title string) string {
+	if title == "" {
+		return ""
+	}
+	return ": " + title
+}
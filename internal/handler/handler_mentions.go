@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hnrobert/feishu-github-tracker/internal/matcher"
+	"github.com/hnrobert/feishu-github-tracker/internal/store"
+)
+
+// prepareMentionData adds *_at variables that render as Feishu @mentions (in
+// lark_md) for GitHub users mapped in users.yaml, falling back to a GitHub
+// profile link for unmapped users. It also fills `mentions_at` according to
+// users.yaml `mentions:` (requested reviewers / assignees / the security
+// contacts on critical alerts; prepareBrokenMentionData handles the CI
+// contacts when a workflow breaks) and adds the oncall.yaml on-call mentions.
+func (h *Handler) prepareMentionData(eventType string, data map[string]any, payload map[string]any) {
+	if sender, ok := payload["sender"].(map[string]any); ok {
+		if login, ok := sender["login"].(string); ok && login != "" {
+			data["sender_at"] = h.userAt(login)
+		}
+	}
+
+	if pr, ok := payload["pull_request"].(map[string]any); ok {
+		if login := userLogin(pr["user"]); login != "" {
+			data["pr_user_at"] = h.userAt(login)
+		}
+		data["pr_reviewers_at"] = h.usersAt(userLogins(pr["requested_reviewers"]))
+		data["pr_assignees_at"] = h.usersAt(userLogins(pr["assignees"]))
+	}
+
+	if issue, ok := payload["issue"].(map[string]any); ok {
+		if login := userLogin(issue["user"]); login != "" {
+			data["issue_user_at"] = h.userAt(login)
+		}
+		data["issue_assignees_at"] = h.usersAt(userLogins(issue["assignees"]))
+	}
+
+	if login := userLogin(payload["assignee"]); login != "" {
+		data["assignee_at"] = h.userAt(login)
+	}
+	if login := userLogin(payload["requested_reviewer"]); login != "" {
+		data["requested_reviewer_at"] = h.userAt(login)
+	}
+
+	mentions := h.Config().Users.Mentions
+	action, _ := payload["action"].(string)
+	switch {
+	case mentions.ReviewRequested && eventType == "pull_request" && action == "review_requested":
+		// fall back to every pending reviewer when the payload names a team
+		if login := userLogin(payload["requested_reviewer"]); login != "" {
+			h.setMentions(data, []string{login})
+		} else {
+			h.setMentions(data, userLogins(valueAt(payload, "pull_request.requested_reviewers")))
+		}
+	case mentions.Assigned && action == "assigned" && (eventType == "issues" || eventType == "pull_request"):
+		if login := userLogin(payload["assignee"]); login != "" {
+			h.setMentions(data, []string{login})
+		}
+	case len(mentions.Security) > 0 && matcher.AlertSeverity(eventType, payload) == "critical":
+		h.setMentions(data, h.expandOnCall(mentions.Security))
+	}
+
+	h.prepareOnCallData(data)
+}
+
+// setMentions sets mentions_at to the Feishu mentions of logins and
+// mentions_md to their GitHub profile links, for sinks that cannot render
+// Feishu mentions.
+func (h *Handler) setMentions(data map[string]any, logins []string) {
+	if len(logins) == 0 {
+		return
+	}
+	links := make([]string, 0, len(logins))
+	for _, login := range logins {
+		links = append(links, profileLink(login))
+	}
+	data["mentions_at"] = h.usersAt(logins)
+	data["mentions_md"] = strings.Join(links, " ")
+}
+
+// prepareBrokenMentionData fills `mentions_at` / `mentions_md` with the users.yaml
+// `mentions.broken` contacts when the workflow run broke the build. It runs
+// once the trackers' variables (ci_transition) are merged into data.
+func (h *Handler) prepareBrokenMentionData(eventType string, data map[string]any) {
+	broken := h.Config().Users.Mentions.Broken
+	if len(broken) > 0 && eventType == "workflow_run" && data["ci_transition"] == store.TransitionBroken {
+		h.setMentions(data, h.expandOnCall(broken))
+	}
+}
+
+// prepareOnCallData exposes who is on call now for every oncall.yaml schedule
+// as oncall.<schedule> (a mention) and oncall_login.<schedule>.
+func (h *Handler) prepareOnCallData(data map[string]any) {
+	if len(h.Config().OnCall.Schedules) == 0 {
+		return
+	}
+	now := time.Now()
+	mentions := map[string]any{}
+	logins := map[string]any{}
+	for i := range h.Config().OnCall.Schedules {
+		s := &h.Config().OnCall.Schedules[i]
+		login, _ := s.OnCall(now)
+		mentions[s.Name] = h.userAt(login)
+		logins[s.Name] = login
+	}
+	data["oncall"] = mentions
+	data["oncall_login"] = logins
+}
+
+// expandOnCall replaces "oncall:<schedule>" entries of a users.yaml mention
+// list with the login on call now; unknown schedules are dropped.
+func (h *Handler) expandOnCall(logins []string) []string {
+	var out []string
+	for _, login := range logins {
+		name, ok := strings.CutPrefix(login, "oncall:")
+		if !ok {
+			out = append(out, login)
+			continue
+		}
+		if s := h.Config().OnCall.Schedule(name); s != nil {
+			current, _ := s.OnCall(time.Now())
+			out = append(out, current)
+		}
+	}
+	return out
+}
+
+// userAt renders a lark_md mention for a mapped GitHub login, or a markdown
+// link to the GitHub profile when the login has no Feishu mapping.
+func (h *Handler) userAt(login string) string {
+	if u, ok := h.Config().LookupUser(login); ok {
+		switch {
+		case u.OpenID != "":
+			return fmt.Sprintf("<at id=%s></at>", u.OpenID)
+		case u.UserID != "":
+			return fmt.Sprintf("<at id=%s></at>", u.UserID)
+		case u.Email != "":
+			return fmt.Sprintf("<at email=%s></at>", u.Email)
+		}
+	}
+	return profileLink(login)
+}
+
+// profileLink renders a markdown link to the GitHub profile of login.
+func profileLink(login string) string {
+	return fmt.Sprintf("[%s](https://github.com/%s)", login, login)
+}
+
+// usersAt joins the mentions of several logins with spaces.
+func (h *Handler) usersAt(logins []string) string {
+	var parts []string
+	for _, login := range logins {
+		parts = append(parts, h.userAt(login))
+	}
+	return strings.Join(parts, " ")
+}
+
+// userLogin returns the login of a GitHub user object.
+func userLogin(v any) string {
+	if user, ok := v.(map[string]any); ok {
+		if login, ok := user["login"].(string); ok {
+			return login
+		}
+	}
+	return ""
+}
+
+// userLogins returns the logins of a list of GitHub user objects.
+func userLogins(v any) []string {
+	list, _ := v.([]any)
+	var logins []string
+	for _, item := range list {
+		if login := userLogin(item); login != "" {
+			logins = append(logins, login)
+		}
+	}
+	return logins
+}
@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"context"
+	"maps"
+	"time"
+
+	"github.com/hnrobert/feishu-github-tracker/internal/config"
+	"github.com/hnrobert/feishu-github-tracker/internal/logger"
+	"github.com/hnrobert/feishu-github-tracker/internal/matcher"
+	"github.com/hnrobert/feishu-github-tracker/internal/store"
+)
+
+// SetHeld enables quiet hours (feishu-bots.yaml and repos.yaml
+// `quiet_hours:`), keeping the deliveries held during them in held. Without
+// it quiet hours are ignored.
+func (h *Handler) SetHeld(held *store.Held) {
+	h.held = held
+}
+
+// quietHours returns the quiet hours that apply to target for a delivery
+// about repo: those of the first matching rule that sets quiet_hours,
+// otherwise the bot's own.
+func (h *Handler) quietHours(repo, target string) *config.QuietHours {
+	if repo != "" {
+		rules, err := matcher.MatchAllRepos(repo, h.Config().Repos.Repos)
+		if err == nil && !h.Config().Server.Server.MatchAllRules && len(rules) > 1 {
+			rules = rules[:1]
+		}
+		for _, rule := range rules {
+			if rule.QuietHours != nil {
+				return rule.QuietHours
+			}
+		}
+	}
+	return h.Config().GetBotQuietHours(target)
+}
+
+// holdQuiet holds the delivery for the targets inside their quiet hours at
+// now, unless the event or one of its tags bypasses them, and returns the
+// targets to send to right away. Only the delivery's summary line is kept
+// (see FlushHeld).
+func (h *Handler) holdQuiet(eventType string, payload map[string]any, targets []string, extra map[string]any, tags []string) []string {
+	if h.held == nil {
+		return targets
+	}
+	now := time.Now()
+	repo := h.extractRepoFullName(payload)
+	var send, quiet []string
+	for _, target := range targets {
+		q := h.quietHours(repo, target)
+		if q != nil && q.Contains(now) && !q.Bypassed(eventType, tags) {
+			quiet = append(quiet, target)
+		} else {
+			send = append(send, target)
+		}
+	}
+	if len(quiet) == 0 {
+		return send
+	}
+	logger.Info("Quiet hours: holding %s for %v", eventType, quiet)
+	data := h.prepareTemplateData(eventType, payload)
+	maps.Copy(data, extra)
+	dropped, err := h.held.Hold(store.HeldDelivery{DigestItem: digestItem(eventType, data), Targets: quiet})
+	if err != nil {
+		logger.Warn("Failed to save held deliveries: %v", err)
+	}
+	if dropped {
+		logger.Warn("Too many deliveries held for quiet hours, dropped the oldest")
+	}
+	return send
+}
+
+// RunQuietHours sends the held deliveries of targets whose quiet hours have
+// ended, every interval, until ctx is done.
+func (h *Handler) RunQuietHours(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			h.FlushHeld(now)
+		}
+	}
+}
+
+// quietDigestRule is the digest_rule of the summary of held deliveries.
+const quietDigestRule = "quiet hours"
+
+// FlushHeld sends every target no longer inside its quiet hours at now one
+// "digest" card summarizing its held deliveries in arrival order.
+// Suppressions and maintenance mode active at that point still apply.
+func (h *Handler) FlushHeld(now time.Time) {
+	if h.held == nil {
+		return
+	}
+	released, err := h.held.Release(func(d store.HeldDelivery, target string) bool {
+		q := h.quietHours(d.Repo, target)
+		return q == nil || !q.Contains(now)
+	})
+	if err != nil {
+		logger.Warn("Failed to save held deliveries: %v", err)
+	}
+	if len(released) == 0 {
+		return
+	}
+	logger.Info("Quiet hours ended, sending %d held deliveries", len(released))
+
+	var active []store.Suppression
+	if h.suppressions != nil {
+		active = h.suppressions.Active(time.Now())
+	}
+	var order []string
+	summaries := map[string]*store.DigestBuffer{}
+	for _, d := range released {
+		for _, target := range d.Targets {
+			if h.suppressed(active, d.Event, d.Repo, target) {
+				continue
+			}
+			b, ok := summaries[target]
+			if !ok {
+				b = &store.DigestBuffer{Rule: quietDigestRule, Targets: []string{target}, Start: d.At, Due: now}
+				summaries[target] = b
+				order = append(order, target)
+			}
+			b.Append(d.DigestItem)
+		}
+	}
+	for _, target := range order {
+		payload, extra := digestData(*summaries[target])
+		targets := h.holdDelivery("digest", payload, []string{target}, extra)
+		if len(targets) == 0 {
+			continue
+		}
+		if err := h.deliver("digest", payload, targets, extra, eventTags("digest", payload, extra)); err != nil {
+			logger.Error("Failed to send held deliveries to %s: %v", target, err)
+		}
+	}
+}
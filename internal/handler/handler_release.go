@@ -1,5 +1,73 @@
 package handler
 
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hnrobert/feishu-github-tracker/internal/store"
+)
+
+// maxChangelogBytes caps release_changelog_md; Feishu rejects cards larger
+// than 30 KB and the release body shares the card.
+const maxChangelogBytes = 6000
+
+// maxChangelogMrkdwnBytes caps release_changelog_mrkdwn, which has to fit a
+// Slack section block (3000 characters) next to the release body.
+const maxChangelogMrkdwnBytes = 2000
+
+// maxFirstChangelogPRs caps the changelog of a release with no earlier one
+// in the history, which would otherwise list every merge recorded so far.
+const maxFirstChangelogPRs = 20
+
+// changelogCategories are the changelog sections, in display order. A pull
+// request marked breaking ("feat!: ...") goes to the first; others go to the
+// first category with one of their labels, else to the one of their
+// conventional-commit title prefix, else to "Other".
+var changelogCategories = []struct {
+	Title    string
+	Labels   []string
+	Prefixes []string
+}{
+	{"⚠️ Breaking Changes", []string{"breaking", "breaking-change", "breaking change"}, nil},
+	{"🚀 Features", []string{"feature", "enhancement", "feat"}, []string{"feat"}},
+	{"🐛 Bug Fixes", []string{"bug", "bugfix", "fix"}, []string{"fix"}},
+	{"⚡ Performance", []string{"performance", "perf"}, []string{"perf"}},
+	{"📝 Documentation", []string{"documentation", "docs"}, []string{"docs"}},
+	{"📦 Dependencies", []string{"dependencies", "deps"}, []string{"deps"}},
+	{"🔧 Maintenance", []string{"chore", "refactor", "ci", "build", "test", "maintenance"}, []string{"chore", "refactor", "ci", "build", "test", "style"}},
+}
+
+// changelogSyntax is the markup a changelog is rendered in.
+type changelogSyntax struct {
+	maxBytes int
+	bold     func(text string) string
+	link     func(text, url string) string
+	escape   func(text string) string
+}
+
+// markdownChangelog renders CommonMark, for Feishu, DingTalk, WeCom and
+// Teams cards.
+var markdownChangelog = changelogSyntax{
+	maxBytes: maxChangelogBytes,
+	bold:     func(text string) string { return "**" + text + "**" },
+	link:     func(text, url string) string { return "[" + text + "](" + url + ")" },
+	escape:   func(text string) string { return text },
+}
+
+// mrkdwnChangelog renders Slack mrkdwn.
+var mrkdwnChangelog = changelogSyntax{
+	maxBytes: maxChangelogMrkdwnBytes,
+	bold:     func(text string) string { return "*" + text + "*" },
+	link:     func(text, url string) string { return "<" + url + "|" + text + ">" },
+	escape:   strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace,
+}
+
+// conventionalTitle matches "type(scope)!: subject" PR titles.
+var conventionalTitle = regexp.MustCompile(`^([a-zA-Z]+)(?:\(([^)]*)\))?(!)?:\s*(.+)$`)
+
 // prepareReleaseData handles release event fields
 func prepareReleaseData(data map[string]any, payload map[string]any) {
 	if release, ok := payload["release"].(map[string]any); ok {
@@ -10,3 +78,155 @@ func prepareReleaseData(data map[string]any, payload map[string]any) {
 		data["release"] = release
 	}
 }
+
+// buildReleaseChangelog returns the release_changelog_* template variables
+// (md, mrkdwn, count, contributors, previous_tag, compare_url) of a published
+// release: the pull requests merged since the previous recorded release, by
+// category. It relies on the event history, so it must run before the
+// release itself is recorded and only covers the history retention; without
+// an earlier release only the latest maxFirstChangelogPRs merges are listed.
+// Other events get nil.
+func (h *Handler) buildReleaseChangelog(eventType string, payload map[string]any) map[string]any {
+	if h.history == nil || eventType != "release" || h.extractAction(payload) != "published" {
+		return nil
+	}
+	repo := h.extractRepoFullName(payload)
+	tag := firstString(payload, "release.tag_name")
+	if repo == "" || tag == "" {
+		return nil
+	}
+	events := h.history.Between(time.Time{}, time.Now())
+	var previous store.HistoryEvent
+	for _, ev := range events {
+		if ev.Kind == store.KindRelease && ev.Repo == repo && ev.Tag != tag {
+			previous = ev
+		}
+	}
+	var merged []store.HistoryEvent
+	for _, ev := range events {
+		if ev.Kind == store.KindPRMerged && ev.Repo == repo && ev.At.After(previous.At) {
+			merged = append(merged, ev)
+		}
+	}
+	omitted := 0
+	if previous.Tag == "" && len(merged) > maxFirstChangelogPRs {
+		omitted = len(merged) - maxFirstChangelogPRs
+		merged = merged[omitted:]
+	}
+	compareURL := ""
+	if repoURL := firstString(payload, "repository.html_url"); repoURL != "" && previous.Tag != "" {
+		compareURL = repoURL + "/compare/" + previous.Tag + "..." + tag
+	}
+	md, contributors := changelogMarkdown(merged, omitted, compareURL, markdownChangelog)
+	mrkdwn, _ := changelogMarkdown(merged, omitted, compareURL, mrkdwnChangelog)
+	return map[string]any{
+		"release_changelog_md":           md,
+		"release_changelog_mrkdwn":       mrkdwn,
+		"release_changelog_count":        len(merged),
+		"release_changelog_contributors": contributors,
+		"release_changelog_previous_tag": previous.Tag,
+		"release_changelog_compare_url":  compareURL,
+	}
+}
+
+// changelogMarkdown renders merged pull requests in syntax as one bold
+// heading per category followed by "- subject ([#12](url)) @author" lines,
+// cut to syntax.maxBytes with a note (and compareURL, if any) for the rest
+// and the omitted earlier ones. It also returns the authors as "@alice,
+// @bob" in order of appearance.
+func changelogMarkdown(prs []store.HistoryEvent, omitted int, compareURL string, syntax changelogSyntax) (string, string) {
+	if len(prs) == 0 {
+		return "", ""
+	}
+	sections := make([][]string, len(changelogCategories)+1)
+	var authors []string
+	for _, pr := range prs {
+		category, subject := changelogCategory(pr)
+		line := "- " + syntax.escape(subject)
+		if pr.Number != 0 {
+			ref := fmt.Sprintf("#%d", pr.Number)
+			if pr.URL != "" {
+				ref = syntax.link(ref, pr.URL)
+			}
+			line += " (" + ref + ")"
+		}
+		if pr.Actor != "" {
+			line += " @" + pr.Actor
+			if !slices.Contains(authors, "@"+pr.Actor) {
+				authors = append(authors, "@"+pr.Actor)
+			}
+		}
+		sections[category] = append(sections[category], line)
+	}
+
+	var lines []string
+	for i, items := range sections {
+		if len(items) == 0 {
+			continue
+		}
+		title := "Other"
+		if i < len(changelogCategories) {
+			title = changelogCategories[i].Title
+		}
+		lines = append(lines, syntax.bold(title))
+		lines = append(lines, items...)
+	}
+
+	var out strings.Builder
+	rest := omitted
+	for i, line := range lines {
+		if out.Len()+len(line)+1 > syntax.maxBytes {
+			for _, l := range lines[i:] {
+				if strings.HasPrefix(l, "- ") {
+					rest++
+				}
+			}
+			break
+		}
+		out.WriteString(line + "\n")
+	}
+	if rest > 0 {
+		fmt.Fprintf(&out, "… and %d more", rest)
+		if compareURL != "" {
+			fmt.Fprintf(&out, " (%s)", syntax.link("full changelog", compareURL))
+		}
+	}
+	return strings.TrimSuffix(out.String(), "\n"), strings.Join(authors, ", ")
+}
+
+// changelogCategory returns the changelogCategories index of pr (the "Other"
+// index past the end if none applies) and its subject: the title without a
+// known conventional-commit prefix.
+func changelogCategory(pr store.HistoryEvent) (int, string) {
+	byPrefix, subject := -1, pr.Title
+	if m := conventionalTitle.FindStringSubmatch(pr.Title); m != nil {
+		for i, c := range changelogCategories {
+			if slices.Contains(c.Prefixes, strings.ToLower(m[1])) {
+				byPrefix = i
+			}
+		}
+		if m[3] != "" {
+			byPrefix = 0
+		}
+		if byPrefix >= 0 {
+			subject = m[4]
+			if m[2] != "" {
+				subject = m[2] + ": " + subject
+			}
+		}
+	}
+	if byPrefix == 0 {
+		return 0, subject
+	}
+	for i, c := range changelogCategories {
+		for _, label := range pr.Labels {
+			if slices.Contains(c.Labels, strings.ToLower(label)) {
+				return i, subject
+			}
+		}
+	}
+	if byPrefix >= 0 {
+		return byPrefix, subject
+	}
+	return len(changelogCategories), subject
+}
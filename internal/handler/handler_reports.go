@@ -0,0 +1,342 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hnrobert/feishu-github-tracker/internal/config"
+	"github.com/hnrobert/feishu-github-tracker/internal/logger"
+	"github.com/hnrobert/feishu-github-tracker/internal/matcher"
+	"github.com/hnrobert/feishu-github-tracker/internal/store"
+)
+
+// maxReportItems caps each list (merged PRs, releases, failed workflows) in
+// a report; the rest are only counted.
+const maxReportItems = 10
+
+// SetHistory enables the event history that scheduled reports (server.yaml
+// `reports:`) aggregate. Without it no report can be sent.
+func (h *Handler) SetHistory(history *store.History) {
+	h.history = history
+}
+
+// recordHistory records the events reports summarize (PRs, issues, releases,
+// failed workflow runs and pushes) for tracked repos, whatever the rules
+// subscribe to. Failures are only logged.
+func (h *Handler) recordHistory(eventType string, payload map[string]any) {
+	if h.history == nil {
+		return
+	}
+	repo := h.extractRepoFullName(payload)
+	if repo == "" {
+		return
+	}
+	if rule, err := matcher.MatchRepo(repo, h.Config().Repos.Repos); err != nil || rule == nil {
+		return
+	}
+	ev, ok := historyEvent(eventType, h.extractAction(payload), payload)
+	if !ok {
+		return
+	}
+	ev.Repo = repo
+	if err := h.history.Record(ev); err != nil {
+		logger.Warn("Failed to record %s event of %s: %v", eventType, repo, err)
+	}
+}
+
+// historyEvent converts a webhook into a history record, if it is one of the
+// kinds reports count.
+func historyEvent(eventType, action string, payload map[string]any) (store.HistoryEvent, bool) {
+	var ev store.HistoryEvent
+	switch {
+	case eventType == "pull_request" && action == "opened":
+		ev.Kind = store.KindPROpened
+	case eventType == "pull_request" && action == "closed":
+		ev.Kind = store.KindPRClosed
+		if merged, _ := valueAt(payload, "pull_request.merged").(bool); merged {
+			ev.Kind = store.KindPRMerged
+		}
+	case eventType == "issues" && action == "opened":
+		ev.Kind = store.KindIssueOpened
+	case eventType == "issues" && action == "closed":
+		ev.Kind = store.KindIssueClosed
+	case eventType == "release" && action == "published":
+		ev.Kind = store.KindRelease
+	case eventType == "workflow_run" && action == "completed" &&
+		firstString(payload, "workflow_run.conclusion") == "failure":
+		ev.Kind = store.KindWorkflowFailed
+	case eventType == "push":
+		commits, _ := payload["commits"].([]any)
+		if len(commits) == 0 {
+			return ev, false
+		}
+		ev.Kind, ev.Commits = store.KindPush, len(commits)
+	default:
+		return ev, false
+	}
+
+	switch eventType {
+	case "pull_request":
+		ev.Actor = firstString(payload, "pull_request.user.login")
+		ev.Title = firstString(payload, "pull_request.title")
+		ev.URL = firstString(payload, "pull_request.html_url")
+		ev.Number = intAt(payload, "pull_request.number")
+		labels, _ := valueAt(payload, "pull_request.labels").([]any)
+		for _, l := range labels {
+			if lm, ok := l.(map[string]any); ok {
+				if name := firstString(lm, "name"); name != "" {
+					ev.Labels = append(ev.Labels, name)
+				}
+			}
+		}
+	case "issues":
+		ev.Actor = firstString(payload, "issue.user.login")
+		ev.Title = firstString(payload, "issue.title")
+		ev.URL = firstString(payload, "issue.html_url")
+		ev.Number = intAt(payload, "issue.number")
+	case "release":
+		ev.Actor = firstString(payload, "release.author.login", "sender.login")
+		ev.Title = firstString(payload, "release.name", "release.tag_name")
+		ev.URL = firstString(payload, "release.html_url")
+		ev.Tag = firstString(payload, "release.tag_name")
+	case "workflow_run":
+		ev.Actor = firstString(payload, "workflow_run.actor.login", "sender.login")
+		ev.Title = firstString(payload, "workflow_run.name")
+		ev.URL = firstString(payload, "workflow_run.html_url")
+	case "push":
+		ev.Actor = firstString(payload, "sender.login", "pusher.name")
+		ev.Title = strings.TrimPrefix(firstString(payload, "ref"), "refs/heads/")
+		ev.URL = firstString(payload, "compare")
+	}
+	return ev, true
+}
+
+// intAt returns the JSON number at a dotted path as an int, or 0.
+func intAt(data map[string]any, path string) int {
+	n, _ := valueAt(data, path).(float64)
+	return int(n)
+}
+
+// RunReports sends each configured report when its schedule comes due,
+// checking every interval until ctx is done. A report is first scheduled
+// from the time it is seen, so runs missed while the tracker was stopped are
+// skipped; schedules edited on reload are picked up on the next check.
+func (h *Handler) RunReports(ctx context.Context, interval time.Duration) {
+	next := map[string]time.Time{}
+	h.runDueReports(time.Now(), next)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			h.runDueReports(now, next)
+		}
+	}
+}
+
+// runDueReports sends the reports due at now. next holds each report's next
+// run, keyed by name, schedule and timezone.
+func (h *Handler) runDueReports(now time.Time, next map[string]time.Time) {
+	reports := h.Config().Server.Reports
+	current := make(map[string]bool, len(reports))
+	for i := range reports {
+		r := &reports[i]
+		key := r.Name + "|" + r.Schedule + "|" + r.Timezone
+		current[key] = true
+		due, ok := next[key]
+		if ok && (due.IsZero() || now.Before(due)) {
+			continue
+		}
+		next[key] = r.Cron().Next(now.In(r.Location()))
+		if !ok {
+			continue
+		}
+		if err := h.SendReport(r, due); err != nil {
+			logger.Error("Failed to send report %s: %v", r.Name, err)
+		}
+	}
+	for key := range next {
+		if !current[key] {
+			delete(next, key)
+		}
+	}
+}
+
+// SendReport sends report r for its run at time at, covering the configured
+// period (or the time since the previous scheduled run) before at. The
+// summary is exposed to the "report" template as report_* variables.
+func (h *Handler) SendReport(r *config.ReportConfig, at time.Time) error {
+	if h.history == nil {
+		return fmt.Errorf("event history is not enabled")
+	}
+	at = at.In(r.Location())
+	from := at.Add(-r.PeriodDuration())
+	if r.PeriodDuration() == 0 {
+		if from = r.Cron().Prev(at); from.IsZero() {
+			from = at.Add(-24 * time.Hour)
+		}
+	}
+
+	covered := map[string]bool{}
+	var events []store.HistoryEvent
+	for _, ev := range h.history.Between(from, at) {
+		in, ok := covered[ev.Repo]
+		if !ok {
+			in = h.reportCovers(r, ev.Repo)
+			covered[ev.Repo] = in
+		}
+		if in {
+			events = append(events, ev)
+		}
+	}
+	logger.Info("Sending report %s: %d event(s) from %s to %s", r.Name, len(events), from.Format(time.RFC3339), at.Format(time.RFC3339))
+	return h.sendNotificationWithData("report", map[string]any{}, r.NotifyTo, reportData(r, events, from, at))
+}
+
+// reportCovers reports whether repo is in the scope of r: one of its repos
+// globs, or by default a repo whose rules notify one of its targets.
+func (h *Handler) reportCovers(r *config.ReportConfig, repo string) bool {
+	if len(r.Repos) > 0 {
+		patterns := make([]config.RepoPattern, len(r.Repos))
+		for i, p := range r.Repos {
+			patterns[i].Pattern = p
+		}
+		rule, err := matcher.MatchRepo(repo, patterns)
+		return err == nil && rule != nil
+	}
+	rules, err := matcher.MatchAllRepos(repo, h.Config().Repos.Repos)
+	if err != nil {
+		return false
+	}
+	for _, rule := range rules {
+		for _, target := range rule.NotifyTo {
+			if slices.Contains(r.NotifyTo, target) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// reportData aggregates events into the report_* template variables. Lists
+// come in a lark/markdown flavor (_md) and a plain-link flavor (_text).
+func reportData(r *config.ReportConfig, events []store.HistoryEvent, from, to time.Time) map[string]any {
+	counts := map[string]int{}
+	commits := 0
+	repos := map[string]bool{}
+	scores := map[string]int{}
+	var merged, releases, failed []store.HistoryEvent
+	for _, ev := range events {
+		counts[ev.Kind]++
+		repos[ev.Repo] = true
+		switch ev.Kind {
+		case store.KindPRMerged:
+			merged = append(merged, ev)
+		case store.KindRelease:
+			releases = append(releases, ev)
+		case store.KindWorkflowFailed:
+			failed = append(failed, ev)
+		case store.KindPush:
+			commits += ev.Commits
+		}
+		if ev.Actor != "" && ev.Kind != store.KindWorkflowFailed && !strings.HasSuffix(ev.Actor, "[bot]") {
+			scores[ev.Actor] += max(ev.Commits, 1)
+		}
+	}
+
+	top := r.TopContributors
+	if top <= 0 {
+		top = 5
+	}
+	logins := make([]string, 0, len(scores))
+	for login := range scores {
+		logins = append(logins, login)
+	}
+	sort.Slice(logins, func(i, j int) bool {
+		if scores[logins[i]] != scores[logins[j]] {
+			return scores[logins[i]] > scores[logins[j]]
+		}
+		return logins[i] < logins[j]
+	})
+	var contributors, contributorsMD []string
+	for i, login := range logins {
+		if i == top {
+			break
+		}
+		contributors = append(contributors, fmt.Sprintf("%s (%d)", login, scores[login]))
+		contributorsMD = append(contributorsMD, fmt.Sprintf("%d. [%s](https://github.com/%s) · %d", i+1, login, login, scores[login]))
+	}
+
+	mergedMD, mergedText := reportList(merged)
+	releasesMD, releasesText := reportList(releases)
+	failedMD, failedText := reportList(failed)
+	return map[string]any{
+		"report_name":             r.Name,
+		"report_start":            from.Format("2006-01-02 15:04"),
+		"report_end":              to.Format("2006-01-02 15:04"),
+		"report_timezone":         to.Location().String(),
+		"report_count":            len(events),
+		"report_repos":            len(repos),
+		"report_prs_opened":       counts[store.KindPROpened],
+		"report_prs_merged":       counts[store.KindPRMerged],
+		"report_prs_closed":       counts[store.KindPRClosed],
+		"report_issues_opened":    counts[store.KindIssueOpened],
+		"report_issues_closed":    counts[store.KindIssueClosed],
+		"report_releases":         counts[store.KindRelease],
+		"report_workflows_failed": counts[store.KindWorkflowFailed],
+		"report_commits":          commits,
+		"report_merged_md":        mergedMD,
+		"report_merged_text":      mergedText,
+		"report_releases_md":      releasesMD,
+		"report_releases_text":    releasesText,
+		"report_failed_md":        failedMD,
+		"report_failed_text":      failedText,
+		"report_contributors":     joinOrDash(contributors, ", "),
+		"report_contributors_md":  joinOrDash(contributorsMD, "\n"),
+	}
+}
+
+// reportList renders up to maxReportItems events as markdown and plain-text
+// bullet lists; "—" stands for an empty list.
+func reportList(events []store.HistoryEvent) (string, string) {
+	var md, text []string
+	for i, ev := range events {
+		if i == maxReportItems {
+			more := fmt.Sprintf("… and %d more", len(events)-maxReportItems)
+			md, text = append(md, more), append(text, more)
+			break
+		}
+		ref := ev.Repo
+		if ev.Number > 0 {
+			ref = fmt.Sprintf("%s#%d", ev.Repo, ev.Number)
+		}
+		title := ev.Title
+		link := title
+		if link == "" {
+			link = ref
+		}
+		if ev.URL != "" {
+			link = fmt.Sprintf("[%s](%s)", link, ev.URL)
+		}
+		if title == "" {
+			md = append(md, "- "+link)
+		} else {
+			md = append(md, fmt.Sprintf("- %s %s", ref, link))
+		}
+		text = append(text, strings.Join(strings.Fields(fmt.Sprintf("- %s %s %s", ref, title, ev.URL)), " "))
+	}
+	return joinOrDash(md, "\n"), joinOrDash(text, "\n")
+}
+
+func joinOrDash(items []string, sep string) string {
+	if len(items) == 0 {
+		return "—"
+	}
+	return strings.Join(items, sep)
+}
@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/hnrobert/feishu-github-tracker/internal/config"
+	"github.com/hnrobert/feishu-github-tracker/internal/logger"
+	"github.com/hnrobert/feishu-github-tracker/internal/matcher"
+	"github.com/hnrobert/feishu-github-tracker/internal/store"
+)
+
+// SetPullRequests enables review reminders (repos.yaml `stale_prs:`),
+// tracking the review state of open pull requests in pulls.
+func (h *Handler) SetPullRequests(pulls *store.PullRequests) {
+	h.pulls = pulls
+}
+
+// staleRule returns the rule whose stale_prs policy applies to repo: the
+// matching rule, or with match_all_rules the first matching rule that has a
+// policy. It returns nil when no policy applies.
+func (h *Handler) staleRule(repo string) *config.RepoPattern {
+	if h.Config().Server.Server.MatchAllRules {
+		rules, _ := matcher.MatchAllRepos(repo, h.Config().Repos.Repos)
+		for _, rule := range rules {
+			if rule.StalePRs != nil {
+				return rule
+			}
+		}
+		return nil
+	}
+	rule, _ := matcher.MatchRepo(repo, h.Config().Repos.Repos)
+	if rule != nil && rule.StalePRs != nil {
+		return rule
+	}
+	return nil
+}
+
+// trackPullRequest follows the review state of open pull requests in repos
+// with a stale_prs policy. A pull request waits for review from when it is
+// opened, reopened, marked ready or (after being reviewed) has a review
+// requested, until a review leaves no requested reviewer pending. Closing
+// it stops tracking. Failures are only logged.
+func (h *Handler) trackPullRequest(eventType string, payload map[string]any) {
+	if h.pulls == nil || (eventType != "pull_request" && eventType != "pull_request_review") {
+		return
+	}
+	key := h.threadKey(eventType, payload)
+	if key == "" {
+		return
+	}
+	action := h.extractAction(payload)
+	now := time.Now()
+	var err error
+	switch {
+	case eventType == "pull_request" && action == "closed":
+		err = h.pulls.Remove(key)
+	case h.staleRule(h.extractRepoFullName(payload)) == nil:
+		return
+	case eventType == "pull_request":
+		err = h.pulls.Update(key, func(pr *store.PullRequest, created bool) {
+			fillPullRequest(pr, payload)
+			if created {
+				pr.OpenedAt = now
+			}
+			switch {
+			case created, action == "opened", action == "reopened", action == "ready_for_review":
+				startWaiting(pr, now)
+			case action == "review_requested" && pr.WaitingSince.IsZero():
+				startWaiting(pr, now)
+			}
+		})
+	case action == "submitted":
+		reviewer := firstString(payload, "review.user.login")
+		_, err = h.pulls.Modify(key, func(pr *store.PullRequest) {
+			fillPullRequest(pr, payload)
+			if reviewer == "" || reviewer == pr.Author {
+				return
+			}
+			pr.Reviewers = slices.DeleteFunc(pr.Reviewers, func(login string) bool { return login == reviewer })
+			if len(pr.Reviewers) == 0 {
+				pr.WaitingSince = time.Time{}
+			}
+		})
+	}
+	if err != nil {
+		logger.Warn("Failed to track pull request %s: %v", key, err)
+	}
+}
+
+// fillPullRequest copies the pull request details of a webhook payload.
+func fillPullRequest(pr *store.PullRequest, payload map[string]any) {
+	pr.Repo = firstString(payload, "repository.full_name")
+	pr.RepoURL = firstString(payload, "repository.html_url")
+	pr.Number = intAt(payload, "pull_request.number")
+	pr.Title = firstString(payload, "pull_request.title")
+	pr.URL = firstString(payload, "pull_request.html_url")
+	pr.Author = firstString(payload, "pull_request.user.login")
+	pr.Draft, _ = valueAt(payload, "pull_request.draft").(bool)
+	pr.Reviewers = userLogins(valueAt(payload, "pull_request.requested_reviewers"))
+}
+
+// startWaiting restarts the review wait (and the reminder count) at now.
+func startWaiting(pr *store.PullRequest, now time.Time) {
+	pr.WaitingSince = now
+	pr.LastReminder = time.Time{}
+	pr.Reminders = 0
+}
+
+// RunReminders sends due review reminders every interval until ctx is done.
+func (h *Handler) RunReminders(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			h.SendReminders(now)
+		}
+	}
+}
+
+// SendReminders sends a "stale_pr" card to the rule's targets for every pull
+// request that is due a reminder at now.
+func (h *Handler) SendReminders(now time.Time) {
+	if h.pulls == nil {
+		return
+	}
+	keys, pulls := h.pulls.List()
+	for i, pr := range pulls {
+		rule := h.staleRule(pr.Repo)
+		if rule == nil || rule.Muted(now) || !reminderDue(&pr, rule.StalePRs, now) {
+			continue
+		}
+		payload, extra := h.reminderData(keys[i], pr, now)
+		logger.Info("Sending review reminder for %s (waiting %s)", keys[i], extra["stale_age"])
+		if err := h.sendNotificationWithData("stale_pr", payload, rule.NotifyTo, extra); err != nil {
+			logger.Error("Failed to send review reminder for %s: %v", keys[i], err)
+			continue
+		}
+		if _, err := h.pulls.Modify(keys[i], func(p *store.PullRequest) {
+			p.LastReminder = now
+			p.Reminders++
+		}); err != nil {
+			logger.Warn("Failed to record reminder for %s: %v", keys[i], err)
+		}
+	}
+}
+
+// reminderDue reports whether pr should be reminded at now under policy.
+func reminderDue(pr *store.PullRequest, policy *config.StalePRConfig, now time.Time) bool {
+	switch {
+	case !pr.Waiting(), now.Before(pr.SnoozedUntil), now.Sub(pr.WaitingSince) < policy.AfterDuration():
+		return false
+	case !pr.LastReminder.IsZero() && now.Sub(pr.LastReminder) < policy.RepeatDuration():
+		return false
+	case policy.WorkingHours != nil && !policy.WorkingHours.Contains(now):
+		return false
+	}
+	return true
+}
+
+// reminderData rebuilds a pull_request-like payload from the tracked state,
+// so the usual pr_* and pr_reviewers_at variables work, plus the stale_*
+// variables.
+func (h *Handler) reminderData(key string, pr store.PullRequest, now time.Time) (map[string]any, map[string]any) {
+	reviewers := make([]any, 0, len(pr.Reviewers))
+	for _, login := range pr.Reviewers {
+		reviewers = append(reviewers, map[string]any{"login": login})
+	}
+	payload := map[string]any{
+		"repository": map[string]any{"full_name": pr.Repo, "html_url": pr.RepoURL},
+		"pull_request": map[string]any{
+			"number":              float64(pr.Number),
+			"title":               pr.Title,
+			"html_url":            pr.URL,
+			"state":               "open",
+			"user":                map[string]any{"login": pr.Author, "html_url": "https://github.com/" + pr.Author},
+			"requested_reviewers": reviewers,
+		},
+	}
+	reviewersAt := h.usersAt(pr.Reviewers)
+	if reviewersAt == "" {
+		reviewersAt = "—"
+	}
+	extra := map[string]any{
+		"stale_key":          key,
+		"stale_age":          formatAge(now.Sub(pr.WaitingSince)),
+		"stale_since":        pr.WaitingSince.Local().Format("2006-01-02 15:04"),
+		"stale_reminder":     pr.Reminders + 1,
+		"stale_reviewers":    joinOrDash(pr.Reviewers, ", "),
+		"stale_reviewers_at": reviewersAt,
+	}
+	return payload, extra
+}
+
+// formatAge renders a duration as "2d 3h", "5h 10m" or "12m".
+func formatAge(d time.Duration) string {
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	}
+	return fmt.Sprintf("%dm", minutes)
+}
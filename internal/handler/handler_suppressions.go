@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/hnrobert/feishu-github-tracker/internal/config"
+	"github.com/hnrobert/feishu-github-tracker/internal/logger"
+	"github.com/hnrobert/feishu-github-tracker/internal/matcher"
+	"github.com/hnrobert/feishu-github-tracker/internal/store"
+)
+
+// SetSuppressions enables the runtime suppression list and maintenance mode
+// kept in suppressions (managed from the panel). Without it every delivery
+// is sent.
+func (h *Handler) SetSuppressions(suppressions *store.Suppressions) {
+	h.suppressions = suppressions
+}
+
+// holdDelivery applies maintenance mode and the active suppressions to a
+// delivery. While maintenance mode is on the whole delivery is queued and
+// nothing is returned; otherwise it returns the targets not suppressed,
+// recording the others.
+func (h *Handler) holdDelivery(eventType string, payload map[string]any, targets []string, extra map[string]any) []string {
+	if h.suppressions == nil {
+		return targets
+	}
+	now := time.Now()
+	if h.suppressions.Maintenance().Active(now) {
+		logger.Info("Maintenance mode is on, queueing %s for %d target(s)", eventType, len(targets))
+		dropped, err := h.suppressions.Queue(store.QueuedDelivery{Event: eventType, Payload: payload, Targets: targets, Extra: extra})
+		if err != nil {
+			logger.Warn("Failed to save maintenance queue: %v", err)
+		}
+		if dropped {
+			logger.Warn("Maintenance queue is full, dropped the oldest delivery")
+		}
+		return nil
+	}
+
+	active := h.suppressions.Active(now)
+	if len(active) == 0 {
+		return targets
+	}
+	repo := h.extractRepoFullName(payload)
+	var kept []string
+	for _, target := range targets {
+		if !h.suppressed(active, eventType, repo, target) {
+			kept = append(kept, target)
+		}
+	}
+	return kept
+}
+
+// suppressed reports whether one of the active suppressions drops the
+// delivery of eventType about repo to target, recording it if so.
+func (h *Handler) suppressed(active []store.Suppression, eventType, repo, target string) bool {
+	sup, ok := matchSuppression(active, eventType, repo, target)
+	if !ok {
+		return false
+	}
+	logger.Info("Suppressed %s for %s (repo: %s, suppression: %s)", eventType, target, repo, sup.ID)
+	if err := h.suppressions.Record(store.SuppressedDelivery{Event: eventType, Repo: repo, Target: target, Suppression: sup.ID}); err != nil {
+		logger.Warn("Failed to record suppressed delivery: %v", err)
+	}
+	return true
+}
+
+// matchSuppression returns the first suppression whose repo glob, event type
+// and target (each when set) all match the delivery.
+func matchSuppression(active []store.Suppression, eventType, repo, target string) (store.Suppression, bool) {
+	for _, sup := range active {
+		if sup.Event != "" && sup.Event != eventType {
+			continue
+		}
+		if sup.Target != "" && sup.Target != target {
+			continue
+		}
+		if sup.Repo != "" {
+			rule, err := matcher.MatchRepo(repo, []config.RepoPattern{{Pattern: sup.Repo}})
+			if err != nil || rule == nil {
+				continue
+			}
+		}
+		return sup, true
+	}
+	return store.Suppression{}, false
+}
+
+// RunSuppressions sends the deliveries queued during maintenance mode, every
+// interval, once it is lifted or expires, until ctx is done.
+func (h *Handler) RunSuppressions(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			h.FlushQueued(now)
+		}
+	}
+}
+
+// FlushQueued sends the queued deliveries in arrival order if maintenance
+// mode is no longer active at now. Suppressions active at that point still
+// apply.
+func (h *Handler) FlushQueued(now time.Time) {
+	if h.suppressions == nil {
+		return
+	}
+	queue, err := h.suppressions.TakeQueued(now)
+	if err != nil {
+		logger.Warn("Failed to save maintenance queue: %v", err)
+	}
+	if len(queue) > 0 {
+		logger.Info("Maintenance mode ended, sending %d queued deliveries", len(queue))
+	}
+	for _, d := range queue {
+		if err := h.sendNotificationWithData(d.Event, d.Payload, d.Targets, d.Extra); err != nil {
+			logger.Error("Failed to send queued %s: %v", d.Event, err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hnrobert/feishu-github-tracker/internal/feishu"
+	"github.com/hnrobert/feishu-github-tracker/internal/logger"
+	"github.com/hnrobert/feishu-github-tracker/internal/matcher"
+	"github.com/hnrobert/feishu-github-tracker/internal/store"
+)
+
+// SetTasks enables Feishu Task sync for issues (users.yaml tasks.enabled),
+// recording the task created for each issue assignee in tasks.
+func (h *Handler) SetTasks(tasks *store.Tasks) {
+	h.tasks = tasks
+}
+
+// syncIssueTasks mirrors issue assignment and state onto Feishu Tasks: an
+// assigned event creates a task for the (mapped) assignee, closed completes
+// the issue's tasks and reopened reopens them. It runs for the issues events
+// a rule of the repo subscribes to, whether or not that rule notifies (it may
+// be muted or digested), and only logs failures so task sync never fails the
+// webhook.
+func (h *Handler) syncIssueTasks(eventType string, payload map[string]any) {
+	if eventType != "issues" || h.tasks == nil || !h.Config().Users.Tasks.Enabled {
+		return
+	}
+	key := h.threadKey(eventType, payload)
+	if key == "" || !h.subscribed(eventType, payload) {
+		return
+	}
+	app := h.currentNotifier().App()
+	if app == nil {
+		logger.Warn("Task sync is enabled but no feishu_app is configured, skipping %s", key)
+		return
+	}
+
+	switch h.extractAction(payload) {
+	case "assigned":
+		h.createIssueTask(app, key, payload)
+	case "closed":
+		h.setIssueTasksCompleted(app, key, true)
+	case "reopened":
+		h.setIssueTasksCompleted(app, key, false)
+	}
+}
+
+// subscribed reports whether a rule matching the event's repository
+// subscribes to the event.
+func (h *Handler) subscribed(eventType string, payload map[string]any) bool {
+	rules, err := matcher.MatchAllRepos(h.extractRepoFullName(payload), h.Config().Repos.Repos)
+	if err != nil {
+		return false
+	}
+	action, ref := h.extractAction(payload), h.extractRef(payload)
+	for _, rule := range rules {
+		expandedEvents := matcher.ExpandEvents(rule.Events, h.Config().Events.EventSets, h.Config().Events.Events)
+		if matcher.MatchEvent(eventType, action, ref, payload, nil, expandedEvents) {
+			return true
+		}
+	}
+	return false
+}
+
+// createIssueTask creates a task for the assignee of an assigned event, unless
+// they already have one for this issue or have no open_id/user_id mapping.
+func (h *Handler) createIssueTask(app *feishu.Client, key string, payload map[string]any) {
+	login := userLogin(payload["assignee"])
+	if login == "" || h.tasks.Task(key, login) != "" {
+		return
+	}
+	user, ok := h.Config().LookupUser(login)
+	task := feishu.Task{TasklistGUID: h.Config().Users.Tasks.TasklistGUID}
+	switch {
+	case !ok:
+		logger.Debug("Assignee %s of %s is not mapped in users.yaml, no task created", login, key)
+		return
+	case user.OpenID != "":
+		task.AssigneeIDType, task.AssigneeID = feishu.ReceiveIDOpen, user.OpenID
+	case user.UserID != "":
+		task.AssigneeIDType, task.AssigneeID = feishu.ReceiveIDUser, user.UserID
+	default:
+		logger.Debug("Assignee %s of %s has no open_id or user_id, no task created", login, key)
+		return
+	}
+
+	data := make(map[string]any)
+	prepareIssuesData(data, payload)
+	title, _ := data["issue_title"].(string)
+	issueURL, _ := data["issue_url"].(string)
+	body, _ := data["issue_body"].(string)
+	task.Summary = fmt.Sprintf("[%s] %s", key, title)
+	task.Description = issueURL
+	if body != "" {
+		task.Description += "\n\n" + body
+	}
+	task.LinkURL, task.LinkTitle = issueURL, key
+
+	guid, err := app.CreateTask(task)
+	if err != nil {
+		logger.Error("Failed to create task for %s on %s: %v", login, key, err)
+		return
+	}
+	logger.Info("Created Feishu task %s for %s on %s", guid, login, key)
+	if err := h.tasks.SetTask(key, login, guid); err != nil {
+		logger.Warn("Failed to record task %s for %s: %v", guid, key, err)
+	}
+}
+
+// setIssueTasksCompleted completes or reopens every task of an issue.
+func (h *Handler) setIssueTasksCompleted(app *feishu.Client, key string, completed bool) {
+	now := time.Now()
+	for login, guid := range h.tasks.IssueTasks(key) {
+		if err := app.SetTaskCompleted(guid, completed, now); err != nil {
+			logger.Error("Failed to update task %s of %s for %s: %v", guid, login, key, err)
+			continue
+		}
+		logger.Info("Task %s of %s for %s is now completed=%v", guid, login, key, completed)
+	}
+}
@@ -1,10 +1,18 @@
 package handler
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
 	"testing"
+	"time"
 
 	"net/url"
 	"strings"
@@ -12,6 +20,7 @@ import (
 	"github.com/hnrobert/feishu-github-tracker/internal/config"
 	"github.com/hnrobert/feishu-github-tracker/internal/logger"
 	"github.com/hnrobert/feishu-github-tracker/internal/notifier"
+	"github.com/hnrobert/feishu-github-tracker/internal/store"
 )
 
 func TestPrepareTemplateData_IncludesNestedObjects(t *testing.T) {
@@ -302,3 +311,1395 @@ func TestPrepareTemplateData_PackageURL(t *testing.T) {
 		t.Fatalf("package_link_md mismatch: got %q want %q", s, want)
 	}
 }
+
+func TestProcessWebhookLabelRoutes(t *testing.T) {
+	logger.Init("error", os.TempDir())
+	received := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received[r.URL.Path]++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Repos: config.ReposConfig{Repos: []config.RepoPattern{{
+			Pattern:  "org/repo",
+			Events:   map[string]any{"issues": nil, "pull_request": nil},
+			NotifyTo: []string{"triage"},
+			LabelRoutes: []config.LabelRoute{
+				{Labels: []string{"area/infra*"}, NotifyTo: []string{"sre"}},
+				{Labels: []string{"security"}, NotifyTo: []string{"sec"}},
+			},
+		}}},
+		FeishuBots: config.FeishuBotsConfig{FeishuBots: []config.FeishuBot{
+			{Alias: "triage", URL: server.URL + "/triage"},
+			{Alias: "sre", URL: server.URL + "/sre"},
+			{Alias: "sec", URL: server.URL + "/sec"},
+		}},
+		Templates: map[string]config.TemplatesConfig{"default": {
+			Templates: map[string]config.EventTemplate{
+				"issues":       {Payloads: []config.PayloadTemplate{{Tags: []string{"default"}, Payload: map[string]any{"msg_type": "text"}}}},
+				"pull_request": {Payloads: []config.PayloadTemplate{{Tags: []string{"default"}, Payload: map[string]any{"msg_type": "text"}}}},
+			},
+		}},
+	}
+	h := New(cfg, notifier.New(cfg.FeishuBots))
+
+	issue := map[string]any{
+		"action":     "opened",
+		"repository": map[string]any{"full_name": "org/repo"},
+		"issue": map[string]any{"number": 1, "labels": []any{
+			map[string]any{"name": "area/infra-db"},
+			map[string]any{"name": "security"},
+		}},
+	}
+	if err := h.processWebhook("issues", issue); err != nil {
+		t.Fatalf("processWebhook returned error: %v", err)
+	}
+	if received["/triage"] != 1 || received["/sre"] != 1 || received["/sec"] != 1 {
+		t.Fatalf("opened issue delivered %#v, want triage, sre and sec once each", received)
+	}
+
+	// A labeled action only routes by the label that was just added.
+	for k := range received {
+		delete(received, k)
+	}
+	pr := map[string]any{
+		"action":       "labeled",
+		"repository":   map[string]any{"full_name": "org/repo"},
+		"label":        map[string]any{"name": "security"},
+		"pull_request": map[string]any{"number": 2, "labels": []any{map[string]any{"name": "area/infra"}, map[string]any{"name": "security"}}},
+	}
+	if err := h.processWebhook("pull_request", pr); err != nil {
+		t.Fatalf("processWebhook returned error: %v", err)
+	}
+	if received["/triage"] != 1 || received["/sec"] != 1 || received["/sre"] != 0 {
+		t.Fatalf("labeled PR delivered %#v, want triage and sec only", received)
+	}
+}
+
+func TestProcessWebhookOwnershipRouting(t *testing.T) {
+	logger.Init("error", os.TempDir())
+	bodies := make(map[string][]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies[r.URL.Path] = append(bodies[r.URL.Path], string(body))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Repos: config.ReposConfig{Repos: []config.RepoPattern{{
+			Pattern:  "org/repo",
+			Events:   map[string]any{"push": nil},
+			NotifyTo: []string{"all"},
+			Owners: []config.OwnerRule{
+				{Paths: []string{"/api/"}, NotifyTo: []string{"backend"}},
+				{Paths: []string{"*.tsx"}, NotifyTo: []string{"frontend"}},
+			},
+		}}},
+		FeishuBots: config.FeishuBotsConfig{FeishuBots: []config.FeishuBot{
+			{Alias: "all", URL: server.URL + "/all"},
+			{Alias: "backend", URL: server.URL + "/backend"},
+			{Alias: "frontend", URL: server.URL + "/frontend"},
+		}},
+		Templates: map[string]config.TemplatesConfig{"default": {
+			Templates: map[string]config.EventTemplate{"push": {
+				Payloads: []config.PayloadTemplate{{Tags: []string{"default"}, Payload: map[string]any{"text": "{{owned_files_md | default('all files')}}"}}},
+			}},
+		}},
+	}
+	h := New(cfg, notifier.New(cfg.FeishuBots))
+
+	payload := map[string]any{
+		"ref":        "refs/heads/main",
+		"repository": map[string]any{"full_name": "org/repo"},
+		"commits": []any{
+			map[string]any{"added": []any{"api/users.go"}, "modified": []any{"web/App.tsx"}},
+			map[string]any{"removed": []any{"api/legacy.go"}, "modified": []any{"README.md"}},
+		},
+	}
+	if err := h.processWebhook("push", payload); err != nil {
+		t.Fatalf("processWebhook returned error: %v", err)
+	}
+
+	if len(bodies["/all"]) != 1 || !strings.Contains(bodies["/all"][0], "all files") {
+		t.Fatalf("regular target got %v, want one full card", bodies["/all"])
+	}
+	if len(bodies["/backend"]) != 1 || !strings.Contains(bodies["/backend"][0], `- api/users.go\n- api/legacy.go`) || strings.Contains(bodies["/backend"][0], "App.tsx") {
+		t.Fatalf("backend got %v, want only its files", bodies["/backend"])
+	}
+	if len(bodies["/frontend"]) != 1 || !strings.Contains(bodies["/frontend"][0], "- web/App.tsx") || strings.Contains(bodies["/frontend"][0], "api/") {
+		t.Fatalf("frontend got %v, want only its files", bodies["/frontend"])
+	}
+
+	// A digested rule still sends owner cards right away.
+	digests, err := store.OpenDigests(filepath.Join(t.TempDir(), "digests.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetDigests(digests)
+	cfg.Repos.Repos[0].Digest = &config.DigestConfig{Window: "15m", Events: []string{"push"}}
+	clear(bodies)
+	if err := h.processWebhook("push", payload); err != nil {
+		t.Fatalf("processWebhook returned error: %v", err)
+	}
+	if len(bodies["/all"]) != 0 || len(bodies["/backend"]) != 1 || len(bodies["/frontend"]) != 1 {
+		t.Fatalf("digested push sent %v, want owner cards only", bodies)
+	}
+}
+
+func TestProcessWebhookSenderFilters(t *testing.T) {
+	logger.Init("error", os.TempDir())
+	received := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	skip := true
+	cfg := &config.Config{
+		Repos: config.ReposConfig{Repos: []config.RepoPattern{{
+			Pattern:      "org/repo",
+			Events:       map[string]any{"pull_request": nil},
+			NotifyTo:     []string{server.URL},
+			SenderFilter: config.SenderFilter{ExcludeSenders: []string{"release-*"}},
+		}}},
+		Templates: map[string]config.TemplatesConfig{"default": {
+			Templates: map[string]config.EventTemplate{"pull_request": {
+				Payloads: []config.PayloadTemplate{{Tags: []string{"default"}, Payload: map[string]any{"msg_type": "text"}}},
+			}},
+		}},
+	}
+	cfg.Server.Filters.SkipBots = &skip
+	h := New(cfg, notifier.New(cfg.FeishuBots))
+
+	send := func(sender map[string]any) {
+		payload := map[string]any{
+			"action":     "opened",
+			"repository": map[string]any{"full_name": "org/repo"},
+			"sender":     sender,
+		}
+		if err := h.processWebhook("pull_request", payload); err != nil {
+			t.Fatalf("processWebhook returned error: %v", err)
+		}
+	}
+
+	send(map[string]any{"login": "renovate[bot]", "type": "Bot"})
+	send(map[string]any{"login": "ci-helper", "type": "Bot"})
+	send(map[string]any{"login": "release-manager", "type": "User"})
+	if received != 0 {
+		t.Fatalf("filtered senders delivered %d messages, want 0", received)
+	}
+
+	send(map[string]any{"login": "alice", "type": "User"})
+	if received != 1 {
+		t.Fatalf("regular sender delivered %d messages, want 1", received)
+	}
+
+	// A muted rule delivers nothing until muted_until has passed.
+	cfg.Repos.Repos[0].MutedUntil = time.Now().Add(time.Hour)
+	send(map[string]any{"login": "alice", "type": "User"})
+	cfg.Repos.Repos[0].MutedUntil = time.Now().Add(-time.Minute)
+	send(map[string]any{"login": "alice", "type": "User"})
+	if received != 2 {
+		t.Fatalf("muted rule: delivered %d messages in total, want 2", received)
+	}
+}
+
+func TestPrepareTemplateData_Mentions(t *testing.T) {
+	cfg := &config.Config{Users: config.UsersConfig{
+		Users: []config.UserMapping{
+			{GitHub: "Alice", OpenID: "ou_alice"},
+			{GitHub: "bob", Email: "bob@corp.example"},
+		},
+		Mentions: config.MentionsConfig{ReviewRequested: true},
+	}}
+	h := New(cfg, notifier.New(config.FeishuBotsConfig{}))
+
+	payload := map[string]any{
+		"action": "review_requested",
+		"sender": map[string]any{"login": "alice"},
+		"pull_request": map[string]any{
+			"number":              3,
+			"user":                map[string]any{"login": "carol"},
+			"requested_reviewers": []any{map[string]any{"login": "bob"}, map[string]any{"login": "dave"}},
+		},
+		"requested_reviewer": map[string]any{"login": "bob"},
+	}
+	data := h.prepareTemplateData("pull_request", payload)
+
+	want := map[string]string{
+		"sender_at":             "<at id=ou_alice></at>",
+		"pr_user_at":            "[carol](https://github.com/carol)",
+		"pr_reviewers_at":       "<at email=bob@corp.example></at> [dave](https://github.com/dave)",
+		"requested_reviewer_at": "<at email=bob@corp.example></at>",
+		"mentions_at":           "<at email=bob@corp.example></at>",
+	}
+	for key, value := range want {
+		if got := data[key]; got != value {
+			t.Errorf("%s = %v, want %q", key, got, value)
+		}
+	}
+
+	// assigned mentions stay off unless enabled
+	payload = map[string]any{
+		"action":   "assigned",
+		"issue":    map[string]any{"number": 4, "assignees": []any{map[string]any{"login": "alice"}}},
+		"assignee": map[string]any{"login": "alice"},
+	}
+	data = h.prepareTemplateData("issues", payload)
+	if data["issue_assignees_at"] != "<at id=ou_alice></at>" || data["assignee_at"] != "<at id=ou_alice></at>" {
+		t.Errorf("unexpected assignee mentions: %v / %v", data["issue_assignees_at"], data["assignee_at"])
+	}
+	if _, ok := data["mentions_at"]; ok {
+		t.Errorf("mentions_at set without mentions.assigned: %v", data["mentions_at"])
+	}
+}
+
+func TestPrepareThreadData(t *testing.T) {
+	cfg := &config.Config{}
+	h := New(cfg, notifier.New(config.FeishuBotsConfig{
+		FeishuApp:  config.FeishuAppConfig{AppID: "cli_x", AppSecret: "s", Threading: "update"},
+		FeishuBots: []config.FeishuBot{{Alias: "pr-chat", Type: "app", ReceiveID: "chat:oc_x"}},
+	}))
+	threads, err := store.OpenThreads(filepath.Join(t.TempDir(), "threads.json"), 0)
+	if err != nil {
+		t.Fatalf("OpenThreads() error = %v", err)
+	}
+	h.SetThreads(threads)
+
+	repo := map[string]any{"full_name": "org/repo"}
+	events := []struct {
+		eventType string
+		payload   map[string]any
+	}{
+		{"pull_request", map[string]any{"action": "opened", "repository": repo,
+			"pull_request": map[string]any{"number": float64(5), "state": "open", "title": "Add X"}}},
+		{"pull_request_review", map[string]any{"action": "submitted", "repository": repo,
+			"pull_request": map[string]any{"number": float64(5)}, "review": map[string]any{"state": "APPROVED"}}},
+		{"check_suite", map[string]any{"action": "completed", "repository": repo,
+			"check_suite": map[string]any{"conclusion": "success", "pull_requests": []any{map[string]any{"number": float64(5)}}}}},
+	}
+	var data map[string]any
+	for _, e := range events {
+		data = map[string]any{}
+		if key := h.prepareThreadData(e.eventType, data, e.payload, []string{"pr-chat"}); key != "org/repo#5" {
+			t.Fatalf("%s: thread key = %q, want org/repo#5", e.eventType, key)
+		}
+	}
+	if data["thread_title"] != "Add X" || data["thread_state"] != "open" || data["thread_review_state"] != "approved" || data["thread_ci_status"] != "success" {
+		t.Fatalf("unexpected thread data: %v", data)
+	}
+
+	// Events for chats that do not thread leave the index alone.
+	other := map[string]any{"action": "opened", "repository": repo,
+		"pull_request": map[string]any{"number": float64(6), "state": "open"}}
+	if key := h.prepareThreadData("pull_request", map[string]any{}, other, []string{"https://example.com/hook"}); key != "" {
+		t.Errorf("thread key for a non-threading target = %q, want empty", key)
+	}
+	if _, ok := threads.Get("org/repo#6"); ok {
+		t.Error("status recorded for a pull request no target threads")
+	}
+
+	statusTemplates := config.TemplatesConfig{Templates: map[string]config.EventTemplate{
+		"thread_status": {Payloads: []config.PayloadTemplate{
+			{Tags: []string{"default"}, Payload: map[string]any{"msg_type": "interactive", "card": map[string]any{"title": "{{thread_title}}"}}},
+			{Tags: []string{"merged"}, Payload: map[string]any{"msg_type": "interactive", "card": map[string]any{"title": "merged {{thread_title}}"}}},
+		}},
+	}}
+	card := threadStatusCard(statusTemplates, data)
+	if title := card["card"].(map[string]any)["title"]; title != "Add X" {
+		t.Errorf("thread status card title = %v, want Add X", title)
+	}
+	if threadStatusCard(config.TemplatesConfig{}, data) != nil {
+		t.Error("threadStatusCard() without a thread_status template should be nil")
+	}
+
+	// issue_comment on the PR shares its thread; push events have none.
+	comment := map[string]any{"repository": repo, "issue": map[string]any{"number": float64(5)}}
+	if key := h.threadKey("issue_comment", comment); key != "org/repo#5" {
+		t.Errorf("issue_comment thread key = %q", key)
+	}
+	if key := h.threadKey("push", map[string]any{"repository": repo}); key != "" {
+		t.Errorf("push thread key = %q, want empty", key)
+	}
+}
+
+func TestProcessWebhookSinkFormats(t *testing.T) {
+	logger.Init("error", os.TempDir())
+	received := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received[r.URL.Path] = string(body)
+		switch r.URL.Path {
+		case "/slack":
+			_, _ = w.Write([]byte("ok"))
+		case "/teams":
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			_, _ = w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+		}
+	}))
+	defer server.Close()
+
+	ping := func(payload map[string]any) map[string]config.EventTemplate {
+		return map[string]config.EventTemplate{"ping": {
+			Payloads: []config.PayloadTemplate{{Tags: []string{"default"}, Payload: payload}},
+		}}
+	}
+	cfg := &config.Config{
+		Repos: config.ReposConfig{Repos: []config.RepoPattern{{
+			Pattern:  "org/repo",
+			Events:   map[string]any{"ping": nil},
+			NotifyTo: []string{"feishu", "ding", "ding-feishu", "slack", "teams"},
+		}}},
+		FeishuBots: config.FeishuBotsConfig{FeishuBots: []config.FeishuBot{
+			{Alias: "feishu", URL: server.URL + "/feishu"},
+			{Alias: "ding", Type: "dingtalk", URL: server.URL + "/ding"},
+			{Alias: "ding-feishu", Type: "dingtalk", URL: server.URL + "/ding-feishu", Template: "default"},
+			{Alias: "slack", Type: "slack", URL: server.URL + "/slack"},
+			{Alias: "teams", Type: "teams", URL: server.URL + "/teams"},
+		}},
+		Templates: map[string]config.TemplatesConfig{
+			"default":  {Templates: ping(map[string]any{"msg_type": "text"})},
+			"dingtalk": {Format: "dingtalk", Templates: ping(map[string]any{"msgtype": "text"})},
+			"slack":    {Format: "slack", Templates: ping(map[string]any{"text": "hi"})},
+			"teams":    {Format: "teams", Templates: ping(map[string]any{"type": "message"})},
+		},
+	}
+	h := New(cfg, notifier.New(cfg.FeishuBots))
+
+	err := h.processWebhook("ping", map[string]any{"repository": map[string]any{"full_name": "org/repo"}})
+	if err == nil || !strings.Contains(err.Error(), "ding-feishu") {
+		t.Fatalf("processWebhook error = %v, want a format mismatch for ding-feishu", err)
+	}
+	if received["/feishu"] != `{"msg_type":"text"}` || received["/ding"] != `{"msgtype":"text"}` ||
+		received["/slack"] != `{"text":"hi"}` || received["/teams"] != `{"type":"message"}` {
+		t.Fatalf("unexpected deliveries: %v", received)
+	}
+	if _, ok := received["/ding-feishu"]; ok {
+		t.Fatal("a Feishu template was sent to a DingTalk bot")
+	}
+
+	// An event the Slack template file does not cover skips the Slack bot
+	// without failing the Feishu delivery.
+	clear(received)
+	cfg.Repos.Repos[0].Events = map[string]any{"discussion": nil}
+	cfg.Repos.Repos[0].NotifyTo = []string{"feishu", "slack"}
+	cfg.Templates["default"].Templates["discussion"] = config.EventTemplate{
+		Payloads: []config.PayloadTemplate{{Tags: []string{"default"}, Payload: map[string]any{"msg_type": "post"}}},
+	}
+	err = h.processWebhook("discussion", map[string]any{"action": "created", "repository": map[string]any{"full_name": "org/repo"}})
+	if err != nil {
+		t.Fatalf("processWebhook(discussion) error = %v", err)
+	}
+	if len(received) != 1 || received["/feishu"] != `{"msg_type":"post"}` {
+		t.Fatalf("unexpected deliveries: %v", received)
+	}
+}
+
+func TestProcessWebhookGenericEnvelope(t *testing.T) {
+	logger.Init("error", os.TempDir())
+	received := make(map[string]map[string]any)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		received[r.URL.Path] = body
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Repos: config.ReposConfig{Repos: []config.RepoPattern{{
+			Pattern:  "org/repo",
+			Events:   map[string]any{"issues": nil},
+			NotifyTo: []string{"relay", "rendered"},
+		}}},
+		FeishuBots: config.FeishuBotsConfig{FeishuBots: []config.FeishuBot{
+			{Alias: "relay", Type: "generic", URL: server.URL + "/relay"},
+			{Alias: "rendered", Type: "generic", Body: "template", URL: server.URL + "/rendered", Template: "svc"},
+		}},
+		Templates: map[string]config.TemplatesConfig{
+			"default": {},
+			"svc": {Format: "generic", Templates: map[string]config.EventTemplate{"issues": {
+				Payloads: []config.PayloadTemplate{{Tags: []string{"default"}, Payload: map[string]any{"repo": "{{repository.full_name}}", "action": "{{action}}"}}},
+			}}},
+		},
+	}
+	h := New(cfg, notifier.New(cfg.FeishuBots))
+
+	payload := map[string]any{
+		"action":     "opened",
+		"repository": map[string]any{"full_name": "org/repo"},
+		"issue":      map[string]any{"number": float64(7), "title": "Bug"},
+	}
+	if err := h.processWebhook("issues", payload); err != nil {
+		t.Fatalf("processWebhook returned error: %v", err)
+	}
+
+	envelope := received["/relay"]
+	if envelope["event"] != "issues" {
+		t.Fatalf("envelope event = %v, want issues", envelope["event"])
+	}
+	if tags, _ := envelope["tags"].([]any); !slices.Contains(tags, any("opened")) {
+		t.Errorf("envelope tags = %v, want opened", envelope["tags"])
+	}
+	if data, _ := envelope["data"].(map[string]any); data["issue_number"] != float64(7) {
+		t.Errorf("envelope data issue_number = %v", data["issue_number"])
+	}
+	if raw, _ := envelope["payload"].(map[string]any); raw["action"] != "opened" {
+		t.Errorf("envelope payload = %v", envelope["payload"])
+	}
+	if rendered := received["/rendered"]; rendered["repo"] != "org/repo" || rendered["action"] != "opened" {
+		t.Errorf("rendered body = %v", rendered)
+	}
+}
+
+func TestSyncIssueTasks(t *testing.T) {
+	logger.Init("error", os.TempDir())
+	var calls []string
+	var created map[string]any
+	mux := http.NewServeMux()
+	mux.HandleFunc("/open-apis/auth/v3/tenant_access_token/internal", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"code":0,"tenant_access_token":"t","expire":7200}`))
+	})
+	mux.HandleFunc("/open-apis/task/v2/tasks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&created)
+		calls = append(calls, "create "+r.URL.Query().Get("user_id_type"))
+		_, _ = w.Write([]byte(`{"code":0,"data":{"task":{"guid":"task-1"}}}`))
+	})
+	mux.HandleFunc("/open-apis/task/v2/tasks/", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Task map[string]string `json:"task"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		done := body.Task["completed_at"] != "0"
+		calls = append(calls, r.Method+" "+strings.TrimPrefix(r.URL.Path, "/open-apis/task/v2/tasks/")+" completed="+strconv.FormatBool(done))
+		_, _ = w.Write([]byte(`{"code":0,"data":{}}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	cfg := &config.Config{
+		Repos: config.ReposConfig{Repos: []config.RepoPattern{
+			{Pattern: "org/repo", Events: map[string]any{"issues": nil}},
+			{Pattern: "org/*", Events: map[string]any{"push": nil}},
+		}},
+		FeishuBots: config.FeishuBotsConfig{
+			FeishuApp: config.FeishuAppConfig{AppID: "cli_x", AppSecret: "s", BaseURL: server.URL},
+		},
+		Users: config.UsersConfig{
+			Users: []config.UserMapping{{GitHub: "alice", OpenID: "ou_alice"}, {GitHub: "carol", Email: "carol@example.com"}},
+			Tasks: config.TasksConfig{Enabled: true},
+		},
+	}
+	h := New(cfg, notifier.New(cfg.FeishuBots))
+	tasks, err := store.OpenTasks(filepath.Join(t.TempDir(), "tasks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetTasks(tasks)
+
+	issueEvent := func(repo, action, assignee string) map[string]any {
+		p := map[string]any{
+			"action":     action,
+			"repository": map[string]any{"full_name": repo},
+			"issue": map[string]any{
+				"number": float64(9), "title": "Broken login", "state": "open",
+				"html_url": "https://github.com/org/repo/issues/9",
+			},
+		}
+		if assignee != "" {
+			p["assignee"] = map[string]any{"login": assignee}
+		}
+		return p
+	}
+	event := func(action, assignee string) map[string]any {
+		return issueEvent("org/repo", action, assignee)
+	}
+	for _, p := range []map[string]any{
+		event("assigned", "alice"),
+		event("assigned", "alice"), // already has a task
+		event("assigned", "carol"), // mapped by email only
+		event("assigned", "dave"),  // not mapped
+		event("closed", ""),
+		event("reopened", ""),
+		issueEvent("org/other", "assigned", "alice"), // no rule subscribes to issues
+	} {
+		if err := h.processWebhook("issues", p); err != nil {
+			t.Fatalf("processWebhook returned error: %v", err)
+		}
+	}
+
+	want := []string{"create open_id", "PATCH task-1 completed=true", "PATCH task-1 completed=false"}
+	if !slices.Equal(calls, want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+	if created["summary"] != "[org/repo#9] Broken login" {
+		t.Errorf("summary = %v", created["summary"])
+	}
+	members, _ := created["members"].([]any)
+	if len(members) != 1 || members[0].(map[string]any)["id"] != "ou_alice" {
+		t.Errorf("members = %v", created["members"])
+	}
+	if origin, _ := created["origin"].(map[string]any); origin["href"].(map[string]any)["url"] != "https://github.com/org/repo/issues/9" {
+		t.Errorf("origin = %v", created["origin"])
+	}
+	if guid := tasks.Task("org/repo#9", "alice"); guid != "task-1" {
+		t.Errorf("recorded task = %q", guid)
+	}
+}
+
+func TestProcessWebhookDigest(t *testing.T) {
+	logger.Init("error", os.TempDir())
+	var received []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		received = append(received, body)
+		_, _ = w.Write([]byte(`{"code":0}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Repos: config.ReposConfig{Repos: []config.RepoPattern{{
+			Pattern:  "org/repo",
+			Events:   map[string]any{"star": nil, "issues": nil},
+			NotifyTo: []string{server.URL},
+			Digest:   &config.DigestConfig{Window: "15m", Events: []string{"star"}},
+		}}},
+		Templates: map[string]config.TemplatesConfig{
+			"default": {Templates: map[string]config.EventTemplate{
+				"issues": {Payloads: []config.PayloadTemplate{{Tags: []string{"default"}, Payload: map[string]any{"text": "issue {{issue_number}}"}}}},
+				"digest": {Payloads: []config.PayloadTemplate{{Tags: []string{"default"}, Payload: map[string]any{
+					"text": "{{digest_count}} in {{repo_full_name}}: {{digest_summary}}\n{{digest_items_text}}",
+				}}}},
+			}},
+		},
+	}
+	h := New(cfg, notifier.New(cfg.FeishuBots))
+	digests, err := store.OpenDigests(filepath.Join(t.TempDir(), "digests.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetDigests(digests)
+
+	repo := map[string]any{"full_name": "org/repo", "html_url": "https://github.com/org/repo"}
+	for _, login := range []string{"alice", "bob"} {
+		payload := map[string]any{"action": "created", "repository": repo, "sender": map[string]any{"login": login}}
+		if err := h.processWebhook("star", payload); err != nil {
+			t.Fatalf("processWebhook returned error: %v", err)
+		}
+	}
+	issue := map[string]any{"action": "opened", "repository": repo, "issue": map[string]any{"number": float64(3)}}
+	if err := h.processWebhook("issues", issue); err != nil {
+		t.Fatalf("processWebhook returned error: %v", err)
+	}
+	if len(received) != 1 || received[0]["text"] != "issue 3" {
+		t.Fatalf("before flush: received %v, want only the issue card", received)
+	}
+
+	h.FlushDigests(time.Now()) // window not over yet
+	if len(received) != 1 {
+		t.Fatalf("digest sent before its window ended: %v", received)
+	}
+	h.FlushDigests(time.Now().Add(15 * time.Minute))
+	if len(received) != 2 {
+		t.Fatalf("after flush: received %d messages, want 2", len(received))
+	}
+	want := "2 in org/repo: star ×2\n- star/created · org/repo · alice\n- star/created · org/repo · bob"
+	if got := received[1]["text"]; got != want {
+		t.Fatalf("digest text = %q, want %q", got, want)
+	}
+}
+
+func TestSendReport(t *testing.T) {
+	logger.Init("error", os.TempDir())
+	var received []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		received = append(received, body)
+		_, _ = w.Write([]byte(`{"code":0}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Server: config.ServerConfig{Reports: []config.ReportConfig{{
+			Name: "weekly", Schedule: "0 9 * * 1", NotifyTo: []string{"managers"},
+		}}},
+		Repos: config.ReposConfig{Repos: []config.RepoPattern{
+			{Pattern: "org/web", NotifyTo: []string{"managers"}},
+			{Pattern: "org/*", NotifyTo: []string{"devs"}},
+		}},
+		FeishuBots: config.FeishuBotsConfig{FeishuBots: []config.FeishuBot{{Alias: "managers", URL: server.URL}}},
+		Templates: map[string]config.TemplatesConfig{
+			"default": {Templates: map[string]config.EventTemplate{
+				"report": {Payloads: []config.PayloadTemplate{{Tags: []string{"default"}, Payload: map[string]any{
+					"text": "{{report_name}}: {{report_prs_opened}}/{{report_prs_merged}} PRs, {{report_commits}} commits, {{report_workflows_failed}} failed\n{{report_merged_text}}\n{{report_contributors}}",
+				}}}},
+			}},
+		},
+	}
+	h := New(cfg, notifier.New(cfg.FeishuBots))
+	history, err := store.OpenHistory(filepath.Join(t.TempDir(), "history.jsonl"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetHistory(history)
+
+	pr := func(repo, action string, merged bool) map[string]any {
+		return map[string]any{
+			"action":     action,
+			"repository": map[string]any{"full_name": repo},
+			"pull_request": map[string]any{
+				"number": float64(7), "title": "Add login", "merged": merged,
+				"html_url": "https://github.com/" + repo + "/pull/7",
+				"user":     map[string]any{"login": "alice"},
+			},
+		}
+	}
+	webhooks := []struct {
+		event   string
+		payload map[string]any
+	}{
+		{"pull_request", pr("org/web", "opened", false)},
+		{"pull_request", pr("org/web", "closed", true)},
+		{"pull_request", pr("org/api", "opened", false)}, // not notified to managers
+		{"push", map[string]any{"repository": map[string]any{"full_name": "org/web"}, "sender": map[string]any{"login": "bob"},
+			"commits": []any{map[string]any{}, map[string]any{}, map[string]any{}}}},
+		{"workflow_run", map[string]any{"action": "completed", "repository": map[string]any{"full_name": "org/web"},
+			"workflow_run": map[string]any{"name": "CI", "conclusion": "failure"}}},
+		{"workflow_run", map[string]any{"action": "completed", "repository": map[string]any{"full_name": "org/web"},
+			"workflow_run": map[string]any{"name": "CI", "conclusion": "success"}}},
+	}
+	for _, w := range webhooks {
+		if err := h.processWebhook(w.event, w.payload); err != nil {
+			t.Fatalf("processWebhook(%s) returned error: %v", w.event, err)
+		}
+	}
+
+	// A report is scheduled from the first check and sent once its time passes.
+	next := map[string]time.Time{}
+	start := time.Now()
+	h.runDueReports(start, next)
+	if len(received) != 0 {
+		t.Fatalf("report sent before its schedule: %v", received)
+	}
+	h.runDueReports(start.AddDate(0, 0, 7), next)
+	if len(received) != 1 {
+		t.Fatalf("received %d reports, want 1", len(received))
+	}
+	want := "weekly: 1/1 PRs, 3 commits, 1 failed\n- org/web#7 Add login https://github.com/org/web/pull/7\nbob (3), alice (2)"
+	if got := received[0]["text"]; got != want {
+		t.Fatalf("report text = %q, want %q", got, want)
+	}
+}
+
+func TestReleaseChangelog(t *testing.T) {
+	logger.Init("error", os.TempDir())
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		text, _ := body["text"].(string)
+		received = append(received, text)
+		_, _ = w.Write([]byte(`{"code":0}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Repos: config.ReposConfig{Repos: []config.RepoPattern{{
+			Pattern:  "org/web",
+			NotifyTo: []string{server.URL},
+			Events:   map[string]any{"release": nil},
+		}}},
+		Templates: map[string]config.TemplatesConfig{
+			"default": {Templates: map[string]config.EventTemplate{
+				"release": {Payloads: []config.PayloadTemplate{{Tags: []string{"default"}, Payload: map[string]any{
+					"text": "{{release_changelog_previous_tag}}..{{release_tag}} ({{release_changelog_count}})\n{{release_changelog_md}}\n{{release_changelog_contributors}}",
+				}}}},
+			}},
+		},
+	}
+	h := New(cfg, notifier.New(cfg.FeishuBots))
+	history, err := store.OpenHistory(filepath.Join(t.TempDir(), "history.jsonl"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetHistory(history)
+
+	now := time.Now()
+	for _, ev := range []store.HistoryEvent{
+		{Kind: store.KindPRMerged, Repo: "org/web", Title: "feat: shipped in v1", Number: 1, At: now.Add(-2 * time.Hour)},
+		{Kind: store.KindRelease, Repo: "org/web", Tag: "v1", At: now.Add(-time.Hour)},
+	} {
+		if err := history.Record(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	send := func(eventType string, payload map[string]any) {
+		t.Helper()
+		payload["repository"] = map[string]any{"full_name": "org/web", "html_url": "https://github.com/org/web"}
+		if err := h.processWebhook(eventType, payload); err != nil {
+			t.Fatalf("processWebhook returned error: %v", err)
+		}
+	}
+	merge := func(number float64, title, author string, labels ...string) {
+		var ls []any
+		for _, l := range labels {
+			ls = append(ls, map[string]any{"name": l})
+		}
+		send("pull_request", map[string]any{"action": "closed", "pull_request": map[string]any{
+			"number": number, "title": title, "merged": true, "labels": ls,
+			"html_url": fmt.Sprintf("https://github.com/org/web/pull/%d", int(number)),
+			"user":     map[string]any{"login": author},
+		}})
+	}
+	merge(2, "fix(api): handle empty body", "alice")
+	merge(3, "Bump yaml", "dependabot[bot]", "dependencies")
+	merge(4, "feat!: drop v1 endpoints", "bob")
+	merge(5, "feat: dark mode", "alice")
+	merge(6, "Tidy README", "carol")
+	release := map[string]any{"action": "published", "release": map[string]any{"tag_name": "v2"}}
+	send("release", release)
+	if _, ok := release["release_changelog"]; ok {
+		t.Error("the changelog was written into the GitHub payload")
+	}
+
+	want := "v1..v2 (5)\n" +
+		"**⚠️ Breaking Changes**\n- drop v1 endpoints ([#4](https://github.com/org/web/pull/4)) @bob\n" +
+		"**🚀 Features**\n- dark mode ([#5](https://github.com/org/web/pull/5)) @alice\n" +
+		"**🐛 Bug Fixes**\n- api: handle empty body ([#2](https://github.com/org/web/pull/2)) @alice\n" +
+		"**📦 Dependencies**\n- Bump yaml ([#3](https://github.com/org/web/pull/3)) @dependabot[bot]\n" +
+		"**Other**\n- Tidy README ([#6](https://github.com/org/web/pull/6)) @carol\n" +
+		"@alice, @dependabot[bot], @bob, @carol"
+	if len(received) != 1 || received[0] != want {
+		t.Fatalf("received %q, want %q", received, want)
+	}
+
+	// Large changelogs are cut to fit a card.
+	var prs []store.HistoryEvent
+	for i := 0; i < 200; i++ {
+		prs = append(prs, store.HistoryEvent{Title: fmt.Sprintf("fix: bug number %d with a fairly long description", i), Number: i, Actor: "alice"})
+	}
+	md, _ := changelogMarkdown(prs, 0, "https://github.com/org/web/compare/v1...v2", markdownChangelog)
+	if len(md) > maxChangelogBytes+100 || !strings.HasSuffix(md, "more ([full changelog](https://github.com/org/web/compare/v1...v2))") {
+		t.Fatalf("truncated changelog (%d bytes) ends with %q", len(md), md[max(len(md)-80, 0):])
+	}
+
+	slackPR := store.HistoryEvent{Title: "fix: <br> & co", Number: 7, URL: "https://github.com/org/web/pull/7", Actor: "alice"}
+	mrkdwn, _ := changelogMarkdown([]store.HistoryEvent{slackPR}, 0, "", mrkdwnChangelog)
+	if mrkdwn != "*🐛 Bug Fixes*\n- &lt;br&gt; &amp; co (<https://github.com/org/web/pull/7|#7>) @alice" {
+		t.Fatalf("mrkdwn changelog = %q", mrkdwn)
+	}
+
+	// Without an earlier release only the latest merges are listed.
+	for i := 0; i < maxFirstChangelogPRs+5; i++ {
+		ev := store.HistoryEvent{Kind: store.KindPRMerged, Repo: "org/new", Title: fmt.Sprintf("fix: bug %d", i), Number: 100 + i, At: now.Add(time.Duration(i-30) * time.Minute)}
+		if err := history.Record(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	changelog := h.buildReleaseChangelog("release", map[string]any{
+		"action":     "published",
+		"release":    map[string]any{"tag_name": "v0.1.0"},
+		"repository": map[string]any{"full_name": "org/new"},
+	})
+	md, _ = changelog["release_changelog_md"].(string)
+	if changelog["release_changelog_count"] != maxFirstChangelogPRs || strings.Contains(md, "bug 4 ") || !strings.HasSuffix(md, "bug 24 (#124)\n… and 5 more") {
+		t.Fatalf("first release changelog (%v PRs):\n%s", changelog["release_changelog_count"], md)
+	}
+}
+
+func TestStalePullRequestReminders(t *testing.T) {
+	logger.Init("error", os.TempDir())
+	var received []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		received = append(received, body)
+		_, _ = w.Write([]byte(`{"code":0}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Repos: config.ReposConfig{Repos: []config.RepoPattern{{
+			Pattern:  "org/repo",
+			NotifyTo: []string{server.URL},
+			StalePRs: &config.StalePRConfig{After: "2d", Repeat: "1d"},
+		}}},
+		Users: config.UsersConfig{Users: []config.UserMapping{{GitHub: "bob", OpenID: "ou_bob"}}},
+		Templates: map[string]config.TemplatesConfig{
+			"default": {Templates: map[string]config.EventTemplate{
+				"stale_pr": {Payloads: []config.PayloadTemplate{{Tags: []string{"default"}, Payload: map[string]any{
+					"text": "#{{pr_number}} {{pr_title}} waiting {{stale_age}}, reminder {{stale_reminder}}: {{stale_reviewers_at}}",
+				}}}},
+			}},
+		},
+	}
+	h := New(cfg, notifier.New(cfg.FeishuBots))
+	pulls, err := store.OpenPullRequests(filepath.Join(t.TempDir(), "pulls.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetPullRequests(pulls)
+
+	pr := func(action string, reviewers ...string) map[string]any {
+		var requested []any
+		for _, login := range reviewers {
+			requested = append(requested, map[string]any{"login": login})
+		}
+		return map[string]any{
+			"action":     action,
+			"repository": map[string]any{"full_name": "org/repo"},
+			"pull_request": map[string]any{
+				"number": float64(5), "title": "Fix cache", "user": map[string]any{"login": "alice"},
+				"requested_reviewers": requested,
+			},
+		}
+	}
+	if err := h.processWebhook("pull_request", pr("opened", "bob")); err != nil {
+		t.Fatalf("processWebhook returned error: %v", err)
+	}
+	start := time.Now()
+
+	h.SendReminders(start.Add(47 * time.Hour))
+	if len(received) != 0 {
+		t.Fatalf("reminder sent too early: %v", received)
+	}
+	h.SendReminders(start.Add(49 * time.Hour))
+	if len(received) != 1 {
+		t.Fatalf("received %d reminders, want 1", len(received))
+	}
+	if got, want := received[0]["text"], "#5 Fix cache waiting 2d 1h, reminder 1: <at id=ou_bob></at>"; got != want {
+		t.Fatalf("reminder text = %q, want %q", got, want)
+	}
+	h.SendReminders(start.Add(60 * time.Hour)) // within repeat
+	if len(received) != 1 {
+		t.Fatalf("reminder repeated too early")
+	}
+
+	// Snoozed pull requests are skipped until the snooze ends.
+	if _, err := pulls.Snooze("org/repo#5", start.Add(100*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	h.SendReminders(start.Add(80 * time.Hour))
+	if len(received) != 1 {
+		t.Fatalf("snoozed pull request reminded")
+	}
+
+	// Bob's review ends the wait; a new review request restarts it.
+	review := pr("submitted")
+	review["review"] = map[string]any{"user": map[string]any{"login": "bob"}, "state": "approved"}
+	if err := h.processWebhook("pull_request_review", review); err != nil {
+		t.Fatalf("processWebhook returned error: %v", err)
+	}
+	h.SendReminders(start.Add(200 * time.Hour))
+	if len(received) != 1 {
+		t.Fatalf("reviewed pull request reminded")
+	}
+	if err := h.processWebhook("pull_request", pr("review_requested", "bob")); err != nil {
+		t.Fatalf("processWebhook returned error: %v", err)
+	}
+	if p, _ := pulls.Get("org/repo#5"); !p.Waiting() || p.Reminders != 0 {
+		t.Fatalf("review request did not restart the wait: %+v", p)
+	}
+
+	if err := h.processWebhook("pull_request", pr("closed")); err != nil {
+		t.Fatalf("processWebhook returned error: %v", err)
+	}
+	if _, ok := pulls.Get("org/repo#5"); ok {
+		t.Fatal("closed pull request still tracked")
+	}
+}
+
+func TestCITransitions(t *testing.T) {
+	logger.Init("error", os.TempDir())
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		text, _ := body["text"].(string)
+		received = append(received, text)
+		_, _ = w.Write([]byte(`{"code":0}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Repos: config.ReposConfig{Repos: []config.RepoPattern{{
+			Pattern:  "org/repo",
+			NotifyTo: []string{server.URL},
+			Events: map[string]any{"workflow_run": map[string]any{
+				"types": []any{"completed"}, "transitions": true, "still_failing_every": 2,
+			}},
+		}}},
+		Templates: map[string]config.TemplatesConfig{
+			"default": {Templates: map[string]config.EventTemplate{
+				"workflow_run": {Payloads: []config.PayloadTemplate{
+					{Tags: []string{"broken"}, Payload: map[string]any{"text": "broken #{{workflow_run_number}}"}},
+					{Tags: []string{"fixed"}, Payload: map[string]any{"text": "fixed #{{workflow_run_number}}"}},
+					{Tags: []string{"still_failing"}, Payload: map[string]any{"text": "still failing x{{ci_failures}}"}},
+					{Tags: []string{"default"}, Payload: map[string]any{"text": "other"}},
+				}},
+			}},
+		},
+	}
+	h := New(cfg, notifier.New(cfg.FeishuBots))
+	states, err := store.OpenCIStates(filepath.Join(t.TempDir(), "ci.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetCIStates(states)
+
+	run := func(id float64, branch, conclusion string) {
+		t.Helper()
+		payload := map[string]any{
+			"action":     "completed",
+			"repository": map[string]any{"full_name": "org/repo"},
+			"workflow_run": map[string]any{
+				"id": id, "run_number": id, "run_attempt": float64(1), "name": "CI",
+				"head_branch": branch, "status": "completed", "conclusion": conclusion,
+			},
+		}
+		if err := h.processWebhook("workflow_run", payload); err != nil {
+			t.Fatalf("processWebhook returned error: %v", err)
+		}
+		if len(payload) != 3 {
+			t.Fatalf("payload was modified: %v", payload)
+		}
+	}
+	run(1, "main", "success")
+	run(2, "main", "failure")
+	run(3, "main", "failure")
+	run(4, "main", "cancelled")
+	run(5, "main", "failure")
+	run(6, "dev", "failure")
+	run(7, "main", "success")
+	run(8, "main", "success")
+
+	want := []string{"broken #2", "still failing x3", "broken #6", "fixed #7"}
+	if !reflect.DeepEqual(received, want) {
+		t.Fatalf("received %q, want %q", received, want)
+	}
+}
+
+func TestCommitStatusCard(t *testing.T) {
+	logger.Init("error", os.TempDir())
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		text, _ := body["text"].(string)
+		received = append(received, text)
+		_, _ = w.Write([]byte(`{"code":0}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Repos: config.ReposConfig{Repos: []config.RepoPattern{{
+			Pattern:      "org/repo",
+			NotifyTo:     []string{server.URL},
+			Events:       map[string]any{"check_run": nil, "check_suite": nil, "workflow_job": nil},
+			CommitStatus: &config.CommitStatusConfig{},
+		}}},
+		Templates: map[string]config.TemplatesConfig{
+			"default": {Templates: map[string]config.EventTemplate{
+				"commit_status": {Payloads: []config.PayloadTemplate{
+					{Tags: []string{"failure"}, Payload: map[string]any{"text": "{{commit_sha_short}} on {{commit_branch}} failed {{commit_failed}}/{{commit_total}}\n{{commit_jobs_text}}"}},
+					{Tags: []string{"default"}, Payload: map[string]any{"text": "{{commit_conclusion}}"}},
+				}},
+			}},
+		},
+	}
+	h := New(cfg, notifier.New(cfg.FeishuBots))
+	commits, err := store.OpenCommitStatuses(filepath.Join(t.TempDir(), "commits.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetCommitStatuses(commits)
+
+	send := func(eventType string, obj map[string]any) {
+		t.Helper()
+		obj["head_sha"] = "0123456789abcdef"
+		payload := map[string]any{"repository": map[string]any{"full_name": "org/repo"}, eventType: obj}
+		if err := h.processWebhook(eventType, payload); err != nil {
+			t.Fatalf("processWebhook returned error: %v", err)
+		}
+	}
+	send("check_suite", map[string]any{"id": float64(1), "status": "in_progress", "head_branch": "main"})
+	send("workflow_job", map[string]any{"id": float64(10), "check_run_url": "https://api.github.com/repos/org/repo/check-runs/20",
+		"name": "build", "workflow_name": "CI", "status": "in_progress"})
+	send("check_run", map[string]any{"id": float64(20), "name": "build", "status": "completed", "conclusion": "success",
+		"started_at": "2026-01-01T10:00:00Z", "completed_at": "2026-01-01T10:02:13Z"})
+	send("workflow_job", map[string]any{"id": float64(11), "name": "test", "workflow_name": "CI", "status": "completed",
+		"conclusion": "failure", "started_at": "2026-01-01T10:00:00Z", "completed_at": "2026-01-01T10:00:45Z"})
+
+	h.FlushCommitStatuses(time.Now().Add(10 * time.Minute))
+	if len(received) != 0 {
+		t.Fatalf("status sent before the suite completed: %q", received)
+	}
+	send("check_suite", map[string]any{"id": float64(1), "status": "completed", "conclusion": "failure"})
+	h.FlushCommitStatuses(time.Now())
+	want := "0123456 on main failed 1/2\n✅ CI / build: success (2m 13s)\n❌ CI / test: failure (45s)"
+	if len(received) != 1 || received[0] != want {
+		t.Fatalf("received %q, want %q", received, want)
+	}
+}
+
+func TestCommitStatusSuitesOnly(t *testing.T) {
+	logger.Init("error", os.TempDir())
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		text, _ := body["text"].(string)
+		received = append(received, text)
+		_, _ = w.Write([]byte(`{"code":0}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Repos: config.ReposConfig{Repos: []config.RepoPattern{{
+			Pattern:      "org/repo",
+			NotifyTo:     []string{server.URL},
+			Events:       map[string]any{"check_suite": nil},
+			CommitStatus: &config.CommitStatusConfig{Events: []string{"check_suite"}},
+		}}},
+		Templates: map[string]config.TemplatesConfig{
+			"default": {Templates: map[string]config.EventTemplate{
+				"commit_status": {Payloads: []config.PayloadTemplate{
+					{Tags: []string{"default"}, Payload: map[string]any{"text": "{{commit_conclusion}} {{commit_failed}}/{{commit_total}}\n{{commit_jobs_text}}"}},
+				}},
+			}},
+		},
+	}
+	h := New(cfg, notifier.New(cfg.FeishuBots))
+	commits, err := store.OpenCommitStatuses(filepath.Join(t.TempDir(), "commits.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetCommitStatuses(commits)
+
+	send := func(status, conclusion string) {
+		t.Helper()
+		payload := map[string]any{"repository": map[string]any{"full_name": "org/repo"}, "check_suite": map[string]any{
+			"id": float64(1), "head_sha": "0123456789abcdef", "status": status, "conclusion": conclusion,
+			"app": map[string]any{"name": "GitHub Actions"},
+		}}
+		if err := h.processWebhook("check_suite", payload); err != nil {
+			t.Fatalf("processWebhook returned error: %v", err)
+		}
+	}
+	send("queued", "")
+	h.FlushCommitStatuses(time.Now().Add(10 * time.Minute))
+	if len(received) != 0 {
+		t.Fatalf("status sent before the suite completed: %q", received)
+	}
+	send("completed", "failure")
+	h.FlushCommitStatuses(time.Now())
+	want := "failure 1/1\n❌ GitHub Actions: failure (—)"
+	if len(received) != 1 || received[0] != want {
+		t.Fatalf("received %q, want %q", received, want)
+	}
+}
+
+func TestDeploymentLifecycle(t *testing.T) {
+	logger.Init("error", os.TempDir())
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		text, _ := body["text"].(string)
+		received = append(received, text)
+		_, _ = w.Write([]byte(`{"code":0}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Repos: config.ReposConfig{Repos: []config.RepoPattern{{
+			Pattern:             "org/repo",
+			NotifyTo:            []string{server.URL},
+			Events:              map[string]any{"deployment": nil, "deployment_status": nil, "deployment_review": nil},
+			DeploymentLifecycle: &config.DeploymentLifecycleConfig{},
+		}}},
+		Templates: map[string]config.TemplatesConfig{
+			"default": {Templates: map[string]config.EventTemplate{
+				"deployment_review": {Payloads: []config.PayloadTemplate{{Tags: []string{"default"}, Payload: map[string]any{"text": "review"}}}},
+				"deployment_status": {Payloads: []config.PayloadTemplate{{Tags: []string{"default"}, Payload: map[string]any{"text": "status"}}}},
+				"deployment_lifecycle": {Payloads: []config.PayloadTemplate{
+					{Tags: []string{"success"}, Payload: map[string]any{"text": "{{deploy_environment}} {{deploy_ref}} {{deploy_sha_short}} ok, approved by {{deploy_approver}}"}},
+					{Tags: []string{"default"}, Payload: map[string]any{"text": "{{deploy_ref}} {{deploy_state}} after {{deploy_previous_ref}} ({{deploy_previous_url}})"}},
+				}},
+			}},
+		},
+	}
+	h := New(cfg, notifier.New(cfg.FeishuBots))
+	deployments, err := store.OpenDeployments(filepath.Join(t.TempDir(), "deployments.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetDeployments(deployments)
+
+	send := func(eventType string, payload map[string]any) {
+		t.Helper()
+		payload["repository"] = map[string]any{"full_name": "org/repo", "html_url": "https://github.com/org/repo"}
+		if err := h.processWebhook(eventType, payload); err != nil {
+			t.Fatalf("processWebhook returned error: %v", err)
+		}
+		if _, ok := payload["deployment_lifecycle"]; ok {
+			t.Fatalf("%s: the lifecycle was written into the GitHub payload", eventType)
+		}
+	}
+	deployment := func(id float64, ref string) map[string]any {
+		return map[string]any{"id": id, "environment": "production", "ref": ref, "sha": "0123456789abcdef"}
+	}
+	status := func(id float64, ref, state string) map[string]any {
+		return map[string]any{
+			"action":            "created",
+			"deployment":        deployment(id, ref),
+			"deployment_status": map[string]any{"state": state, "environment": "production", "log_url": "https://example.com/log/" + ref},
+		}
+	}
+
+	// The review carries no deployment id and is matched by environment and run.
+	send("deployment", map[string]any{"action": "created", "deployment": deployment(5, "v1"), "workflow_run": map[string]any{"id": float64(99)}})
+	send("deployment_review", map[string]any{"action": "approved", "environment": "production",
+		"approver": map[string]any{"login": "alice"}, "workflow_run": map[string]any{"id": float64(99)}})
+	send("deployment_status", status(5, "v1", "in_progress"))
+	send("deployment_status", status(5, "v1", "success"))
+	send("deployment_status", status(5, "v1", "success")) // redelivery
+	want := []string{"review", "production v1 0123456 ok, approved by alice"}
+	if !reflect.DeepEqual(received, want) {
+		t.Fatalf("received %q, want %q", received, want)
+	}
+
+	send("deployment", map[string]any{"action": "created", "deployment": deployment(6, "v2")})
+	send("deployment_status", status(6, "v2", "failure"))
+	if len(received) != 3 || received[2] != "v2 failure after v1 (https://example.com/log/v1)" {
+		t.Fatalf("received %q", received)
+	}
+}
+
+func TestSuppressionsAndMaintenance(t *testing.T) {
+	logger.Init("error", os.TempDir())
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		text, _ := body["text"].(string)
+		received = append(received, text)
+		_, _ = w.Write([]byte(`{"code":0}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Repos: config.ReposConfig{Repos: []config.RepoPattern{{
+			Pattern:  "org/*",
+			NotifyTo: []string{"team"},
+			Events:   map[string]any{"push": nil},
+		}}},
+		FeishuBots: config.FeishuBotsConfig{FeishuBots: []config.FeishuBot{{Alias: "team", URL: server.URL}}},
+		Templates: map[string]config.TemplatesConfig{
+			"default": {Templates: map[string]config.EventTemplate{
+				"push": {Payloads: []config.PayloadTemplate{{Tags: []string{"default"}, Payload: map[string]any{"text": "{{repo_name}}"}}}},
+			}},
+		},
+	}
+	h := New(cfg, notifier.New(cfg.FeishuBots))
+	suppressions, err := store.OpenSuppressions(filepath.Join(t.TempDir(), "suppressions.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetSuppressions(suppressions)
+	push := func(repo string) {
+		t.Helper()
+		payload := map[string]any{"ref": "refs/heads/main", "repository": map[string]any{"full_name": repo, "name": repo[4:]}}
+		if err := h.processWebhook("push", payload); err != nil {
+			t.Fatalf("processWebhook returned error: %v", err)
+		}
+	}
+
+	if _, err := suppressions.Add(store.Suppression{Repo: "org/leg*", Target: "team", Until: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	push("org/legacy")
+	push("org/app")
+	if !reflect.DeepEqual(received, []string{"app"}) {
+		t.Fatalf("received %q, want only app", received)
+	}
+	if log := suppressions.Log(); len(log) != 1 || log[0].Repo != "org/legacy" || log[0].Target != "team" {
+		t.Fatalf("suppressed log = %+v", log)
+	}
+
+	// Maintenance mode queues everything until it is lifted.
+	if err := suppressions.SetMaintenance(true, time.Time{}, "migration"); err != nil {
+		t.Fatal(err)
+	}
+	push("org/app")
+	h.FlushQueued(time.Now())
+	if len(received) != 1 || suppressions.Queued() != 1 {
+		t.Fatalf("sent during maintenance: %q (queued %d)", received, suppressions.Queued())
+	}
+	if err := suppressions.SetMaintenance(false, time.Time{}, ""); err != nil {
+		t.Fatal(err)
+	}
+	h.FlushQueued(time.Now())
+	if !reflect.DeepEqual(received, []string{"app", "app"}) || suppressions.Queued() != 0 {
+		t.Fatalf("after maintenance received %q (queued %d)", received, suppressions.Queued())
+	}
+}
+
+func TestQuietHours(t *testing.T) {
+	logger.Init("error", os.TempDir())
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		text, _ := body["text"].(string)
+		received = append(received, r.URL.Path+" "+text)
+		_, _ = w.Write([]byte(`{"code":0}`))
+	}))
+	defer server.Close()
+
+	now := time.Now().UTC()
+	quiet := &config.QuietHours{
+		TimeWindow: config.TimeWindow{Start: now.Add(-time.Hour).Format("15:04"), End: now.Add(time.Hour).Format("15:04"), Timezone: "UTC"},
+		Bypass:     []string{"opened"},
+	}
+	cfg := &config.Config{
+		Repos: config.ReposConfig{Repos: []config.RepoPattern{{
+			Pattern:  "org/*",
+			NotifyTo: []string{"eu", "cn"},
+			Events:   map[string]any{"push": nil, "issues": nil},
+		}}},
+		FeishuBots: config.FeishuBotsConfig{FeishuBots: []config.FeishuBot{
+			{Alias: "eu", URL: server.URL + "/eu", QuietHours: quiet},
+			{Alias: "cn", URL: server.URL + "/cn"},
+		}},
+		Templates: map[string]config.TemplatesConfig{
+			"default": {Templates: map[string]config.EventTemplate{
+				"push":   {Payloads: []config.PayloadTemplate{{Tags: []string{"default"}, Payload: map[string]any{"text": "push"}}}},
+				"issues": {Payloads: []config.PayloadTemplate{{Tags: []string{"default"}, Payload: map[string]any{"text": "{{action}}"}}}},
+				"digest": {Payloads: []config.PayloadTemplate{{Tags: []string{"default"}, Payload: map[string]any{"text": "{{digest_rule}}: {{digest_summary}}"}}}},
+			}},
+		},
+	}
+	h := New(cfg, notifier.New(cfg.FeishuBots))
+	held, err := store.OpenHeld(filepath.Join(t.TempDir(), "held.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetHeld(held)
+	repo := map[string]any{"full_name": "org/app"}
+	for _, event := range []struct {
+		eventType string
+		payload   map[string]any
+	}{
+		{"push", map[string]any{"ref": "refs/heads/main", "repository": repo}},
+		{"issues", map[string]any{"action": "opened", "repository": repo}}, // bypasses quiet hours
+		{"issues", map[string]any{"action": "closed", "repository": repo}},
+	} {
+		if err := h.processWebhook(event.eventType, event.payload); err != nil {
+			t.Fatalf("processWebhook returned error: %v", err)
+		}
+	}
+	if want := []string{"/eu opened", "/cn opened", "/cn push", "/cn closed"}; !sameElements(received, want) {
+		t.Fatalf("during quiet hours received %q, want %q", received, want)
+	}
+
+	received = nil
+	h.FlushHeld(now)
+	if len(received) != 0 || held.Len() != 2 {
+		t.Fatalf("released during quiet hours: %q", received)
+	}
+	h.FlushHeld(now.Add(3 * time.Hour))
+	if !reflect.DeepEqual(received, []string{"/eu quiet hours: issues ×1, push ×1"}) || held.Len() != 0 {
+		t.Fatalf("after quiet hours received %q", received)
+	}
+
+	// A rule's quiet_hours overrides the bots'.
+	cfg.Repos.Repos[0].QuietHours = &config.QuietHours{TimeWindow: config.TimeWindow{Start: "00:00", End: "00:01", Timezone: "UTC"}}
+	if got := h.quietHours("org/app", "eu"); got != cfg.Repos.Repos[0].QuietHours {
+		t.Fatalf("quietHours() = %+v, want the rule override", got)
+	}
+}
+
+// sameElements reports whether a and b hold the same strings in any order.
+func sameElements(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
+
+func TestOnCallData(t *testing.T) {
+	cfg := &config.Config{
+		Users: config.UsersConfig{
+			Users:    []config.UserMapping{{GitHub: "alice", OpenID: "ou_alice"}},
+			Mentions: config.MentionsConfig{Broken: []string{"oncall:ci", "oncall:missing", "bob"}},
+		},
+		OnCall: config.OnCallConfig{Schedules: []config.OnCallSchedule{
+			{Name: "ci", Rotation: config.RotationWeekly, Start: "2026-01-05 09:00", Timezone: "UTC", Members: []string{"alice"}},
+		}},
+	}
+	h := New(cfg, notifier.New(cfg.FeishuBots))
+	payload := map[string]any{
+		"action":       "completed",
+		"repository":   map[string]any{"full_name": "org/repo"},
+		"workflow_run": map[string]any{"name": "CI", "conclusion": "failure"},
+	}
+	data := h.prepareTemplateData("workflow_run", payload)
+	data["ci_transition"] = store.TransitionBroken
+	h.prepareBrokenMentionData("workflow_run", data)
+	oncall, _ := data["oncall"].(map[string]any)
+	if oncall["ci"] != "<at id=ou_alice></at>" {
+		t.Errorf("oncall = %v", data["oncall"])
+	}
+	if want := "<at id=ou_alice></at> [bob](https://github.com/bob)"; data["mentions_at"] != want {
+		t.Errorf("mentions_at = %q, want %q", data["mentions_at"], want)
+	}
+	if want := "[alice](https://github.com/alice) [bob](https://github.com/bob)"; data["mentions_md"] != want {
+		t.Errorf("mentions_md = %q, want %q", data["mentions_md"], want)
+	}
+}
+
+func TestReloadWhileProcessing(t *testing.T) {
+	logger.Init("error", os.TempDir())
+	dir := t.TempDir()
+	files := map[string]string{
+		"server.yaml":      "server:\n  port: 4594\n",
+		"repos.yaml":       "repos:\n  - pattern: org/*\n    events: {push: }\n    notify_to: [team]\n",
+		"events.yaml":      "events: {}\n",
+		"feishu-bots.yaml": "feishu_bots: []\n",
+		"templates.jsonc":  `{"templates": {}}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := &config.Config{}
+	h := New(cfg, notifier.New(cfg.FeishuBots))
+	h.EnableHotReload(dir)
+	h.Reload()
+	if len(h.Config().Repos.Repos) != 1 {
+		t.Fatalf("Reload() did not apply repos.yaml: %+v", h.Config().Repos)
+	}
+
+	// Run with -race: reloads swap the config and notifier under running
+	// webhooks.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			h.Reload()
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		_ = h.processWebhook("push", map[string]any{"ref": "refs/heads/main", "repository": map[string]any{"full_name": "org/repo"}})
+	}
+	<-done
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hnrobert/feishu-github-tracker/internal/config"
+	"github.com/hnrobert/feishu-github-tracker/internal/logger"
+	"github.com/hnrobert/feishu-github-tracker/internal/store"
+	"github.com/hnrobert/feishu-github-tracker/internal/template"
+)
+
+// threadKey returns the "owner/repo#number" key of the pull request or issue
+// an event belongs to, or "" for events that are not about one.
+func (h *Handler) threadKey(eventType string, payload map[string]any) string {
+	repo := h.extractRepoFullName(payload)
+	if repo == "" {
+		return ""
+	}
+	var number float64
+	switch eventType {
+	case "pull_request", "pull_request_review", "pull_request_review_comment", "pull_request_review_thread":
+		if pr, ok := payload["pull_request"].(map[string]any); ok {
+			number, _ = pr["number"].(float64)
+		}
+	case "issues", "issue_comment":
+		// Pull request comments arrive as issue_comment with the PR number,
+		// so they share the pull request's thread.
+		if issue, ok := payload["issue"].(map[string]any); ok {
+			number, _ = issue["number"].(float64)
+		}
+	case "check_suite":
+		if suite, ok := payload["check_suite"].(map[string]any); ok {
+			if prs, ok := suite["pull_requests"].([]any); ok && len(prs) > 0 {
+				if pr, ok := prs[0].(map[string]any); ok {
+					number, _ = pr["number"].(float64)
+				}
+			}
+		}
+	}
+	if number == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s#%d", repo, int(number))
+}
+
+// threadStatus extracts the status an event reports for its thread: the
+// PR/issue title and state, the latest review verdict or the check suite
+// result.
+func threadStatus(eventType string, payload map[string]any) store.Thread {
+	var status store.Thread
+	action, _ := payload["action"].(string)
+	for _, key := range []string{"pull_request", "issue"} {
+		if item, ok := payload[key].(map[string]any); ok {
+			status.Title, _ = item["title"].(string)
+			status.URL, _ = item["html_url"].(string)
+		}
+	}
+	switch eventType {
+	case "pull_request":
+		if pr, ok := payload["pull_request"].(map[string]any); ok {
+			status.State, _ = pr["state"].(string)
+			if merged, _ := pr["merged"].(bool); merged {
+				status.State = "merged"
+			} else if draft, _ := pr["draft"].(bool); draft && status.State == "open" {
+				status.State = "draft"
+			}
+		}
+	case "issues":
+		if issue, ok := payload["issue"].(map[string]any); ok {
+			status.State, _ = issue["state"].(string)
+		}
+	case "pull_request_review":
+		if action == "dismissed" {
+			status.Review = "dismissed"
+		} else if review, ok := payload["review"].(map[string]any); ok {
+			state, _ := review["state"].(string)
+			status.Review = strings.ToLower(state)
+		}
+	case "check_suite":
+		if suite, ok := payload["check_suite"].(map[string]any); ok {
+			if action == "completed" {
+				status.CI, _ = suite["conclusion"].(string)
+			} else {
+				status.CI = "pending"
+			}
+		}
+	}
+	return status
+}
+
+// prepareThreadData returns the thread key of an event sent to targets and,
+// if one of them threads (see notifier.Threaded), records the event's status
+// on the thread and exposes the accumulated status as thread_* template
+// fields for the thread_status card. It returns "" when no target threads,
+// so events for other chats leave the thread index alone.
+func (h *Handler) prepareThreadData(eventType string, data map[string]any, payload map[string]any, targets []string) string {
+	if h.threads == nil || !h.currentNotifier().Threaded(targets) {
+		return ""
+	}
+	key := h.threadKey(eventType, payload)
+	if key == "" {
+		return ""
+	}
+	thread, err := h.threads.UpdateStatus(key, threadStatus(eventType, payload))
+	if err != nil {
+		logger.Warn("Failed to record status for %s: %v", key, err)
+	}
+	data["thread_key"] = key
+	data["thread_title"] = thread.Title
+	data["thread_url"] = thread.URL
+	data["thread_state"] = thread.State
+	data["thread_review_state"] = thread.Review
+	data["thread_ci_status"] = thread.CI
+	data["thread_event"] = eventType
+	return key
+}
+
+// threadStatusCard renders the thread_status template of templatesConfig,
+// which update-mode chats get in place of their thread's first card. It
+// returns nil if the template file has none, so those chats get a reply in
+// the thread instead.
+func threadStatusCard(templatesConfig config.TemplatesConfig, data map[string]any) map[string]any {
+	if _, ok := templatesConfig.Templates["thread_status"]; !ok {
+		return nil
+	}
+	var tags []string
+	if state, _ := data["thread_state"].(string); state != "" {
+		tags = append(tags, state)
+	}
+	tmpl, err := template.SelectTemplate("thread_status", tags, templatesConfig)
+	if err == nil {
+		var card map[string]any
+		if card, err = template.FillTemplate(tmpl, data); err == nil {
+			return card
+		}
+	}
+	logger.Warn("Failed to render thread status card for %v: %v", data["thread_key"], err)
+	return nil
+}
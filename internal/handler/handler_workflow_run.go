@@ -54,6 +54,10 @@ func prepareWorkflowRunData(data map[string]any, payload map[string]any) {
 		}
 	}
 
+	// CI transition; trackCIState supplies the values for tracked runs
+	data["ci_transition"] = ""
+	data["ci_failures"] = 0
+
 	// repository convenience keys (may already be set by prepareCommonData,
 	// but provide workflow-scoped aliases for templates that prefer them)
 	if repo, ok := payload["repository"].(map[string]any); ok {
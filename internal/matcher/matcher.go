@@ -40,6 +40,88 @@ func MatchAllRepos(fullName string, repos []config.RepoPattern) ([]*config.RepoP
 	return matched, nil
 }
 
+// MatchLabelRoutes returns the targets of every label route that matches at
+// least one of the given labels, in configuration order and without
+// duplicates. Label names and globs are compared case-insensitively.
+func MatchLabelRoutes(labels []string, routes []config.LabelRoute) ([]string, error) {
+	var targets []string
+	seen := make(map[string]struct{})
+	for _, route := range routes {
+		matched := false
+		for _, pattern := range route.Labels {
+			g, err := glob.Compile(strings.ToLower(pattern))
+			if err != nil {
+				return nil, fmt.Errorf("invalid label glob %s: %w", pattern, err)
+			}
+			for _, label := range labels {
+				if g.Match(strings.ToLower(label)) {
+					matched = true
+					break
+				}
+			}
+			if matched {
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		for _, target := range route.NotifyTo {
+			if _, ok := seen[target]; ok {
+				continue
+			}
+			seen[target] = struct{}{}
+			targets = append(targets, target)
+		}
+	}
+	return targets, nil
+}
+
+// SenderAllowed reports whether a webhook triggered by login (isBot when the
+// sender is a GitHub App / bot account) passes the global and rule sender
+// filters. Excludes from both levels apply; the rule's include list replaces
+// the global one when set; the rule's skip_bots overrides the global flag.
+// Logins are compared case-insensitively and invalid globs are ignored.
+func SenderAllowed(login string, isBot bool, global, rule config.SenderFilter) bool {
+	skipBots := global.SkipBots != nil && *global.SkipBots
+	if rule.SkipBots != nil {
+		skipBots = *rule.SkipBots
+	}
+	if skipBots && isBot {
+		return false
+	}
+
+	if matchLogin(login, global.ExcludeSenders) || matchLogin(login, rule.ExcludeSenders) {
+		return false
+	}
+
+	include := global.IncludeSenders
+	if len(rule.IncludeSenders) > 0 {
+		include = rule.IncludeSenders
+	}
+	if len(include) > 0 && !matchLogin(login, include) {
+		return false
+	}
+	return true
+}
+
+// matchLogin matches login against sender globs. Only * and ? are wildcards,
+// so bot logins such as "dependabot[bot]" can be written literally.
+func matchLogin(login string, patterns []string) bool {
+	login = strings.ToLower(login)
+	unquote := strings.NewReplacer(`\*`, "*", `\?`, "?")
+	for _, pattern := range patterns {
+		g, err := glob.Compile(unquote.Replace(glob.QuoteMeta(strings.ToLower(pattern))))
+		if err != nil {
+			continue
+		}
+		if g.Match(login) {
+			return true
+		}
+	}
+	return false
+}
+
 // ExpandEvents expands event templates and merges them with custom events
 func ExpandEvents(repoEvents map[string]any, eventSets map[string]map[string]any, baseEvents map[string]any) map[string]any {
 	result := make(map[string]any)
@@ -70,8 +152,10 @@ func ExpandEvents(repoEvents map[string]any, eventSets map[string]map[string]any
 	return result
 }
 
-// MatchEvent checks if the webhook event matches the configured events
-func MatchEvent(eventType string, action string, ref string, payload map[string]any, configuredEvents map[string]any) bool {
+// MatchEvent checks if the webhook event matches the configured events.
+// extra holds the variables the handler derived for the event (may be nil);
+// the workflow_run transitions filter reads ci_transition / ci_failures.
+func MatchEvent(eventType string, action string, ref string, payload map[string]any, extra map[string]any, configuredEvents map[string]any) bool {
 	eventConfig, exists := configuredEvents[eventType]
 	if !exists {
 		return false
@@ -103,9 +187,36 @@ func MatchEvent(eventType string, action string, ref string, payload map[string]
 		}
 	}
 
+	// Severity, ecosystem and advisory filters for security alerts
+	if IsSecurityAlert(eventType) && !matchSecurityFilters(eventType, payload, configMap) {
+		return false
+	}
+
+	// Only CI transitions for workflow_run events in transitions mode
+	if eventType == "workflow_run" {
+		if transitions, _ := configMap["transitions"].(bool); transitions {
+			return action == "completed" && matchTransition(extra, configMap["still_failing_every"])
+		}
+	}
+
 	return true
 }
 
+// matchTransition reports whether a completed workflow run changed the CI
+// state: it broke or fixed the build, or it is the Nth consecutive failure
+// after the one that broke it (still_failing_every: N; 0 never repeats).
+func matchTransition(extra map[string]any, every any) bool {
+	switch transition, _ := extra["ci_transition"].(string); transition {
+	case "broken", "fixed":
+		return true
+	case "still_failing":
+		n, _ := every.(int)
+		failures, _ := extra["ci_failures"].(int)
+		return n > 0 && (failures-1)%n == 0
+	}
+	return false
+}
+
 func matchBranches(ref string, branches []any) bool {
 	// Extract branch name from ref (refs/heads/main -> main)
 	branchName := ref
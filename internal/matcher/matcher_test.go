@@ -1,6 +1,7 @@
 package matcher
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/hnrobert/feishu-github-tracker/internal/config"
@@ -113,7 +114,147 @@ func TestMatchEvent(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := MatchEvent(tt.eventType, tt.action, tt.ref, nil, tt.configuredEvents)
+			got := MatchEvent(tt.eventType, tt.action, tt.ref, nil, nil, tt.configuredEvents)
+			if got != tt.want {
+				t.Errorf("MatchEvent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchLabelRoutes(t *testing.T) {
+	routes := []config.LabelRoute{
+		{Labels: []string{"area/infra*"}, NotifyTo: []string{"sre-team"}},
+		{Labels: []string{"security"}, NotifyTo: []string{"sec-team", "sre-team"}},
+	}
+
+	tests := []struct {
+		name   string
+		labels []string
+		want   []string
+	}{
+		{"glob match", []string{"area/infra-network"}, []string{"sre-team"}},
+		{"case insensitive", []string{"Security"}, []string{"sec-team", "sre-team"}},
+		{"deduplicated across routes", []string{"area/infra", "security"}, []string{"sre-team", "sec-team"}},
+		{"no match", []string{"docs"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MatchLabelRoutes(tt.labels, routes)
+			if err != nil {
+				t.Fatalf("MatchLabelRoutes() error = %v", err)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("MatchLabelRoutes(%v) = %v, want %v", tt.labels, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchOwners(t *testing.T) {
+	rules := []config.OwnerRule{
+		{Paths: []string{"*"}, NotifyTo: []string{"core"}},
+		{Paths: []string{"/deploy/", "*.tf"}, NotifyTo: []string{"sre"}},
+		{Paths: []string{"docs"}, NotifyTo: []string{"docs-team"}},
+		{Paths: []string{"/deploy/vendor/"}},
+	}
+	files := []string{"main.go", "deploy/k8s/app.yaml", "infra/net/main.tf", "site/docs/intro.md", "deploy/vendor/x.yaml"}
+
+	owned, err := MatchOwners(files, rules)
+	if err != nil {
+		t.Fatalf("MatchOwners() error = %v", err)
+	}
+	got := make(map[string]string)
+	var order []string
+	for _, o := range owned {
+		got[o.Target] = strings.Join(o.Files, ",")
+		order = append(order, o.Target)
+	}
+	want := map[string]string{
+		"core":      "main.go",
+		"sre":       "deploy/k8s/app.yaml,infra/net/main.tf",
+		"docs-team": "site/docs/intro.md",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("MatchOwners() = %#v, want %#v", got, want)
+	}
+	for target, files := range want {
+		if got[target] != files {
+			t.Errorf("files for %s = %q, want %q", target, got[target], files)
+		}
+	}
+	if strings.Join(order, ",") != "core,sre,docs-team" {
+		t.Errorf("owner order = %v, want first-appearance order", order)
+	}
+}
+
+func TestSenderAllowed(t *testing.T) {
+	yes, no := true, false
+	global := config.SenderFilter{ExcludeSenders: []string{"release-bot"}, SkipBots: &yes}
+
+	tests := []struct {
+		name  string
+		login string
+		isBot bool
+		rule  config.SenderFilter
+		want  bool
+	}{
+		{"regular user", "alice", false, config.SenderFilter{}, true},
+		{"bot skipped globally", "dependabot[bot]", true, config.SenderFilter{}, false},
+		{"rule re-enables bots", "dependabot[bot]", true, config.SenderFilter{SkipBots: &no}, true},
+		{"global exclude", "Release-Bot", false, config.SenderFilter{SkipBots: &no}, false},
+		{"rule exclude glob", "renovate-app", false, config.SenderFilter{ExcludeSenders: []string{"renovate*"}}, false},
+		{"rule include list", "bob", false, config.SenderFilter{IncludeSenders: []string{"alice", "carol"}}, false},
+		{"rule include match", "carol", false, config.SenderFilter{IncludeSenders: []string{"alice", "carol"}}, true},
+		{"literal brackets", "dependabot[bot]", true, config.SenderFilter{SkipBots: &no, IncludeSenders: []string{"dependabot[bot]"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SenderAllowed(tt.login, tt.isBot, global, tt.rule); got != tt.want {
+				t.Errorf("SenderAllowed(%q) = %v, want %v", tt.login, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchEventSecurityFilters(t *testing.T) {
+	dependabot := func(severity, ecosystem, ghsa string) map[string]any {
+		return map[string]any{"alert": map[string]any{
+			"security_advisory": map[string]any{"severity": severity, "ghsa_id": ghsa, "cve_id": "CVE-2026-0001"},
+			"dependency":        map[string]any{"package": map[string]any{"ecosystem": ecosystem}},
+		}}
+	}
+	codeScanning := func(ruleSeverity, securitySeverity string) map[string]any {
+		rule := map[string]any{"severity": ruleSeverity}
+		if securitySeverity != "" {
+			rule["security_severity_level"] = securitySeverity
+		}
+		return map[string]any{"alert": map[string]any{"rule": rule}}
+	}
+	tests := []struct {
+		name      string
+		eventType string
+		payload   map[string]any
+		filter    map[string]any
+		want      bool
+	}{
+		{"severity at threshold", "dependabot_alert", dependabot("high", "npm", "GHSA-a"), map[string]any{"min_severity": "high"}, true},
+		{"severity below threshold", "dependabot_alert", dependabot("moderate", "npm", "GHSA-a"), map[string]any{"min_severity": "high"}, false},
+		{"ecosystem listed", "dependabot_alert", dependabot("low", "pip", "GHSA-a"), map[string]any{"ecosystems": []any{"npm", "pip"}}, true},
+		{"ecosystem not listed", "dependabot_alert", dependabot("low", "maven", "GHSA-a"), map[string]any{"ecosystems": []any{"npm"}}, false},
+		{"ghsa allowed", "dependabot_alert", dependabot("low", "npm", "GHSA-a"), map[string]any{"ghsa": []any{"ghsa-a"}}, true},
+		{"cve allowed", "dependabot_alert", dependabot("low", "npm", "GHSA-b"), map[string]any{"cve": []any{"CVE-2026-0001"}}, true},
+		{"advisory not allowed", "dependabot_alert", dependabot("low", "npm", "GHSA-b"), map[string]any{"ghsa": []any{"GHSA-a"}}, false},
+		{"code scanning rule severity", "code_scanning_alert", codeScanning("warning", ""), map[string]any{"min_severity": "high"}, false},
+		{"code scanning security severity", "code_scanning_alert", codeScanning("warning", "critical"), map[string]any{"security_severity_level": "high"}, true},
+		{"code scanning without security severity", "code_scanning_alert", codeScanning("error", ""), map[string]any{"security_severity_level": "low"}, false},
+		{"secret scanning has no severity", "secret_scanning_alert", map[string]any{"alert": map[string]any{}}, map[string]any{"min_severity": "critical"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MatchEvent(tt.eventType, "created", "", tt.payload, nil, map[string]any{tt.eventType: tt.filter})
 			if got != tt.want {
 				t.Errorf("MatchEvent() = %v, want %v", got, tt.want)
 			}
@@ -0,0 +1,99 @@
+package matcher
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gobwas/glob"
+	"github.com/hnrobert/feishu-github-tracker/internal/config"
+)
+
+// OwnedFiles is the subset of changed files owned by one notification target.
+type OwnedFiles struct {
+	Target string
+	Files  []string
+}
+
+// MatchOwners assigns every changed file to the targets of the LAST ownership
+// rule matching it (CODEOWNERS semantics) and groups the files per target, in
+// order of first appearance. Files without owners are dropped.
+func MatchOwners(files []string, rules []config.OwnerRule) ([]OwnedFiles, error) {
+	compiled := make([][]glob.Glob, len(rules))
+	for i, rule := range rules {
+		for _, pattern := range rule.Paths {
+			globs, err := compileOwnerPattern(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid owner path pattern %s: %w", pattern, err)
+			}
+			compiled[i] = append(compiled[i], globs...)
+		}
+	}
+
+	var result []OwnedFiles
+	index := make(map[string]int)
+	for _, file := range files {
+		owner := -1
+		for i := len(rules) - 1; i >= 0 && owner < 0; i-- {
+			for _, g := range compiled[i] {
+				if g.Match(file) {
+					owner = i
+					break
+				}
+			}
+		}
+		if owner < 0 {
+			continue
+		}
+		for _, target := range rules[owner].NotifyTo {
+			idx, ok := index[target]
+			if !ok {
+				idx = len(result)
+				index[target] = idx
+				result = append(result, OwnedFiles{Target: target})
+			}
+			result[idx].Files = append(result[idx].Files, file)
+		}
+	}
+	return result, nil
+}
+
+// compileOwnerPattern translates a CODEOWNERS path pattern into globs over
+// repository-relative paths:
+//   - a leading "/" anchors the pattern at the repository root;
+//   - a pattern without any other "/" matches at any depth ("*.go", "docs");
+//   - a trailing "/" (or a plain name) also matches everything below it.
+func compileOwnerPattern(pattern string) ([]glob.Glob, error) {
+	p := strings.TrimSpace(pattern)
+	anchored := strings.HasPrefix(p, "/")
+	p = strings.TrimPrefix(p, "/")
+	dirOnly := strings.HasSuffix(p, "/")
+	p = strings.TrimSuffix(p, "/")
+	if p == "" {
+		return nil, fmt.Errorf("empty pattern")
+	}
+
+	bases := []string{p}
+	if !anchored && !strings.Contains(p, "/") {
+		bases = append(bases, "**/"+p)
+	}
+
+	var candidates []string
+	for _, base := range bases {
+		if !dirOnly {
+			candidates = append(candidates, base)
+		}
+		if dirOnly || !strings.HasSuffix(base, "*") {
+			candidates = append(candidates, base+"/**")
+		}
+	}
+
+	globs := make([]glob.Glob, 0, len(candidates))
+	for _, c := range candidates {
+		g, err := glob.Compile(c, '/')
+		if err != nil {
+			return nil, err
+		}
+		globs = append(globs, g)
+	}
+	return globs, nil
+}
@@ -0,0 +1,159 @@
+package matcher
+
+import (
+	"slices"
+	"strings"
+)
+
+// severityRanks orders alert severities. GitHub advisories say "moderate"
+// where code scanning says "medium"; both rank the same.
+var severityRanks = map[string]int{"low": 1, "medium": 2, "moderate": 2, "high": 3, "critical": 4}
+
+// ruleSeverities maps code scanning rule severities (used by queries without
+// a security severity) onto the alert severity scale.
+var ruleSeverities = map[string]string{"note": "low", "warning": "medium", "error": "high"}
+
+// IsSecurityAlert reports whether eventType is one of the security alert
+// events the severity filters and tags apply to.
+func IsSecurityAlert(eventType string) bool {
+	switch eventType {
+	case "dependabot_alert", "code_scanning_alert", "secret_scanning_alert", "repository_vulnerability_alert":
+		return true
+	}
+	return false
+}
+
+// AlertSeverity returns the normalized severity (low, medium, high or
+// critical) of a security alert event, or "" when it has none (secret
+// scanning alerts, code scanning rules with severity "none").
+func AlertSeverity(eventType string, payload map[string]any) string {
+	alert, _ := payload["alert"].(map[string]any)
+	var severity string
+	switch eventType {
+	case "dependabot_alert":
+		severity = stringAt(alert, "security_advisory", "severity")
+		if severity == "" {
+			severity = stringAt(alert, "security_vulnerability", "severity")
+		}
+	case "repository_vulnerability_alert":
+		severity = stringAt(alert, "severity")
+	case "code_scanning_alert":
+		severity = stringAt(alert, "rule", "security_severity_level")
+		if severity == "" {
+			severity = ruleSeverities[strings.ToLower(stringAt(alert, "rule", "severity"))]
+		}
+	}
+	severity = strings.ToLower(severity)
+	if severity == "moderate" {
+		severity = "medium"
+	}
+	if _, ok := severityRanks[severity]; !ok {
+		return ""
+	}
+	return severity
+}
+
+// AlertEcosystem returns the package ecosystem (npm, pip, maven, ...) of a
+// Dependabot alert, or "".
+func AlertEcosystem(eventType string, payload map[string]any) string {
+	if eventType != "dependabot_alert" {
+		return ""
+	}
+	alert, _ := payload["alert"].(map[string]any)
+	if eco := stringAt(alert, "dependency", "package", "ecosystem"); eco != "" {
+		return strings.ToLower(eco)
+	}
+	return strings.ToLower(stringAt(alert, "security_vulnerability", "package", "ecosystem"))
+}
+
+// AlertIdentifiers returns the advisory identifiers (GHSA and CVE ids) of a
+// Dependabot or repository vulnerability alert.
+func AlertIdentifiers(eventType string, payload map[string]any) []string {
+	alert, _ := payload["alert"].(map[string]any)
+	var ids []string
+	add := func(id string) {
+		if id != "" && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	switch eventType {
+	case "dependabot_alert":
+		add(stringAt(alert, "security_advisory", "ghsa_id"))
+		add(stringAt(alert, "security_advisory", "cve_id"))
+		advisory, _ := alert["security_advisory"].(map[string]any)
+		identifiers, _ := advisory["identifiers"].([]any)
+		for _, item := range identifiers {
+			if m, ok := item.(map[string]any); ok {
+				add(stringAt(m, "value"))
+			}
+		}
+	case "repository_vulnerability_alert":
+		add(stringAt(alert, "ghsa_id"))
+		add(stringAt(alert, "external_identifier"))
+	}
+	return ids
+}
+
+// matchSecurityFilters applies the security alert filter keys of an event
+// config: min_severity, security_severity_level (code scanning), ecosystems
+// and the ghsa / cve allowlists. A filter only applies to alerts that carry
+// the field it checks, except security_severity_level, which drops code
+// scanning alerts of queries without a security severity.
+func matchSecurityFilters(eventType string, payload map[string]any, configMap map[string]any) bool {
+	if min, ok := configMap["min_severity"].(string); ok && min != "" {
+		if severity := AlertSeverity(eventType, payload); severity != "" && !severityAtLeast(severity, min) {
+			return false
+		}
+	}
+	if min, ok := configMap["security_severity_level"].(string); ok && min != "" && eventType == "code_scanning_alert" {
+		alert, _ := payload["alert"].(map[string]any)
+		if !severityAtLeast(strings.ToLower(stringAt(alert, "rule", "security_severity_level")), min) {
+			return false
+		}
+	}
+	if ecosystems := stringList(configMap["ecosystems"]); len(ecosystems) > 0 {
+		if eco := AlertEcosystem(eventType, payload); eco != "" && !slices.Contains(ecosystems, eco) {
+			return false
+		}
+	}
+	allowed := append(stringList(configMap["ghsa"]), stringList(configMap["cve"])...)
+	if len(allowed) > 0 {
+		ids := AlertIdentifiers(eventType, payload)
+		if len(ids) > 0 && !slices.ContainsFunc(ids, func(id string) bool { return slices.Contains(allowed, strings.ToLower(id)) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// severityAtLeast reports whether severity ranks at or above min. An unknown
+// severity ranks below everything.
+func severityAtLeast(severity, min string) bool {
+	return severityRanks[severity] >= severityRanks[strings.ToLower(min)] && severityRanks[severity] > 0
+}
+
+// stringList converts a YAML list to lowercase strings.
+func stringList(v any) []string {
+	items, _ := v.([]any)
+	var out []string
+	for _, item := range items {
+		if s, ok := item.(string); ok && s != "" {
+			out = append(out, strings.ToLower(s))
+		}
+	}
+	return out
+}
+
+// stringAt returns the string at a path of nested objects, or "".
+func stringAt(m map[string]any, path ...string) string {
+	var cur any = m
+	for _, key := range path {
+		obj, ok := cur.(map[string]any)
+		if !ok {
+			return ""
+		}
+		cur = obj[key]
+	}
+	s, _ := cur.(string)
+	return s
+}
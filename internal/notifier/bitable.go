@@ -0,0 +1,92 @@
+package notifier
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/hnrobert/feishu-github-tracker/internal/config"
+	"github.com/hnrobert/feishu-github-tracker/internal/feishu"
+	"github.com/hnrobert/feishu-github-tracker/internal/logger"
+	"github.com/hnrobert/feishu-github-tracker/internal/template"
+)
+
+// bitableSink records each event envelope as a row of a Feishu Bitable
+// table, rendering the bot's per-event field mapping from the template data.
+type bitableSink struct {
+	app         *feishu.Client // nil unless feishu_app is configured
+	appToken    string
+	tableID     string
+	uniqueField string
+	fields      map[string]map[string]string
+
+	mu sync.Mutex // serializes find-then-write so one key never gets two rows
+}
+
+func newBitableSink(app *feishu.Client, bot config.FeishuBot) *bitableSink {
+	return &bitableSink{
+		app:         app,
+		appToken:    bot.AppToken,
+		tableID:     bot.TableID,
+		uniqueField: bot.UniqueField,
+		fields:      bot.Fields,
+	}
+}
+
+func (s *bitableSink) Send(envelope map[string]any) error {
+	if s.app == nil {
+		return fmt.Errorf("bitable target requires feishu_app credentials")
+	}
+	if s.appToken == "" || s.tableID == "" {
+		return fmt.Errorf("bitable target requires app_token and table_id")
+	}
+	event, _ := envelope["event"].(string)
+	mapping, ok := s.fields[event]
+	if !ok {
+		mapping, ok = s.fields["default"]
+	}
+	if !ok {
+		logger.Debug("No bitable field mapping for %s in table %s, not recording it", event, s.tableID)
+		return nil
+	}
+	data, _ := envelope["data"].(map[string]any)
+	fields := renderFields(mapping, data)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.uniqueField != "" {
+		key, ok := fields[s.uniqueField]
+		if !ok {
+			return fmt.Errorf("bitable unique field %q did not render for %s", s.uniqueField, event)
+		}
+		recordID, err := s.app.FindRecord(s.appToken, s.tableID, s.uniqueField, fmt.Sprint(key))
+		if err != nil {
+			return fmt.Errorf("bitable search: %w", err)
+		}
+		if recordID != "" {
+			logger.Debug("Updating bitable record %s (%s = %v)", recordID, s.uniqueField, key)
+			return s.app.UpdateRecord(s.appToken, s.tableID, recordID, fields)
+		}
+	}
+	recordID, err := s.app.CreateRecord(s.appToken, s.tableID, fields)
+	if err != nil {
+		return err
+	}
+	logger.Debug("Created bitable record %s in table %s", recordID, s.tableID)
+	return nil
+}
+
+// renderFields renders a field mapping. Fields whose expression references
+// missing data are left out, so an update keeps their previous value.
+func renderFields(mapping map[string]string, data map[string]any) map[string]any {
+	fields := make(map[string]any, len(mapping))
+	for name, expr := range mapping {
+		value, ok := template.RenderValue(expr, data)
+		if !ok {
+			logger.Debug("Bitable field %q: %s did not render, skipping it", name, expr)
+			continue
+		}
+		fields[name] = value
+	}
+	return fields
+}
@@ -0,0 +1,146 @@
+package notifier
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hnrobert/feishu-github-tracker/internal/config"
+	"github.com/hnrobert/feishu-github-tracker/internal/logger"
+)
+
+// fakeBitable is an in-memory bitable/v1 records API for one table.
+type fakeBitable struct {
+	mu      sync.Mutex
+	records map[string]map[string]any // record_id -> fields
+	calls   []string
+}
+
+func (f *fakeBitable) handler() http.Handler {
+	const records = "/open-apis/bitable/v1/apps/bascn1/tables/tbl1/records"
+	reply := func(w http.ResponseWriter, data any) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"code": 0, "msg": "success", "data": data})
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/open-apis/auth/v3/tenant_access_token/internal", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"code":0,"tenant_access_token":"t","expire":7200}`))
+	})
+	mux.HandleFunc(records+"/search", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Filter struct {
+				Conditions []struct {
+					FieldName string   `json:"field_name"`
+					Value     []string `json:"value"`
+				} `json:"conditions"`
+			} `json:"filter"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		cond := body.Filter.Conditions[0]
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.calls = append(f.calls, "search")
+		items := []map[string]any{}
+		for id, fields := range f.records {
+			if v, ok := fields[cond.FieldName]; ok && v == cond.Value[0] {
+				items = append(items, map[string]any{"record_id": id, "fields": fields})
+			}
+		}
+		reply(w, map[string]any{"items": items, "total": len(items)})
+	})
+	mux.HandleFunc(records, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Fields map[string]any `json:"fields"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.calls = append(f.calls, "create")
+		id := "rec" + string(rune('a'+len(f.records)))
+		f.records[id] = body.Fields
+		reply(w, map[string]any{"record": map[string]any{"record_id": id, "fields": body.Fields}})
+	})
+	mux.HandleFunc(records+"/", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Fields map[string]any `json:"fields"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		id := strings.TrimPrefix(r.URL.Path, records+"/")
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.calls = append(f.calls, r.Method+" "+id)
+		for k, v := range body.Fields {
+			f.records[id][k] = v
+		}
+		reply(w, map[string]any{"record": map[string]any{"record_id": id}})
+	})
+	return mux
+}
+
+func TestBitableSink_Upserts(t *testing.T) {
+	_ = logger.Init("debug", t.TempDir())
+
+	fake := &fakeBitable{records: map[string]map[string]any{}}
+	srv := httptest.NewServer(fake.handler())
+	defer srv.Close()
+
+	n := New(config.FeishuBotsConfig{
+		FeishuApp: config.FeishuAppConfig{AppID: "cli_x", AppSecret: "s", BaseURL: srv.URL},
+		FeishuBots: []config.FeishuBot{{
+			Alias:       "ledger",
+			Type:        "bitable",
+			AppToken:    "bascn1",
+			TableID:     "tbl1",
+			UniqueField: "PR",
+			Fields: map[string]map[string]string{
+				"pull_request": {
+					"PR":     "{{repo_full_name}}#{{pr_number}}",
+					"Number": "{{pr_number}}",
+					"Title":  "{{pr_title}}",
+					"State":  "{{pr_state}}",
+					"Merged": "{{pr_merged}}",
+				},
+			},
+		}},
+	})
+	envelope := func(event, state string, merged any) map[string]any {
+		data := map[string]any{
+			"repo_full_name": "org/repo",
+			"pr_number":      float64(7),
+			"pr_title":       "Add ledger",
+			"pr_state":       state,
+		}
+		if merged != nil {
+			data["pr_merged"] = merged
+		}
+		return map[string]any{"event": event, "data": data}
+	}
+
+	if err := n.Send([]string{"ledger"}, envelope("pull_request", "open", nil)); err != nil {
+		t.Fatalf("first send: %v", err)
+	}
+	if err := n.Send([]string{"ledger"}, envelope("pull_request", "closed", true)); err != nil {
+		t.Fatalf("second send: %v", err)
+	}
+	// Events without a mapping are not recorded.
+	if err := n.Send([]string{"ledger"}, envelope("push", "", nil)); err != nil {
+		t.Fatalf("unmapped event: %v", err)
+	}
+
+	if got := strings.Join(fake.calls, ","); got != "search,create,search,PUT reca" {
+		t.Fatalf("calls = %s", got)
+	}
+	row := fake.records["reca"]
+	if len(fake.records) != 1 || row["PR"] != "org/repo#7" || row["Number"] != float64(7) ||
+		row["State"] != "closed" || row["Merged"] != true || row["Title"] != "Add ledger" {
+		t.Fatalf("records = %v", fake.records)
+	}
+
+	// Bitable targets need the app credentials.
+	noApp := New(config.FeishuBotsConfig{FeishuBots: []config.FeishuBot{{Alias: "ledger", Type: "bitable", AppToken: "bascn1", TableID: "tbl1"}}})
+	if err := noApp.Send([]string{"ledger"}, envelope("pull_request", "open", nil)); err == nil {
+		t.Fatal("expected an error without feishu_app")
+	}
+}
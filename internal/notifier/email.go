@@ -0,0 +1,227 @@
+package notifier
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hnrobert/feishu-github-tracker/internal/config"
+	"github.com/hnrobert/feishu-github-tracker/internal/logger"
+)
+
+// emailSink sends rendered "email" templates ({"subject", "text", "html"})
+// to the bot's recipients through the configured SMTP server.
+type emailSink struct {
+	smtp config.SMTPConfig
+	to   []string
+	now  func() time.Time
+}
+
+func newEmailSink(cfg config.SMTPConfig, bot config.FeishuBot) *emailSink {
+	if password := os.Getenv("SMTP_PASSWORD"); password != "" {
+		cfg.Password = password
+	}
+	return &emailSink{smtp: cfg, to: bot.To, now: time.Now}
+}
+
+func (s *emailSink) Send(payload map[string]any) error {
+	if s.smtp.Host == "" || s.smtp.From == "" {
+		return fmt.Errorf("email target requires smtp.host and smtp.from")
+	}
+	if len(s.to) == 0 {
+		return fmt.Errorf("email target has no recipients")
+	}
+	subject, _ := payload["subject"].(string)
+	text, _ := payload["text"].(string)
+	html, _ := payload["html"].(string)
+	if subject == "" || (text == "" && html == "") {
+		return fmt.Errorf("email template must render a subject and a text or html body")
+	}
+
+	from, err := mail.ParseAddress(s.smtp.From)
+	if err != nil {
+		return fmt.Errorf("invalid smtp.from: %w", err)
+	}
+	var to []*mail.Address
+	var rcpts []string
+	for _, raw := range s.to {
+		addr, err := mail.ParseAddress(raw)
+		if err != nil {
+			return fmt.Errorf("invalid recipient %q: %w", raw, err)
+		}
+		to = append(to, addr)
+		rcpts = append(rcpts, addr.Address)
+	}
+
+	msg, err := s.buildMessage(from, to, subject, text, html)
+	if err != nil {
+		return err
+	}
+	logger.Debug("Sending email %q to %s via %s", subject, strings.Join(rcpts, ", "), s.smtp.Host)
+	return s.deliver(from.Address, rcpts, msg)
+}
+
+// buildMessage renders a MIME message: multipart/alternative when both
+// bodies are set, otherwise a single quoted-printable part.
+func (s *emailSink) buildMessage(from *mail.Address, to []*mail.Address, subject, text, html string) ([]byte, error) {
+	var buf bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&buf, "%s: %s\r\n", k, v) }
+	recipients := make([]string, len(to))
+	for i, addr := range to {
+		recipients[i] = addr.String()
+	}
+	header("From", from.String())
+	header("To", strings.Join(recipients, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", subject))
+	header("Date", s.now().Format(time.RFC1123Z))
+	header("Message-ID", messageID(from.Address))
+	header("MIME-Version", "1.0")
+
+	if text == "" || html == "" {
+		contentType := "text/plain; charset=utf-8"
+		body := text
+		if html != "" {
+			contentType, body = "text/html; charset=utf-8", html
+		}
+		header("Content-Type", contentType)
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, body); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+	header("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+	buf.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// messageID returns a unique Message-ID in the sender's domain.
+func messageID(from string) string {
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		domain = from[at+1:]
+	}
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
+
+// deliver sends msg over SMTP, enforcing the configured TLS mode.
+func (s *emailSink) deliver(from string, rcpts []string, msg []byte) error {
+	mode := s.smtp.TLS
+	if mode == "" {
+		mode = "starttls"
+	}
+	port := s.smtp.Port
+	if port == 0 {
+		port = 587
+		if mode == "tls" {
+			port = 465
+		}
+	}
+	timeout := 15 * time.Second
+	if s.smtp.Timeout > 0 {
+		timeout = time.Duration(s.smtp.Timeout) * time.Second
+	}
+	addr := net.JoinHostPort(s.smtp.Host, strconv.Itoa(port))
+	tlsConfig := &tls.Config{ServerName: s.smtp.Host}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	var err error
+	switch mode {
+	case "tls":
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	case "starttls", "none":
+		conn, err = dialer.Dial("tcp", addr)
+	default:
+		return fmt.Errorf("unknown smtp.tls mode %q", mode)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+
+	c, err := smtp.NewClient(conn, s.smtp.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp handshake with %s: %w", addr, err)
+	}
+	defer c.Close()
+
+	if mode == "starttls" {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("smtp server %s does not support STARTTLS", addr)
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("smtp STARTTLS: %w", err)
+		}
+	}
+	if s.smtp.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.smtp.Username, s.smtp.Password, s.smtp.Host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return fmt.Errorf("smtp MAIL FROM: %w", err)
+	}
+	for _, rcpt := range rcpts {
+		if err := c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("smtp RCPT TO %s: %w", rcpt, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	return c.Quit()
+}
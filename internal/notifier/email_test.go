@@ -0,0 +1,201 @@
+package notifier
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hnrobert/feishu-github-tracker/internal/config"
+	"github.com/hnrobert/feishu-github-tracker/internal/logger"
+)
+
+type fakeMail struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+// fakeSMTP is a minimal plain-text SMTP server that records what it receives.
+type fakeSMTP struct {
+	ln    net.Listener
+	mu    sync.Mutex
+	mails []fakeMail
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &fakeSMTP{ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *fakeSMTP) port() int { return s.ln.Addr().(*net.TCPAddr).Port }
+
+func (s *fakeSMTP) received() []fakeMail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]fakeMail(nil), s.mails...)
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	r := textproto.NewReader(bufio.NewReader(conn))
+	reply := func(line string) { _, _ = io.WriteString(conn, line+"\r\n") }
+	reply("220 fake ESMTP")
+	var m fakeMail
+	for {
+		line, err := r.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250-fake")
+			reply("250 AUTH PLAIN")
+		case strings.HasPrefix(cmd, "AUTH PLAIN "):
+			raw, _ := base64.StdEncoding.DecodeString(line[len("AUTH PLAIN "):])
+			m.auth = string(raw)
+			reply("235 ok")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			m.from = strings.Trim(line[len("MAIL FROM:"):], "<> ")
+			reply("250 ok")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			rcpt := strings.Trim(line[len("RCPT TO:"):], "<> ")
+			if strings.HasPrefix(rcpt, "reject@") {
+				reply("550 no such user")
+				continue
+			}
+			m.to = append(m.to, rcpt)
+			reply("250 ok")
+		case cmd == "DATA":
+			reply("354 go ahead")
+			data, _ := r.ReadDotBytes()
+			m.data = string(data)
+			s.mu.Lock()
+			s.mails = append(s.mails, m)
+			s.mu.Unlock()
+			reply("250 queued")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 unsupported")
+		}
+	}
+}
+
+func TestEmailSink_SendsMultipart(t *testing.T) {
+	_ = logger.Init("debug", t.TempDir())
+	srv := newFakeSMTP(t)
+
+	smtpCfg := config.SMTPConfig{
+		Host:     "127.0.0.1",
+		Port:     srv.port(),
+		Username: "tracker",
+		Password: "pw",
+		From:     "Tracker <tracker@example.com>",
+		TLS:      "none",
+	}
+	n := New(config.FeishuBotsConfig{
+		SMTP: smtpCfg,
+		FeishuBots: []config.FeishuBot{
+			{Alias: "releases", Type: "email", To: []string{"dev@example.com", "Ops <ops@example.com>"}},
+		},
+	})
+	payload := map[string]any{
+		"subject": "🚀 Release v1.2.0",
+		"text":    "Release v1.2.0 is out",
+		"html":    "<p>Release <b>v1.2.0</b> is out</p>",
+	}
+	if err := n.Send([]string{"releases"}, payload); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	mails := srv.received()
+	if len(mails) != 1 {
+		t.Fatalf("received %d mails, want 1", len(mails))
+	}
+	got := mails[0]
+	if got.auth != "\x00tracker\x00pw" {
+		t.Errorf("auth = %q", got.auth)
+	}
+	if got.from != "tracker@example.com" || strings.Join(got.to, ",") != "dev@example.com,ops@example.com" {
+		t.Errorf("envelope from=%s to=%v", got.from, got.to)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(got.data))
+	if err != nil {
+		t.Fatalf("parse message: %v", err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if subject != "🚀 Release v1.2.0" {
+		t.Errorf("subject = %q", subject)
+	}
+	mediaType, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("content type = %s", mediaType)
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	var parts []string
+	for {
+		p, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		body, _ := io.ReadAll(p) // quoted-printable is decoded by NextPart
+		parts = append(parts, p.Header.Get("Content-Type")+": "+string(body))
+	}
+	want := []string{
+		"text/plain; charset=utf-8: Release v1.2.0 is out",
+		"text/html; charset=utf-8: <p>Release <b>v1.2.0</b> is out</p>",
+	}
+	if strings.Join(parts, "\n") != strings.Join(want, "\n") {
+		t.Errorf("parts = %q, want %q", parts, want)
+	}
+
+	// A rejected recipient fails the delivery.
+	n = New(config.FeishuBotsConfig{
+		SMTP:       smtpCfg,
+		FeishuBots: []config.FeishuBot{{Alias: "bad", Type: "email", To: []string{"reject@example.com"}}},
+	})
+	if err := n.Send([]string{"bad"}, payload); err == nil || !strings.Contains(err.Error(), "550") {
+		t.Errorf("rejected recipient: err = %v, want 550", err)
+	}
+}
+
+func TestEmailSink_RequiresStartTLS(t *testing.T) {
+	_ = logger.Init("debug", t.TempDir())
+	srv := newFakeSMTP(t)
+
+	sink := newEmailSink(config.SMTPConfig{Host: "127.0.0.1", Port: srv.port(), From: "tracker@example.com"},
+		config.FeishuBot{To: []string{"dev@example.com"}})
+	err := sink.Send(map[string]any{"subject": "s", "text": "t"})
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Fatalf("err = %v, want STARTTLS error", err)
+	}
+	if len(srv.received()) != 0 {
+		t.Fatal("mail was sent without STARTTLS")
+	}
+}
@@ -1,52 +1,156 @@
 package notifier
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/hnrobert/feishu-github-tracker/internal/config"
+	"github.com/hnrobert/feishu-github-tracker/internal/feishu"
 	"github.com/hnrobert/feishu-github-tracker/internal/logger"
+	"github.com/hnrobert/feishu-github-tracker/internal/store"
 )
 
-// Notifier handles sending notifications to Feishu webhooks
+// Threading modes for app bots (see config.FeishuBot.Threading).
+const (
+	ThreadingUpdate = "update"
+	ThreadingReply  = "reply"
+)
+
+// Notifier handles sending notifications to webhook sinks (Feishu, DingTalk,
+// WeCom) and, when an app is configured, to Feishu chats, users and Bitable
+// tables through the Open API.
 type Notifier struct {
-	bots   map[string]string
-	client *http.Client
+	bots       map[string]string // alias -> webhook URL
+	sinks      map[string]Sink   // alias -> sink for bots
+	appTargets map[string]string // alias -> app receive target (e.g. "chat:oc_xxx")
+	threading  map[string]string // alias -> threading mode, for app bots
+	app        *feishu.Client    // nil unless feishu_app is configured
+	threads    *store.Threads    // nil disables threading
+	client     *http.Client
 }
 
 // New creates a new Notifier
 func New(botsConfig config.FeishuBotsConfig) *Notifier {
+	return newNotifier(botsConfig, nil)
+}
+
+// Renew creates a Notifier for a reloaded configuration. It keeps n's
+// threads and, when the feishu_app credentials are unchanged, n's Open API
+// client so its cached tenant_access_token survives the reload.
+func (n *Notifier) Renew(botsConfig config.FeishuBotsConfig) *Notifier {
+	var app *feishu.Client
+	if cfg := botsConfig.FeishuApp; n.app != nil && cfg.AppID != "" && n.app.Is(cfg.AppID, cfg.AppSecret, cfg.BaseURL) {
+		app = n.app
+	}
+	renewed := newNotifier(botsConfig, app)
+	renewed.threads = n.threads
+	return renewed
+}
+
+// newNotifier creates a Notifier, using app as the Open API client if it is
+// not nil.
+func newNotifier(botsConfig config.FeishuBotsConfig, app *feishu.Client) *Notifier {
 	bots := make(map[string]string)
+	appTargets := make(map[string]string)
+	threading := make(map[string]string)
+	client := &http.Client{
+		Timeout: 15 * time.Second,
+	}
+	if cfg := botsConfig.FeishuApp; app == nil && cfg.AppID != "" {
+		app = feishu.NewClient(cfg.AppID, cfg.AppSecret, cfg.BaseURL, client)
+	}
+	sinks := make(map[string]Sink)
 	for _, bot := range botsConfig.FeishuBots {
+		if bot.Type == config.BotTypeApp {
+			appTargets[bot.Alias] = bot.ReceiveID
+			threading[bot.Alias] = bot.Threading
+			if bot.Threading == "" {
+				threading[bot.Alias] = botsConfig.FeishuApp.Threading
+			}
+			continue
+		}
+		if bot.Type == config.BotTypeEmail {
+			sinks[bot.Alias] = newEmailSink(botsConfig.SMTP, bot)
+			continue
+		}
+		if bot.Type == config.BotTypeBitable {
+			sinks[bot.Alias] = newBitableSink(app, bot)
+			continue
+		}
 		bots[bot.Alias] = bot.URL
+		sinks[bot.Alias] = newSink(bot, client)
 	}
 
-	return &Notifier{
-		bots: bots,
-		client: &http.Client{
-			Timeout: 15 * time.Second,
-		},
+	n := &Notifier{
+		bots:       bots,
+		sinks:      sinks,
+		appTargets: appTargets,
+		threading:  threading,
+		app:        app,
+		client:     client,
 	}
+	return n
+}
+
+// App returns the Open API client, or nil when no feishu_app is configured.
+func (n *Notifier) App() *feishu.Client {
+	return n.app
+}
+
+// SetThreads enables threaded delivery for app bots, recording each PR or
+// issue's first card in threads.
+func (n *Notifier) SetThreads(threads *store.Threads) {
+	n.threads = threads
 }
 
 // Send sends a notification to the specified targets
 func (n *Notifier) Send(targets []string, payload map[string]any) error {
+	return n.SendThread(targets, payload, "", nil)
+}
+
+// Threaded reports whether any of targets is an app bot with threading
+// enabled, i.e. whether SendThread would group its cards.
+func (n *Notifier) Threaded(targets []string) bool {
+	if n.threads == nil {
+		return false
+	}
+	for _, target := range targets {
+		if mode := n.threading[target]; mode == ThreadingUpdate || mode == ThreadingReply {
+			return true
+		}
+	}
+	return false
+}
+
+// SendThread is like Send, but app bots with threading enabled group the
+// cards that share threadKey ("owner/repo#number"): the first card is sent
+// as usual, and later ones replace it with the status card (update) or reply
+// in its thread (reply, or update without a status card). An empty key
+// sends a new message, as Send does.
+func (n *Notifier) SendThread(targets []string, payload map[string]any, threadKey string, status map[string]any) error {
 	var errs []string
 
 	for _, target := range targets {
-		url := n.resolveURL(target)
-		if url == "" {
+		if idType, id, ok := n.resolveAppTarget(target); ok {
+			if err := n.sendToApp(idType, id, payload, threadKey, n.threading[target], status); err != nil {
+				logger.Error("Failed to send notification to %s: %v", target, err)
+				errs = append(errs, err.Error())
+			} else {
+				logger.Info("Successfully sent notification to %s", target)
+			}
+			continue
+		}
+
+		sink := n.resolveSink(target)
+		if sink == nil {
 			logger.Warn("Failed to resolve target: %s", target)
 			continue
 		}
 
-		if err := n.sendToWebhook(url, payload); err != nil {
-			logger.Error("Failed to send notification to %s: %v", url, err)
+		if err := sink.Send(payload); err != nil {
+			logger.Error("Failed to send notification to %s: %v", target, err)
 			errs = append(errs, err.Error())
 		} else {
 			logger.Info("Successfully sent notification to %s", target)
@@ -74,33 +178,88 @@ func (n *Notifier) resolveURL(target string) string {
 	return ""
 }
 
-func (n *Notifier) sendToWebhook(url string, payload map[string]any) error {
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
+// resolveSink returns the sink for a bot alias, or a Feishu webhook sink for
+// a direct URL.
+func (n *Notifier) resolveSink(target string) Sink {
+	if sink, exists := n.sinks[target]; exists {
+		return sink
+	}
+	if url := n.resolveURL(target); url != "" {
+		return &feishuSink{url: url, client: n.client}
 	}
+	return nil
+}
 
-	logger.Debug("Sending payload to %s: %s", url, string(jsonData))
+// resolveAppTarget resolves an app-bot alias or an inline chat:/user: target
+// into an Open API receive_id type and value.
+func (n *Notifier) resolveAppTarget(target string) (string, string, bool) {
+	if receiveID, exists := n.appTargets[target]; exists {
+		return feishu.ParseReceiveTarget(receiveID)
+	}
+	if _, exists := n.sinks[target]; exists {
+		return "", "", false
+	}
+	return feishu.ParseReceiveTarget(target)
+}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+// sendToApp delivers payload through the Open API. With a threading mode and
+// a thread key, a card already sent for the thread to the same receiver is
+// patched with status (update) or replied to with payload (reply, or update
+// without a status card); if that fails, or there is none yet, payload is
+// sent as a new message and remembered for the thread.
+func (n *Notifier) sendToApp(idType, id string, payload map[string]any, threadKey, mode string, status map[string]any) error {
+	if n.app == nil {
+		return fmt.Errorf("no feishu_app configured for app target %s:%s", idType, id)
+	}
+	threaded := threadKey != "" && n.threads != nil && (mode == ThreadingUpdate || mode == ThreadingReply)
+	if threaded && mode == ThreadingUpdate {
+		feishu.EnableSharedCard(payload)
+	}
+	msgType, content, err := feishu.ConvertWebhookPayload(payload)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	receiver := idType + ":" + id
+	if threaded {
+		if anchor := n.threads.Message(threadKey, receiver); anchor != "" {
+			err = n.thread(anchor, threadKey, mode, msgType, content, status)
+			if err == nil {
+				return nil
+			}
+			logger.Warn("Failed to thread %s onto message %s, sending a new message: %v", threadKey, anchor, err)
+		}
+	}
 
-	resp, err := n.client.Do(req)
+	logger.Debug("Sending %s message to %s %s: %s", msgType, idType, id, content)
+	messageID, err := n.app.SendMessage(idType, id, msgType, content)
 	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("received non-2xx status code %d: %s", resp.StatusCode, string(body))
+	logger.Debug("Feishu Open API message_id: %s", messageID)
+	if threaded {
+		if err := n.threads.SetMessage(threadKey, receiver, messageID); err != nil {
+			logger.Warn("Failed to record message %s for %s: %v", messageID, threadKey, err)
+		}
 	}
-
-	logger.Debug("Response from webhook: %s", string(body))
 	return nil
 }
+
+// thread patches anchor with status in update mode, and otherwise replies to
+// it with the event's message.
+func (n *Notifier) thread(anchor, threadKey, mode, msgType, content string, status map[string]any) error {
+	if mode == ThreadingUpdate && status != nil {
+		feishu.EnableSharedCard(status)
+		statusType, statusContent, err := feishu.ConvertWebhookPayload(status)
+		if err != nil {
+			return err
+		}
+		if statusType == "interactive" {
+			logger.Debug("Patching message %s for %s: %s", anchor, threadKey, statusContent)
+			return n.app.PatchMessage(anchor, statusContent)
+		}
+	}
+	logger.Debug("Replying to message %s for %s: %s", anchor, threadKey, content)
+	_, err := n.app.ReplyMessage(anchor, msgType, content, true)
+	return err
+}
@@ -1,13 +1,17 @@
 package notifier
 
 import (
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/hnrobert/feishu-github-tracker/internal/config"
 	"github.com/hnrobert/feishu-github-tracker/internal/logger"
+	"github.com/hnrobert/feishu-github-tracker/internal/store"
 )
 
 func TestResolveURL(t *testing.T) {
@@ -63,3 +67,131 @@ func TestSend_SuccessAndFailure(t *testing.T) {
 		t.Fatalf("expected error when server returns non-2xx")
 	}
 }
+
+func TestSend_AppTargets(t *testing.T) {
+	_ = logger.Init("debug", t.TempDir())
+
+	var receivers []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/open-apis/auth/v3/tenant_access_token/internal", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"code":0,"tenant_access_token":"t","expire":7200}`))
+	})
+	mux.HandleFunc("/open-apis/im/v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		receivers = append(receivers, r.URL.Query().Get("receive_id_type")+"="+body["receive_id"])
+		_, _ = w.Write([]byte(`{"code":0,"data":{"message_id":"om_1"}}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	n := New(config.FeishuBotsConfig{
+		FeishuApp:  config.FeishuAppConfig{AppID: "cli_x", AppSecret: "s", BaseURL: srv.URL},
+		FeishuBots: []config.FeishuBot{{Alias: "sre-chat", Type: "app", ReceiveID: "chat:oc_sre"}},
+	})
+	payload := map[string]any{"msg_type": "text", "content": map[string]any{"text": "hi"}}
+	if err := n.Send([]string{"sre-chat", "user:alice@corp.example"}, payload); err != nil {
+		t.Fatalf("expected app send success, got error: %v", err)
+	}
+	if len(receivers) != 2 || receivers[0] != "chat_id=oc_sre" || receivers[1] != "email=alice@corp.example" {
+		t.Fatalf("unexpected receivers: %v", receivers)
+	}
+
+	// Without app credentials, app targets fail instead of being dropped.
+	if err := New(config.FeishuBotsConfig{}).Send([]string{"chat:oc_x"}, payload); err == nil {
+		t.Fatal("expected error for app target without feishu_app")
+	}
+}
+
+func TestSendThread_UpdateAndReply(t *testing.T) {
+	_ = logger.Init("debug", t.TempDir())
+
+	var calls []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/open-apis/auth/v3/tenant_access_token/internal", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"code":0,"tenant_access_token":"t","expire":7200}`))
+	})
+	mux.HandleFunc("/open-apis/im/v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		calls = append(calls, "send "+body["receive_id"])
+		if !strings.Contains(body["content"], `"update_multi":true`) && body["receive_id"] == "oc_update" {
+			t.Errorf("update-mode card not shared: %s", body["content"])
+		}
+		_, _ = w.Write([]byte(`{"code":0,"data":{"message_id":"om_` + body["receive_id"] + `"}}`))
+	})
+	mux.HandleFunc("/open-apis/im/v1/messages/", func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+strings.TrimPrefix(r.URL.Path, "/open-apis/im/v1/messages/"))
+		_, _ = w.Write([]byte(`{"code":0,"data":{"message_id":"om_reply"}}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	threads, err := store.OpenThreads(filepath.Join(t.TempDir(), "threads.json"), 0)
+	if err != nil {
+		t.Fatalf("OpenThreads() error = %v", err)
+	}
+	n := New(config.FeishuBotsConfig{
+		FeishuApp: config.FeishuAppConfig{AppID: "cli_x", AppSecret: "s", BaseURL: srv.URL, Threading: "update"},
+		FeishuBots: []config.FeishuBot{
+			{Alias: "update-chat", Type: "app", ReceiveID: "chat:oc_update"},
+			{Alias: "reply-chat", Type: "app", ReceiveID: "chat:oc_reply", Threading: "reply"},
+		},
+	})
+	n.SetThreads(threads)
+
+	card := func() map[string]any {
+		return map[string]any{"msg_type": "interactive", "card": map[string]any{"elements": []any{}}}
+	}
+	targets := []string{"update-chat", "reply-chat"}
+	if !n.Threaded(targets) || n.Threaded([]string{"chat:oc_other"}) {
+		t.Fatal("Threaded() should only report app bots with a threading mode")
+	}
+	for i := 0; i < 2; i++ {
+		if err := n.SendThread(targets, card(), "org/repo#7", card()); err != nil {
+			t.Fatalf("SendThread() error = %v", err)
+		}
+	}
+	// Without a status card, update mode replies instead of replacing the
+	// first card with a later event's.
+	if err := n.SendThread([]string{"update-chat"}, card(), "org/repo#7", nil); err != nil {
+		t.Fatalf("SendThread() error = %v", err)
+	}
+	// A different PR starts its own thread.
+	if err := n.SendThread([]string{"update-chat"}, card(), "org/repo#8", nil); err != nil {
+		t.Fatalf("SendThread() error = %v", err)
+	}
+
+	want := []string{
+		"send oc_update", "send oc_reply",
+		"PATCH om_oc_update", "POST om_oc_reply/reply",
+		"POST om_oc_update/reply",
+		"send oc_update",
+	}
+	if strings.Join(calls, ",") != strings.Join(want, ",") {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestRenewKeepsAppClient(t *testing.T) {
+	bots := config.FeishuBotsConfig{FeishuApp: config.FeishuAppConfig{AppID: "cli_a", AppSecret: "s"}}
+	n := New(bots)
+	threads, err := store.OpenThreads(filepath.Join(t.TempDir(), "threads.json"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.SetThreads(threads)
+
+	bots.FeishuBots = []config.FeishuBot{{Alias: "team", URL: "https://example.com/hook"}}
+	renewed := n.Renew(bots)
+	if renewed.App() != n.App() || renewed.threads != threads {
+		t.Fatal("Renew() with unchanged credentials dropped the app client or threads")
+	}
+	if renewed.resolveSink("team") == nil {
+		t.Fatal("Renew() did not apply the new bots")
+	}
+	bots.FeishuApp.AppSecret = "rotated"
+	if n.Renew(bots).App() == n.App() {
+		t.Fatal("Renew() kept the app client after the secret changed")
+	}
+}
@@ -0,0 +1,324 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hnrobert/feishu-github-tracker/internal/config"
+	"github.com/hnrobert/feishu-github-tracker/internal/logger"
+)
+
+// Sink delivers a rendered template payload (or, for generic targets, the
+// event envelope) to one webhook-style target. Each sink posts the payload
+// as-is and interprets its service's response.
+type Sink interface {
+	Send(payload map[string]any) error
+}
+
+// newSink returns the sink for a webhook-style bot (every type but "app").
+func newSink(bot config.FeishuBot, client *http.Client) Sink {
+	switch bot.Type {
+	case config.BotTypeDingTalk:
+		return &dingTalkSink{url: bot.URL, secret: bot.Secret, client: client, now: time.Now}
+	case config.BotTypeWeCom:
+		return &weComSink{url: bot.URL, client: client}
+	case config.BotTypeSlack:
+		return &slackSink{url: bot.URL, client: client}
+	case config.BotTypeTeams:
+		return &teamsSink{url: bot.URL, client: client}
+	case config.BotTypeGeneric:
+		if bot.Timeout > 0 {
+			client = &http.Client{Timeout: time.Duration(bot.Timeout) * time.Second}
+		}
+		return &genericSink{
+			url:     bot.URL,
+			secret:  bot.Secret,
+			headers: bot.Headers,
+			retries: bot.Retries,
+			client:  client,
+			backoff: time.Second,
+			budget:  maxDeliveryTime,
+		}
+	}
+	return &feishuSink{url: bot.URL, client: client}
+}
+
+// feishuSink posts to a Feishu custom bot webhook.
+type feishuSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *feishuSink) Send(payload map[string]any) error {
+	body, err := postJSON(s.client, s.url, payload)
+	if err != nil {
+		return err
+	}
+	// Feishu answers 200 for rejected messages too: {"code":19024,"msg":...},
+	// or {"StatusCode":0,...} from older endpoints.
+	var resp struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if json.Unmarshal(body, &resp) == nil && resp.Code != 0 {
+		return fmt.Errorf("feishu webhook error %d: %s", resp.Code, resp.Msg)
+	}
+	return nil
+}
+
+// dingTalkSink posts to a DingTalk group robot webhook, signing the request
+// when the robot uses the "加签" security setting.
+type dingTalkSink struct {
+	url    string
+	secret string
+	client *http.Client
+	now    func() time.Time
+}
+
+func (s *dingTalkSink) Send(payload map[string]any) error {
+	target := s.url
+	if s.secret != "" {
+		signed, err := dingTalkSign(s.url, s.secret, s.now())
+		if err != nil {
+			return err
+		}
+		target = signed
+	}
+	body, err := postJSON(s.client, target, payload)
+	if err != nil {
+		return err
+	}
+	return errcodeError("dingtalk", body)
+}
+
+// dingTalkSign adds the timestamp and sign query parameters: the base64
+// HMAC-SHA256 of "timestamp\nsecret" keyed by the secret.
+func dingTalkSign(rawURL, secret string, now time.Time) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid dingtalk webhook URL: %w", err)
+	}
+	timestamp := strconv.FormatInt(now.UnixMilli(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + secret))
+	q := u.Query()
+	q.Set("timestamp", timestamp)
+	q.Set("sign", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// weComSink posts to a WeCom (企业微信) group robot webhook.
+type weComSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *weComSink) Send(payload map[string]any) error {
+	body, err := postJSON(s.client, s.url, payload)
+	if err != nil {
+		return err
+	}
+	return errcodeError("wecom", body)
+}
+
+// slackSink posts Block Kit payloads to a Slack incoming webhook.
+type slackSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *slackSink) Send(payload map[string]any) error {
+	body, err := postJSON(s.client, s.url, payload)
+	if err != nil {
+		var statusErr *statusError
+		if errors.As(err, &statusErr) {
+			// Slack explains rejections in a plain-text body, e.g.
+			// "invalid_blocks" or "no_service".
+			return fmt.Errorf("slack webhook error %d: %s", statusErr.StatusCode, strings.TrimSpace(string(statusErr.Body)))
+		}
+		return err
+	}
+	if reply := strings.TrimSpace(string(body)); reply != "" && reply != "ok" {
+		return fmt.Errorf("slack webhook error: %s", reply)
+	}
+	return nil
+}
+
+// teamsSink posts Adaptive Card messages to a Microsoft Teams workflow
+// webhook (Power Automate "when a Teams webhook request is received").
+type teamsSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *teamsSink) Send(payload map[string]any) error {
+	body, err := postJSON(s.client, s.url, payload)
+	if err != nil {
+		var statusErr *statusError
+		if errors.As(err, &statusErr) {
+			var resp struct {
+				Error struct {
+					Code    string `json:"code"`
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			if json.Unmarshal(statusErr.Body, &resp) == nil && resp.Error.Code != "" {
+				return fmt.Errorf("teams webhook error %d %s: %s", statusErr.StatusCode, resp.Error.Code, resp.Error.Message)
+			}
+		}
+		return err
+	}
+	// Legacy Office 365 connector URLs answer 200 with the failure as text.
+	if reply := string(body); strings.Contains(reply, "delivery failed") {
+		return fmt.Errorf("teams webhook error: %s", reply)
+	}
+	return nil
+}
+
+// SignatureHeader carries the HMAC-SHA256 of a generic target's request
+// body, in the same "sha256=<hex>" form as GitHub's X-Hub-Signature-256.
+const SignatureHeader = "X-Tracker-Signature-256"
+
+// maxDeliveryTime bounds a generic target's delivery, retries included:
+// deliveries run while the webhook waits for its response.
+const maxDeliveryTime = 30 * time.Second
+
+// genericSink posts JSON to an arbitrary HTTP endpoint, signing the body
+// and retrying transient failures with exponential backoff.
+type genericSink struct {
+	url     string
+	secret  string
+	headers map[string]string
+	retries int
+	client  *http.Client
+	backoff time.Duration // delay before the first retry, doubled after each
+	budget  time.Duration // total time for all attempts and delays
+}
+
+func (s *genericSink) Send(payload map[string]any) error {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	header := http.Header{}
+	for k, v := range s.headers {
+		header.Set(k, v)
+	}
+	if s.secret != "" {
+		mac := hmac.New(sha256.New, []byte(s.secret))
+		mac.Write(jsonData)
+		header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.budget)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+	delay := s.backoff
+	for attempt := 0; ; attempt++ {
+		_, err = postContext(ctx, s.client, s.url, jsonData, header)
+		if err == nil || attempt >= s.retries || !retryable(err) {
+			return err
+		}
+		if time.Until(deadline) <= delay {
+			return fmt.Errorf("giving up after %d attempts in %v: %w", attempt+1, s.budget, err)
+		}
+		logger.Warn("Delivery to %s failed (attempt %d of %d), retrying in %v: %v", s.url, attempt+1, s.retries+1, delay, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// retryable reports whether a failed delivery may succeed when repeated:
+// network errors, 429 and 5xx responses.
+func retryable(err error) bool {
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	return true
+}
+
+// errcodeError interprets the {"errcode":0,"errmsg":"ok"} responses of
+// DingTalk and WeCom robots, which report failures with HTTP 200.
+func errcodeError(service string, body []byte) error {
+	var resp struct {
+		ErrCode *int   `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil || resp.ErrCode == nil {
+		return fmt.Errorf("unexpected %s response: %s", service, string(body))
+	}
+	if *resp.ErrCode != 0 {
+		return fmt.Errorf("%s robot error %d: %s", service, *resp.ErrCode, resp.ErrMsg)
+	}
+	return nil
+}
+
+// statusError is returned by postJSON for non-2xx responses.
+type statusError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("received non-2xx status code %d: %s", e.StatusCode, string(e.Body))
+}
+
+// postJSON posts payload to url and returns the body of a 2xx response.
+func postJSON(client *http.Client, url string, payload map[string]any) ([]byte, error) {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	return post(client, url, jsonData, nil)
+}
+
+// post sends a JSON body to url with the extra headers and returns the body
+// of a 2xx response.
+func post(client *http.Client, url string, jsonData []byte, header http.Header) ([]byte, error) {
+	return postContext(context.Background(), client, url, jsonData, header)
+}
+
+// postContext is post with a context bounding the request.
+func postContext(ctx context.Context, client *http.Client, url string, jsonData []byte, header http.Header) ([]byte, error) {
+	logger.Debug("Sending payload to %s: %s", url, string(jsonData))
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &statusError{StatusCode: resp.StatusCode, Body: body}
+	}
+
+	logger.Debug("Response from webhook: %s", string(body))
+	return body, nil
+}
@@ -0,0 +1,198 @@
+package notifier
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hnrobert/feishu-github-tracker/internal/config"
+	"github.com/hnrobert/feishu-github-tracker/internal/logger"
+)
+
+func TestSinks_ResponseErrors(t *testing.T) {
+	_ = logger.Init("debug", t.TempDir())
+
+	status, reply := 200, ""
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(reply))
+	}))
+	defer srv.Close()
+
+	cases := []struct {
+		botType string
+		status  int
+		reply   string
+		wantErr string
+	}{
+		{"", 200, `{"code":0,"msg":"success"}`, ""},
+		{"", 200, `{"StatusCode":0,"StatusMessage":"success"}`, ""},
+		{"", 200, `{"code":19024,"msg":"Key Words Not Found"}`, "19024"},
+		{"dingtalk", 200, `{"errcode":0,"errmsg":"ok"}`, ""},
+		{"dingtalk", 200, `{"errcode":310000,"errmsg":"sign not match"}`, "310000"},
+		{"wecom", 200, `{"errcode":0,"errmsg":"ok"}`, ""},
+		{"wecom", 200, `{"errcode":93000,"errmsg":"invalid webhook url"}`, "93000"},
+		{"wecom", 200, `<html>gateway</html>`, "unexpected wecom response"},
+		{"slack", 200, `ok`, ""},
+		{"slack", 400, `invalid_blocks`, "slack webhook error 400: invalid_blocks"},
+		{"teams", 202, ``, ""},
+		{"teams", 400, `{"error":{"code":"TriggerInputSchemaMismatch","message":"bad body"}}`, "TriggerInputSchemaMismatch: bad body"},
+		{"teams", 200, `Webhook message delivery failed with error: Microsoft Teams endpoint returned HTTP error 400`, "delivery failed"},
+	}
+	for _, tc := range cases {
+		status, reply = tc.status, tc.reply
+		sink := newSink(config.FeishuBot{Type: tc.botType, URL: srv.URL}, srv.Client())
+		err := sink.Send(map[string]any{"msgtype": "text"})
+		switch {
+		case tc.wantErr == "" && err != nil:
+			t.Errorf("%s %s: unexpected error %v", tc.botType, tc.reply, err)
+		case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+			t.Errorf("%s %s: error = %v, want %q", tc.botType, tc.reply, err, tc.wantErr)
+		}
+	}
+}
+
+func TestDingTalkSink_Signs(t *testing.T) {
+	_ = logger.Init("debug", t.TempDir())
+
+	var query map[string][]string
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		_, _ = w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	defer srv.Close()
+
+	now := time.UnixMilli(1700000000123)
+	sink := &dingTalkSink{url: srv.URL + "/robot/send?access_token=abc", secret: "SECxyz", client: srv.Client(), now: func() time.Time { return now }}
+	if err := sink.Send(map[string]any{"msgtype": "markdown"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	mac := hmac.New(sha256.New, []byte("SECxyz"))
+	mac.Write([]byte("1700000000123\nSECxyz"))
+	wantSign := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	if got := query["access_token"]; len(got) != 1 || got[0] != "abc" {
+		t.Errorf("access_token = %v, want kept", got)
+	}
+	if got := query["timestamp"]; len(got) != 1 || got[0] != "1700000000123" {
+		t.Errorf("timestamp = %v", got)
+	}
+	if got := query["sign"]; len(got) != 1 || got[0] != wantSign {
+		t.Errorf("sign = %v, want %s", got, wantSign)
+	}
+	if body != `{"msgtype":"markdown"}` {
+		t.Errorf("body = %s", body)
+	}
+}
+
+func TestSend_RoutesBotsToSinks(t *testing.T) {
+	_ = logger.Init("debug", t.TempDir())
+
+	paths := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths[r.URL.Path]++
+		if r.URL.Path == "/feishu" {
+			_, _ = w.Write([]byte(`{"code":0}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	defer srv.Close()
+
+	n := New(config.FeishuBotsConfig{FeishuBots: []config.FeishuBot{
+		{Alias: "fs", URL: srv.URL + "/feishu"},
+		{Alias: "dt", Type: "dingtalk", URL: srv.URL + "/dingtalk", Secret: "s"},
+		{Alias: "wc", Type: "wecom", URL: srv.URL + "/wecom"},
+	}})
+	if err := n.Send([]string{"fs", "dt", "wc"}, map[string]any{"msgtype": "text"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if paths["/feishu"] != 1 || paths["/dingtalk"] != 1 || paths["/wecom"] != 1 {
+		t.Fatalf("deliveries = %v, want one per sink", paths)
+	}
+}
+
+func TestGenericSink_SignsAndRetries(t *testing.T) {
+	_ = logger.Init("debug", t.TempDir())
+
+	attempts := 0
+	var header http.Header
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		header = r.Header.Clone()
+		body, _ = io.ReadAll(r.Body)
+		if attempts < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	sink := newSink(config.FeishuBot{
+		Type:    "generic",
+		URL:     srv.URL,
+		Secret:  "s3cret",
+		Headers: map[string]string{"Authorization": "Bearer abc"},
+		Timeout: 5,
+		Retries: 2,
+	}, srv.Client()).(*genericSink)
+	sink.backoff = time.Millisecond
+
+	if err := sink.Send(map[string]any{"event": "push"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if attempts != 3 {
+		t.Fatalf("attempts = %d, want 3", attempts)
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	if got, want := header.Get(SignatureHeader), "sha256="+hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Errorf("signature = %q, want %q", got, want)
+	}
+	if header.Get("Authorization") != "Bearer abc" || header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected headers: %v", header)
+	}
+
+	// 4xx responses are not retried.
+	attempts = 0
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer bad.Close()
+	sink.url = bad.URL
+	if err := sink.Send(map[string]any{"event": "push"}); err == nil {
+		t.Fatal("expected an error for 400")
+	}
+	if attempts != 1 {
+		t.Fatalf("attempts after 400 = %d, want 1", attempts)
+	}
+
+	// Retries stop when the delivery time is used up.
+	attempts = 0
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	sink.url, sink.retries, sink.backoff, sink.budget = down.URL, 10, 20*time.Millisecond, 100*time.Millisecond
+	start := time.Now()
+	if err := sink.Send(map[string]any{"event": "push"}); err == nil {
+		t.Fatal("expected an error while the target is down")
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond || attempts > 4 {
+		t.Fatalf("gave up after %d attempts in %v, want the 100ms budget kept", attempts, elapsed)
+	}
+}
@@ -18,6 +18,7 @@ import (
 
 	"github.com/hnrobert/feishu-github-tracker/internal/auth"
 	"github.com/hnrobert/feishu-github-tracker/internal/config"
+	"github.com/hnrobert/feishu-github-tracker/internal/store"
 )
 
 //go:embed templates/*.html
@@ -36,6 +37,9 @@ type Options struct {
 	// OnSave, if set, is invoked after the panel writes any config file, so the
 	// running process can reload and apply the change immediately.
 	OnSave func()
+	// Suppressions, if set, is the runtime suppression list and maintenance
+	// mode managed on the suppressions page (the page is empty without it).
+	Suppressions *store.Suppressions
 }
 
 // App holds panel state and serves HTTP.
@@ -45,6 +49,7 @@ type App struct {
 	cfgDir     string
 	logDir     string
 	onSave     func()
+	suppr      *store.Suppressions
 	pages      map[string]*template.Template
 	handler    http.Handler
 }
@@ -91,6 +96,16 @@ type ViewData struct {
 	// templates
 	TemplateFilesList []TemplateFileRow
 	EditTemplate      EditTemplateData
+
+	// suppressions
+	Suppressions  []SuppressionRow
+	Suppressed    []SuppressedRow
+	Maintenance   MaintenanceView
+	BotAliases    []string // suggestions for the suppression target field
+	SuppressionOn bool     // whether the suppression store is available
+
+	// on-call
+	OnCall []OnCallRow
 }
 
 // ServerInfo captures read-only server status shown on the dashboard.
@@ -118,10 +133,18 @@ type RepoRow struct {
 
 // BotRow represents one feishu-bots.yaml entry.
 type BotRow struct {
-	Index    int
-	Alias    string
-	URL      string
-	Template string
+	Index     int
+	Alias     string
+	URL       string
+	Template  string
+	Type      string // "" (webhook), "app", "dingtalk", "wecom", "slack", "teams", "generic", "email" or "bitable"
+	ReceiveID string // app bots: chat:oc_xxx / user:alice@corp
+	Secret    string // dingtalk and generic bots: signing secret
+	To        string // email bots: comma-separated recipients
+	// Bitable bots: the table written to; field mappings are YAML-only.
+	AppToken    string
+	TableID     string
+	UniqueField string
 }
 
 // ServerForm holds editable server.yaml fields.
@@ -210,6 +233,8 @@ func New(opts Options) (*App, error) {
 		"events",
 		"templates_list",
 		"template_edit",
+		"suppressions",
+		"oncall",
 	} {
 		t, err := base.Clone()
 		if err != nil {
@@ -227,6 +252,7 @@ func New(opts Options) (*App, error) {
 		cfgDir:     opts.ConfigDir,
 		logDir:     opts.LogDir,
 		onSave:     opts.OnSave,
+		suppr:      opts.Suppressions,
 		pages:      pages,
 	}
 	a.handler = a.withAuthContext(a.routes())
@@ -300,6 +326,13 @@ func (a *App) routes() http.Handler {
 	mux.HandleFunc("/templates/edit", a.requireAuth(a.handleTemplateEdit))
 	mux.HandleFunc("/templates/save", a.requireAuth(a.handleTemplateSave))
 
+	mux.HandleFunc("/suppressions", a.requireAuth(a.handleSuppressions))
+	mux.HandleFunc("/suppressions/add", a.requireAuth(a.handleSuppressionAdd))
+	mux.HandleFunc("/suppressions/delete", a.requireAuth(a.handleSuppressionDelete))
+	mux.HandleFunc("/suppressions/maintenance", a.requireAuth(a.handleMaintenance))
+
+	mux.HandleFunc("/oncall", a.requireAuth(a.handleOnCall))
+
 	return mux
 }
 
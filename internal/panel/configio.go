@@ -4,10 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
 	"regexp"
 	"sync"
 
+	"github.com/hnrobert/feishu-github-tracker/internal/store"
 	"gopkg.in/yaml.v3"
 )
 
@@ -16,40 +16,9 @@ import (
 // because every write is an atomic temp-file-then-rename.
 var writeMu sync.Mutex
 
-// atomicWriteFile writes data to path atomically by writing a temp file in the
-// same directory and renaming it over the target. Same-dir rename is atomic on
-// POSIX and avoids cross-device EXDEV.
+// atomicWriteFile writes data to path atomically (temp file + rename).
 func atomicWriteFile(path string, data []byte, perm os.FileMode) error {
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return fmt.Errorf("create config directory: %w", err)
-	}
-
-	tmp, err := os.CreateTemp(dir, ".tmp-*")
-	if err != nil {
-		return fmt.Errorf("create temp file: %w", err)
-	}
-	tmpName := tmp.Name()
-	cleanup := func() { _ = os.Remove(tmpName) }
-
-	if _, err := tmp.Write(data); err != nil {
-		_ = tmp.Close()
-		cleanup()
-		return fmt.Errorf("write temp file: %w", err)
-	}
-	if err := tmp.Close(); err != nil {
-		cleanup()
-		return fmt.Errorf("close temp file: %w", err)
-	}
-	if err := os.Chmod(tmpName, perm); err != nil {
-		cleanup()
-		return fmt.Errorf("chmod temp file: %w", err)
-	}
-	if err := os.Rename(tmpName, path); err != nil {
-		cleanup()
-		return fmt.Errorf("rename temp file: %w", err)
-	}
-	return nil
+	return store.WriteFileAtomic(path, data, perm)
 }
 
 // SaveYAML marshals v to YAML and writes it to path atomically.
@@ -13,7 +13,7 @@ func (a *App) handleBots(w http.ResponseWriter, r *http.Request) {
 	data := a.baseData(r)
 	if cfg, err := a.loadConfig(); err == nil {
 		for i, b := range cfg.FeishuBots.FeishuBots {
-			data.Bots = append(data.Bots, BotRow{Index: i, Alias: b.Alias, URL: b.URL, Template: b.Template})
+			data.Bots = append(data.Bots, botRow(i, b))
 		}
 		data.Templates = a.knownTemplates(cfg)
 	}
@@ -44,8 +44,7 @@ func (a *App) handleBotEdit(w http.ResponseWriter, r *http.Request) {
 		a.redirectFlash(w, r, "/bots", a.message(r, "flash.botNotFound"), "err")
 		return
 	}
-	b := cfg.FeishuBots.FeishuBots[idx]
-	data.EditBot = BotRow{Index: idx, Alias: b.Alias, URL: b.URL, Template: b.Template}
+	data.EditBot = botRow(idx, cfg.FeishuBots.FeishuBots[idx])
 	a.renderPage(w, "bot_edit", data)
 }
 
@@ -59,10 +58,42 @@ func (a *App) handleBotSave(w http.ResponseWriter, r *http.Request) {
 	alias := strings.TrimSpace(r.FormValue("alias"))
 	url := strings.TrimSpace(r.FormValue("url"))
 	tmpl := strings.TrimSpace(r.FormValue("template"))
-	if alias == "" || url == "" {
+	botType := strings.TrimSpace(r.FormValue("type"))
+	receiveID := strings.TrimSpace(r.FormValue("receive_id"))
+	secret := strings.TrimSpace(r.FormValue("secret"))
+	appToken := strings.TrimSpace(r.FormValue("app_token"))
+	tableID := strings.TrimSpace(r.FormValue("table_id"))
+	uniqueField := strings.TrimSpace(r.FormValue("unique_field"))
+	var to []string
+	for _, addr := range strings.Split(r.FormValue("to"), ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			to = append(to, addr)
+		}
+	}
+	if botType == "webhook" {
+		botType = ""
+	}
+	needsURL := botType != "app" && botType != "email" && botType != "bitable"
+	if alias == "" || (needsURL && url == "") || (botType == "app" && receiveID == "") || (botType == "email" && len(to) == 0) ||
+		(botType == "bitable" && (appToken == "" || tableID == "")) {
 		a.redirectFlash(w, r, "/bots", a.message(r, "flash.botFieldsRequired"), "err")
 		return
 	}
+	if !needsURL {
+		url = ""
+	}
+	if botType != "app" {
+		receiveID = ""
+	}
+	if botType != "email" {
+		to = nil
+	}
+	if botType != "dingtalk" && botType != "generic" {
+		secret = ""
+	}
+	if botType != "bitable" {
+		appToken, tableID, uniqueField = "", "", ""
+	}
 
 	cfg, err := a.loadConfig()
 	if err != nil {
@@ -70,7 +101,16 @@ func (a *App) handleBotSave(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	bot := config.FeishuBot{Alias: alias, URL: url, Template: tmpl}
+	// Start from the existing bot so fields the form doesn't edit survive a save.
+	var bot config.FeishuBot
+	if idx >= 0 && idx < len(cfg.FeishuBots.FeishuBots) {
+		bot = cfg.FeishuBots.FeishuBots[idx]
+	}
+	bot.Alias, bot.URL, bot.Template, bot.Type, bot.ReceiveID, bot.Secret, bot.To = alias, url, tmpl, botType, receiveID, secret, to
+	bot.AppToken, bot.TableID, bot.UniqueField = appToken, tableID, uniqueField
+	if botType != "bitable" {
+		bot.Fields = nil
+	}
 	if idx >= 0 && idx < len(cfg.FeishuBots.FeishuBots) {
 		cfg.FeishuBots.FeishuBots[idx] = bot
 	} else {
@@ -109,3 +149,11 @@ func (a *App) handleBotDelete(w http.ResponseWriter, r *http.Request) {
 	a.notifySaved()
 	a.redirectFlash(w, r, "/bots", a.message(r, "flash.botDeleted"), "ok")
 }
+
+// botRow builds a BotRow for list display and editing.
+func botRow(i int, b config.FeishuBot) BotRow {
+	return BotRow{
+		Index: i, Alias: b.Alias, URL: b.URL, Template: b.Template, Type: b.Type, ReceiveID: b.ReceiveID, Secret: b.Secret, To: strings.Join(b.To, ", "),
+		AppToken: b.AppToken, TableID: b.TableID, UniqueField: b.UniqueField,
+	}
+}
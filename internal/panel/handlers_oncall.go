@@ -0,0 +1,55 @@
+package panel
+
+import (
+	"net/http"
+	"time"
+)
+
+// onCallUpcoming is the number of shifts listed per schedule, current one
+// included.
+const onCallUpcoming = 6
+
+// OnCallRow is one oncall.yaml schedule on the on-call page.
+type OnCallRow struct {
+	Name     string
+	Rotation string
+	Timezone string
+	Members  []string
+	Shifts   []OnCallShiftRow // current first
+}
+
+// OnCallShiftRow is one shift of a schedule.
+type OnCallShiftRow struct {
+	User     string
+	Mapped   bool // the login has a users.yaml mapping, so it can be mentioned
+	Start    string
+	End      string
+	Override bool
+	Current  bool
+}
+
+// handleOnCall shows who is on call now and next for every schedule.
+func (a *App) handleOnCall(w http.ResponseWriter, r *http.Request) {
+	data := a.baseData(r)
+	cfg, err := a.loadConfig()
+	if err != nil {
+		data.Flash, data.FlashKind = a.message(r, "flash.configLoadFailed"), "err"
+		a.renderPage(w, "oncall", data)
+		return
+	}
+	now := time.Now()
+	for i := range cfg.OnCall.Schedules {
+		s := &cfg.OnCall.Schedules[i]
+		row := OnCallRow{Name: s.Name, Rotation: s.Rotation, Timezone: s.Location().String(), Members: s.Members}
+		loc := s.Location()
+		for _, shift := range s.Shifts(now, onCallUpcoming) {
+			_, mapped := cfg.LookupUser(shift.User)
+			row.Shifts = append(row.Shifts, OnCallShiftRow{
+				User: shift.User, Mapped: mapped, Override: shift.Override, Current: len(row.Shifts) == 0,
+				Start: shift.Start.In(loc).Format("01/02 Mon 15:04"), End: shift.End.In(loc).Format("01/02 Mon 15:04"),
+			})
+		}
+		data.OnCall = append(data.OnCall, row)
+	}
+	a.renderPage(w, "oncall", data)
+}
@@ -71,7 +71,13 @@ func (a *App) handleRepoSave(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rp := config.RepoPattern{Pattern: pattern, Events: events, NotifyTo: notifyTo, Secret: secret}
+	// Start from the existing rule so fields the form doesn't edit (e.g.
+	// label_routes, owners) survive a save.
+	var rp config.RepoPattern
+	if idx >= 0 && idx < len(cfg.Repos.Repos) {
+		rp = cfg.Repos.Repos[idx]
+	}
+	rp.Pattern, rp.Events, rp.NotifyTo, rp.Secret = pattern, events, notifyTo, secret
 	if idx >= 0 && idx < len(cfg.Repos.Repos) {
 		cfg.Repos.Repos[idx] = rp
 	} else {
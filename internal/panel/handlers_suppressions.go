@@ -0,0 +1,169 @@
+package panel
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hnrobert/feishu-github-tracker/internal/config"
+	"github.com/hnrobert/feishu-github-tracker/internal/store"
+)
+
+// SuppressionRow is one active suppression on the suppressions page.
+type SuppressionRow struct {
+	ID      string
+	Repo    string
+	Event   string
+	Target  string
+	Reason  string
+	Until   string
+	Remains string
+}
+
+// SuppressedRow is one recorded suppressed delivery.
+type SuppressedRow struct {
+	Time        string
+	Event       string
+	Repo        string
+	Target      string
+	Suppression string
+}
+
+// MaintenanceView is the maintenance mode state shown on the suppressions page.
+type MaintenanceView struct {
+	Active bool
+	Since  string
+	Until  string // empty when it lasts until turned off
+	Reason string
+	Queued int
+}
+
+// handleSuppressions lists the active suppressions, maintenance mode and the
+// recently suppressed deliveries.
+func (a *App) handleSuppressions(w http.ResponseWriter, r *http.Request) {
+	data := a.baseData(r)
+	if a.suppr == nil {
+		a.renderPage(w, "suppressions", data)
+		return
+	}
+	data.SuppressionOn = true
+	now := time.Now()
+	for _, s := range a.suppr.Active(now) {
+		data.Suppressions = append(data.Suppressions, SuppressionRow{
+			ID: s.ID, Repo: s.Repo, Event: s.Event, Target: s.Target, Reason: s.Reason,
+			Until: s.Until.Local().Format("01/02 15:04"), Remains: formatRemaining(s.Until.Sub(now)),
+		})
+	}
+	for _, d := range a.suppr.Log() {
+		data.Suppressed = append(data.Suppressed, SuppressedRow{
+			Time: d.At.Local().Format("01/02 15:04:05"), Event: d.Event, Repo: d.Repo, Target: d.Target, Suppression: d.Suppression,
+		})
+	}
+	m := a.suppr.Maintenance()
+	data.Maintenance = MaintenanceView{Active: m.Active(now), Reason: m.Reason, Queued: a.suppr.Queued()}
+	if data.Maintenance.Active {
+		data.Maintenance.Since = m.Since.Local().Format("01/02 15:04")
+		if !m.Until.IsZero() {
+			data.Maintenance.Until = m.Until.Local().Format("01/02 15:04")
+		}
+	}
+	if cfg, err := a.loadConfig(); err == nil {
+		for _, b := range cfg.FeishuBots.FeishuBots {
+			data.BotAliases = append(data.BotAliases, b.Alias)
+		}
+	}
+	a.renderPage(w, "suppressions", data)
+}
+
+// handleSuppressionAdd adds a suppression lasting the submitted duration.
+func (a *App) handleSuppressionAdd(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || a.suppr == nil {
+		a.redirectFlash(w, r, "/suppressions", a.message(r, "flash.invalidForm"), "err")
+		return
+	}
+	sup := store.Suppression{
+		Repo:   strings.TrimSpace(r.FormValue("repo")),
+		Event:  strings.TrimSpace(r.FormValue("event")),
+		Target: strings.TrimSpace(r.FormValue("target")),
+		Reason: strings.TrimSpace(r.FormValue("reason")),
+	}
+	if sup.Repo == "" && sup.Event == "" && sup.Target == "" {
+		a.redirectFlash(w, r, "/suppressions", a.message(r, "flash.suppressionFieldsRequired"), "err")
+		return
+	}
+	d, err := config.ParseDuration(strings.TrimSpace(r.FormValue("duration")))
+	if err != nil || d == 0 {
+		a.redirectFlash(w, r, "/suppressions", a.message(r, "flash.invalidDuration"), "err")
+		return
+	}
+	sup.Until = time.Now().Add(d)
+	if _, err := a.suppr.Add(sup); err != nil {
+		a.redirectFlash(w, r, "/suppressions", a.message(r, "flash.saveFailed", err), "err")
+		return
+	}
+	a.redirectFlash(w, r, "/suppressions", a.message(r, "flash.suppressionAdded"), "ok")
+}
+
+// handleSuppressionDelete lifts a suppression before it expires.
+func (a *App) handleSuppressionDelete(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || a.suppr == nil {
+		a.redirectFlash(w, r, "/suppressions", a.message(r, "flash.invalidForm"), "err")
+		return
+	}
+	ok, err := a.suppr.Remove(r.FormValue("id"))
+	switch {
+	case err != nil:
+		a.redirectFlash(w, r, "/suppressions", a.message(r, "flash.saveFailed", err), "err")
+	case !ok:
+		a.redirectFlash(w, r, "/suppressions", a.message(r, "flash.suppressionNotFound"), "err")
+	default:
+		a.redirectFlash(w, r, "/suppressions", a.message(r, "flash.suppressionDeleted"), "ok")
+	}
+}
+
+// handleMaintenance turns maintenance mode on (optionally for a duration) or
+// off. Turning it off sends the queued deliveries on the next flush.
+func (a *App) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || a.suppr == nil {
+		a.redirectFlash(w, r, "/suppressions", a.message(r, "flash.invalidForm"), "err")
+		return
+	}
+	on := r.FormValue("on") == "true"
+	var until time.Time
+	if on {
+		d, err := config.ParseDuration(strings.TrimSpace(r.FormValue("duration")))
+		if err != nil {
+			a.redirectFlash(w, r, "/suppressions", a.message(r, "flash.invalidDuration"), "err")
+			return
+		}
+		if d > 0 {
+			until = time.Now().Add(d)
+		}
+	}
+	if err := a.suppr.SetMaintenance(on, until, strings.TrimSpace(r.FormValue("reason"))); err != nil {
+		a.redirectFlash(w, r, "/suppressions", a.message(r, "flash.saveFailed", err), "err")
+		return
+	}
+	key := "flash.maintenanceOff"
+	if on {
+		key = "flash.maintenanceOn"
+	}
+	a.redirectFlash(w, r, "/suppressions", a.message(r, key), "ok")
+}
+
+// formatRemaining renders the time left on a suppression as "2h 5m" or "45m".
+func formatRemaining(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d < time.Minute {
+		return "<1m"
+	}
+	if d >= time.Hour {
+		h, m := int(d/time.Hour), int(d%time.Hour/time.Minute)
+		if h >= 24 {
+			return fmt.Sprintf("%dd %dh", h/24, h%24)
+		}
+		return fmt.Sprintf("%dh %dm", h, m)
+	}
+	return fmt.Sprintf("%dm", int(d/time.Minute))
+}
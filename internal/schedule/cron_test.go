@@ -0,0 +1,58 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	// Wednesday 2026-01-07 10:30 UTC
+	from := time.Date(2026, 1, 7, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{"*/15 * * * *", from, time.Date(2026, 1, 7, 10, 45, 0, 0, time.UTC)},
+		{"0 9 * * *", from, time.Date(2026, 1, 8, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 1", from, time.Date(2026, 1, 12, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", from, time.Date(2026, 1, 8, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", from, time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", from, time.Date(2026, 1, 11, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", from, time.Date(2026, 1, 11, 0, 0, 0, 0, time.UTC)},
+		// day-of-month OR day-of-week when both are restricted
+		{"0 0 15 * 5", from, time.Date(2026, 1, 9, 0, 0, 0, 0, time.UTC)},
+		// evaluated in the location of the time passed in
+		{"0 9 * * *", from.In(shanghai), time.Date(2026, 1, 8, 9, 0, 0, 0, shanghai)},
+		{"0 0 31 2 *", from, time.Time{}},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.spec)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", tt.spec, err)
+		}
+		if got := s.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("Parse(%q).Next() = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestPrev(t *testing.T) {
+	s, err := Parse("0 9 * * 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2026, 1, 12, 9, 0, 0, 0, time.UTC)
+	if got, want := s.Prev(at), at.AddDate(0, 0, -7); !got.Equal(want) {
+		t.Errorf("Prev() = %v, want %v", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* * * * 8", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) expected an error", spec)
+		}
+	}
+}
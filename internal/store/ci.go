@@ -0,0 +1,105 @@
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+// CI transitions of a workflow on a branch.
+const (
+	TransitionBroken       = "broken"        // a failure after a success (or the first failure seen)
+	TransitionFixed        = "fixed"         // a success after a failure
+	TransitionStillFailing = "still_failing" // another failure
+)
+
+// ciStateExpiry is how long the state of a workflow/branch that stopped
+// running (e.g. a deleted branch) is kept.
+const ciStateExpiry = 90 * 24 * time.Hour
+
+// CIState is the last known outcome of a workflow on a branch.
+type CIState struct {
+	Failing bool `json:"failing"`
+	// Failures counts the consecutive failed runs, including the one that
+	// broke the build; it is 0 while passing.
+	Failures int   `json:"failures,omitempty"`
+	RunID    int64 `json:"run_id"`
+	Attempt  int   `json:"attempt,omitempty"`
+	// Transition is what the last run changed (a Transition* constant, or
+	// empty for a run that kept it passing).
+	Transition string    `json:"transition,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// CIStates is the persisted last outcome of each workflow, keyed by
+// "owner/repo|workflow|branch". It is safe for concurrent use; every change
+// is written through to disk.
+type CIStates struct {
+	path   string
+	mu     sync.Mutex
+	states map[string]*CIState
+	now    func() time.Time
+}
+
+// OpenCIStates loads the workflow states from path (a missing file starts
+// with none).
+func OpenCIStates(path string) (*CIStates, error) {
+	c := &CIStates{path: path, states: map[string]*CIState{}, now: time.Now}
+	if err := LoadJSON(path, &c.states); err != nil {
+		return nil, err
+	}
+	if c.states == nil {
+		c.states = map[string]*CIState{}
+	}
+	return c, nil
+}
+
+// Record applies the outcome of run attempt of runID (failing or not) to the
+// workflow under key and returns the new state. A run older than the last
+// one recorded, or a redelivery of it, leaves the state untouched and is
+// returned with no transition.
+func (c *CIStates) Record(key string, runID int64, attempt int, failing bool) (CIState, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	st, ok := c.states[key]
+	if ok && (runID < st.RunID || runID == st.RunID && attempt <= st.Attempt) {
+		cp := *st
+		cp.Transition = ""
+		return cp, nil
+	}
+	if !ok {
+		st = &CIState{}
+		c.states[key] = st
+	}
+	switch {
+	case failing && st.Failing:
+		st.Transition = TransitionStillFailing
+		st.Failures++
+	case failing:
+		st.Transition = TransitionBroken
+		st.Failures = 1
+	case st.Failing:
+		st.Transition = TransitionFixed
+		st.Failures = 0
+	default:
+		st.Transition = ""
+	}
+	st.Failing, st.RunID, st.Attempt, st.UpdatedAt = failing, runID, attempt, now
+	for k, s := range c.states {
+		if now.Sub(s.UpdatedAt) > ciStateExpiry {
+			delete(c.states, k)
+		}
+	}
+	return *st, SaveJSON(c.path, c.states)
+}
+
+// Get returns a copy of the state stored under key.
+func (c *CIStates) Get(key string) (CIState, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, ok := c.states[key]
+	if !ok {
+		return CIState{}, false
+	}
+	return *st, true
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCIStatesTransitions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ci.json")
+	states, err := OpenCIStates(path)
+	if err != nil {
+		t.Fatalf("OpenCIStates() error = %v", err)
+	}
+	const key = "org/a|CI|main"
+	steps := []struct {
+		run        int64
+		attempt    int
+		failing    bool
+		transition string
+		failures   int
+	}{
+		{1, 1, false, "", 0},
+		{2, 1, true, TransitionBroken, 1},
+		{2, 1, true, "", 1}, // redelivery
+		{3, 1, true, TransitionStillFailing, 2},
+		{1, 2, false, "", 2}, // re-run of an older run
+		{3, 2, false, TransitionFixed, 0},
+		{4, 1, false, "", 0},
+	}
+	for i, s := range steps {
+		st, err := states.Record(key, s.run, s.attempt, s.failing)
+		if err != nil {
+			t.Fatalf("step %d: Record() error = %v", i, err)
+		}
+		if st.Transition != s.transition || st.Failures != s.failures {
+			t.Fatalf("step %d: Record() = %+v, want transition %q failures %d", i, st, s.transition, s.failures)
+		}
+	}
+
+	reopened, err := OpenCIStates(path)
+	if err != nil {
+		t.Fatalf("reopen error = %v", err)
+	}
+	if st, ok := reopened.Get(key); !ok || st.Failing || st.RunID != 4 {
+		t.Fatalf("Get() = %+v, %v", st, ok)
+	}
+}
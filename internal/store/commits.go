@@ -0,0 +1,153 @@
+package store
+
+import (
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CommitJob is one check run or workflow job of a commit.
+type CommitJob struct {
+	Name        string    `json:"name"`
+	Status      string    `json:"status,omitempty"`     // queued, in_progress, completed
+	Conclusion  string    `json:"conclusion,omitempty"` // success, failure, ... once completed
+	URL         string    `json:"url,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// Completed reports whether the job has finished.
+func (j *CommitJob) Completed() bool {
+	return j.Status == "completed"
+}
+
+// CommitSuite is one check suite of a commit.
+type CommitSuite struct {
+	Name       string `json:"name,omitempty"` // the app running it
+	Status     string `json:"status,omitempty"`
+	Conclusion string `json:"conclusion,omitempty"`
+}
+
+// CommitStatus collects the checks of one commit for one rule until they
+// have all finished (or the commit times out).
+type CommitStatus struct {
+	Rule    string                `json:"rule"`
+	Repo    string                `json:"repo"`
+	RepoURL string                `json:"repo_url,omitempty"`
+	SHA     string                `json:"sha"`
+	Branch  string                `json:"branch,omitempty"`
+	Targets []string              `json:"targets"`
+	Jobs    map[string]*CommitJob `json:"jobs"`
+	// Suites are the check suites seen for the commit by id; a completed
+	// suite means its checks are all reported.
+	Suites  map[string]*CommitSuite `json:"suites,omitempty"`
+	Start   time.Time               `json:"start"`
+	Updated time.Time               `json:"updated"`
+	Due     time.Time               `json:"due"` // timeout
+}
+
+// Finished reports whether every job and suite seen has completed, the
+// commit has at least one of them, and either a suite was seen completing
+// or nothing happened for settle (so jobs that have not started yet get a
+// chance to show up). Rules collecting only check suites finish with the
+// last suite.
+func (s *CommitStatus) Finished(now time.Time, settle time.Duration) bool {
+	if len(s.Jobs) == 0 && len(s.Suites) == 0 {
+		return false
+	}
+	for _, job := range s.Jobs {
+		if !job.Completed() {
+			return false
+		}
+	}
+	for _, suite := range s.Suites {
+		if suite.Status != "completed" {
+			return false
+		}
+	}
+	return len(s.Suites) > 0 || now.Sub(s.Updated) >= settle
+}
+
+// SortedJobs returns the jobs ordered by name. A commit without jobs (the
+// rule collects only check suites) lists its suites instead.
+func (s *CommitStatus) SortedJobs() []CommitJob {
+	jobs := make([]CommitJob, 0, len(s.Jobs))
+	for _, job := range s.Jobs {
+		jobs = append(jobs, *job)
+	}
+	if len(jobs) == 0 {
+		for _, suite := range s.Suites {
+			jobs = append(jobs, CommitJob{Name: suite.Name, Status: suite.Status, Conclusion: suite.Conclusion})
+		}
+	}
+	sort.SliceStable(jobs, func(i, j int) bool { return jobs[i].Name < jobs[j].Name })
+	return jobs
+}
+
+// CommitStatuses is the persisted set of commits whose checks are being
+// collected, keyed by "rule|owner/repo@sha". It is safe for concurrent use;
+// every change is written through to disk.
+type CommitStatuses struct {
+	path    string
+	mu      sync.Mutex
+	commits map[string]*CommitStatus
+	now     func() time.Time
+}
+
+// OpenCommitStatuses loads the collected commits from path (a missing file
+// starts with none).
+func OpenCommitStatuses(path string) (*CommitStatuses, error) {
+	c := &CommitStatuses{path: path, commits: map[string]*CommitStatus{}, now: time.Now}
+	if err := LoadJSON(path, &c.commits); err != nil {
+		return nil, err
+	}
+	if c.commits == nil {
+		c.commits = map[string]*CommitStatus{}
+	}
+	return c, nil
+}
+
+// Update applies fn to the commit under key, creating it (due timeout
+// later) if it is not collected yet, adds targets and saves the result.
+func (c *CommitStatuses) Update(key string, timeout time.Duration, targets []string, fn func(s *CommitStatus)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	s, ok := c.commits[key]
+	if !ok {
+		s = &CommitStatus{Jobs: map[string]*CommitJob{}, Start: now, Due: now.Add(timeout)}
+		c.commits[key] = s
+	}
+	for _, t := range targets {
+		if !slices.Contains(s.Targets, t) {
+			s.Targets = append(s.Targets, t)
+		}
+	}
+	fn(s)
+	s.Updated = now
+	return SaveJSON(c.path, c.commits)
+}
+
+// TakeDone removes and returns the commits that finished (see Finished) or
+// timed out at now, ordered by key.
+func (c *CommitStatuses) TakeDone(now time.Time, settle time.Duration) ([]CommitStatus, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var keys []string
+	for key, s := range c.commits {
+		if !now.Before(s.Due) || s.Finished(now, settle) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	sort.Strings(keys)
+	done := make([]CommitStatus, 0, len(keys))
+	for _, key := range keys {
+		done = append(done, *c.commits[key])
+		delete(c.commits, key)
+	}
+	return done, SaveJSON(c.path, c.commits)
+}
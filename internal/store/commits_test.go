@@ -0,0 +1,80 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCommitStatusesTakeDone(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "commits.json")
+	commits, err := OpenCommitStatuses(path)
+	if err != nil {
+		t.Fatalf("OpenCommitStatuses() error = %v", err)
+	}
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	commits.now = func() time.Time { return now }
+	job := func(key, id, status string) {
+		t.Helper()
+		if err := commits.Update(key, 30*time.Minute, []string{"team"}, func(s *CommitStatus) {
+			s.SHA = key
+			s.Jobs[id] = &CommitJob{Name: id, Status: status}
+		}); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+	}
+	job("a", "build", "in_progress")
+	job("a", "test", "completed")
+	job("b", "lint", "in_progress")
+	if err := commits.Update("c", 30*time.Minute, nil, func(s *CommitStatus) {
+		s.Jobs["x"] = &CommitJob{Name: "x", Status: "completed"}
+		s.Suites = map[string]*CommitSuite{"1": {Status: "completed"}}
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A completed suite finishes its commit right away; others wait.
+	done, err := commits.TakeDone(now, time.Minute)
+	if err != nil || len(done) != 1 || len(done[0].Jobs) != 1 {
+		t.Fatalf("TakeDone() = %+v, %v", done, err)
+	}
+
+	// Finished jobs settle for a minute; the rest time out.
+	now = now.Add(10 * time.Minute)
+	job("a", "build", "completed")
+	reopened, err := OpenCommitStatuses(path)
+	if err != nil {
+		t.Fatalf("reopen error = %v", err)
+	}
+	if done, _ := reopened.TakeDone(now.Add(30*time.Second), time.Minute); len(done) != 0 {
+		t.Fatalf("TakeDone() before settling = %+v", done)
+	}
+	done, _ = reopened.TakeDone(now.Add(time.Minute), time.Minute)
+	if len(done) != 1 || done[0].SHA != "a" || done[0].SortedJobs()[0].Name != "build" {
+		t.Fatalf("TakeDone() after settling = %+v", done)
+	}
+	done, _ = reopened.TakeDone(start.Add(30*time.Minute), time.Minute)
+	if len(done) != 1 || done[0].SHA != "b" {
+		t.Fatalf("TakeDone() at timeout = %+v", done)
+	}
+}
+
+func TestCommitStatusFinishedSuitesOnly(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := CommitStatus{Jobs: map[string]*CommitJob{}, Updated: now}
+	if s.Finished(now.Add(time.Hour), time.Minute) {
+		t.Fatal("empty commit finished")
+	}
+	s.Suites = map[string]*CommitSuite{"1": {Name: "GitHub Actions", Status: "in_progress"}}
+	if s.Finished(now, time.Minute) {
+		t.Fatal("finished with a running suite")
+	}
+	s.Suites["1"] = &CommitSuite{Name: "GitHub Actions", Status: "completed", Conclusion: "failure"}
+	if !s.Finished(now, time.Minute) {
+		t.Fatal("not finished once the suite completed")
+	}
+	if jobs := s.SortedJobs(); len(jobs) != 1 || jobs[0].Name != "GitHub Actions" || jobs[0].Conclusion != "failure" {
+		t.Fatalf("SortedJobs() = %+v", jobs)
+	}
+}
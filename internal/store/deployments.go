@@ -0,0 +1,184 @@
+package store
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Deployment states recorded in a lifecycle, besides the GitHub deployment
+// status states (queued, in_progress, success, failure, error, ...).
+const (
+	DeploymentRequested = "requested" // the deployment was created
+	DeploymentWaiting   = "waiting"   // an approval or protection rule is pending
+	DeploymentRejected  = "rejected"  // a reviewer rejected it
+)
+
+// Expiry of tracked deployments: unfinished ones are dropped after a month
+// without updates, finished ones (kept to ignore redeliveries) after a week.
+const (
+	deploymentExpiry         = 30 * 24 * time.Hour
+	finishedDeploymentExpiry = 7 * 24 * time.Hour
+)
+
+// Deployment is the lifecycle of one deployment, from its creation through
+// approval to its final status.
+type Deployment struct {
+	ID          int64  `json:"id,omitempty"`
+	Repo        string `json:"repo"`
+	RepoURL     string `json:"repo_url,omitempty"`
+	Environment string `json:"environment"`
+	Ref         string `json:"ref,omitempty"`
+	SHA         string `json:"sha,omitempty"`
+	Creator     string `json:"creator,omitempty"`
+	URL         string `json:"url,omitempty"` // log or target URL of the latest status
+	State       string `json:"state"`
+	// Approver and ApprovalComment come from the deployment review that
+	// approved or rejected it.
+	Approver        string    `json:"approver,omitempty"`
+	ApprovalComment string    `json:"approval_comment,omitempty"`
+	Requested       time.Time `json:"requested"`
+	Waiting         time.Time `json:"waiting"`  // approval requested
+	Reviewed        time.Time `json:"reviewed"` // approved or rejected
+	Started         time.Time `json:"started"`  // in progress
+	Finished        time.Time `json:"finished"`
+	Updated         time.Time `json:"updated"`
+}
+
+// Done reports whether the deployment reached a final state.
+func (d *Deployment) Done() bool {
+	return !d.Finished.IsZero()
+}
+
+// IsFinalDeploymentState reports whether a deployment ending in state is
+// over.
+func IsFinalDeploymentState(state string) bool {
+	switch state {
+	case "success", "failure", "error", DeploymentRejected:
+		return true
+	}
+	return false
+}
+
+type deploymentState struct {
+	Deployments map[string]*Deployment `json:"deployments"`
+	// Runs maps "repo|environment|run id" to the key of the deployment of
+	// that workflow run, for events that carry no deployment id.
+	Runs map[string]string `json:"runs,omitempty"`
+	// LastSuccess is the latest successful deployment per
+	// "repo|environment".
+	LastSuccess map[string]Deployment `json:"last_success,omitempty"`
+}
+
+// Deployments is the persisted set of deployment lifecycles, keyed by
+// "repo#id". It is safe for concurrent use; every change is written through
+// to disk.
+type Deployments struct {
+	path  string
+	mu    sync.Mutex
+	state deploymentState
+	now   func() time.Time
+}
+
+// OpenDeployments loads the deployment lifecycles from path (a missing file
+// starts with none).
+func OpenDeployments(path string) (*Deployments, error) {
+	d := &Deployments{path: path, now: time.Now}
+	if err := LoadJSON(path, &d.state); err != nil {
+		return nil, err
+	}
+	if d.state.Deployments == nil {
+		d.state.Deployments = map[string]*Deployment{}
+	}
+	if d.state.Runs == nil {
+		d.state.Runs = map[string]string{}
+	}
+	if d.state.LastSuccess == nil {
+		d.state.LastSuccess = map[string]Deployment{}
+	}
+	return d, nil
+}
+
+// Update applies fn to the deployment of repo with id (0 when the event has
+// none) and run ("environment|run id" of the workflow run deploying it, or
+// empty), creating it if needed, and saves the result. It returns the
+// deployment, whether this update finished it, and the previous successful
+// deployment to the same environment (zero if none). Updates to a finished
+// deployment are ignored.
+func (d *Deployments) Update(repo string, id int64, run string, fn func(dep *Deployment)) (Deployment, bool, Deployment, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+
+	var key, runKey string
+	if run != "" {
+		runKey = repo + "|" + run
+	}
+	if id != 0 {
+		key = repo + "#" + strconv.FormatInt(id, 10)
+	}
+	dep, ok := d.state.Deployments[key]
+	if !ok && runKey != "" {
+		if k, found := d.state.Runs[runKey]; found {
+			if dep, ok = d.state.Deployments[k]; ok && key != "" && k != key {
+				// the deployment id is known now: re-key the entry
+				delete(d.state.Deployments, k)
+				d.state.Deployments[key] = dep
+			}
+			if key == "" {
+				key = k
+			}
+		}
+	}
+	if key == "" {
+		if runKey == "" {
+			return Deployment{}, false, Deployment{}, nil
+		}
+		key = runKey
+	}
+	if !ok {
+		dep = &Deployment{Repo: repo, Requested: now}
+		d.state.Deployments[key] = dep
+	}
+	if runKey != "" {
+		d.state.Runs[runKey] = key
+	}
+	if dep.Done() {
+		return *dep, false, Deployment{}, nil
+	}
+
+	if id != 0 {
+		dep.ID = id
+	}
+	fn(dep)
+	dep.Updated = now
+	finished := false
+	var previous Deployment
+	if IsFinalDeploymentState(dep.State) {
+		finished = true
+		if dep.Finished.IsZero() {
+			dep.Finished = now
+		}
+		envKey := repo + "|" + dep.Environment
+		previous = d.state.LastSuccess[envKey]
+		if dep.State == "success" {
+			d.state.LastSuccess[envKey] = *dep
+		}
+	}
+	d.prune(now)
+	return *dep, finished, previous, SaveJSON(d.path, d.state)
+}
+
+// prune drops expired deployments and run links.
+func (d *Deployments) prune(now time.Time) {
+	for k, dep := range d.state.Deployments {
+		if dep.Done() && now.Sub(dep.Updated) > finishedDeploymentExpiry || now.Sub(dep.Updated) > deploymentExpiry {
+			delete(d.state.Deployments, k)
+		}
+	}
+	for run, k := range d.state.Runs {
+		if _, ok := d.state.Deployments[k]; !ok {
+			delete(d.state.Runs, run)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDeploymentsLifecycle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deployments.json")
+	deps, err := OpenDeployments(path)
+	if err != nil {
+		t.Fatalf("OpenDeployments() error = %v", err)
+	}
+	now := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	deps.now = func() time.Time { return now }
+	set := func(state string) func(d *Deployment) {
+		return func(d *Deployment) {
+			d.Environment = "prod"
+			d.State = state
+		}
+	}
+
+	// A review seen before the deployment id is re-keyed once the id arrives.
+	if _, _, _, err := deps.Update("org/a", 0, "prod|9", set(DeploymentWaiting)); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Minute)
+	d, finished, _, err := deps.Update("org/a", 7, "prod|9", func(d *Deployment) { d.Ref = "v1" })
+	if err != nil || finished || d.State != DeploymentWaiting || d.ID != 7 || d.Ref != "v1" {
+		t.Fatalf("Update() = %+v, %v, %v", d, finished, err)
+	}
+	now = now.Add(time.Minute)
+	d, finished, previous, err := deps.Update("org/a", 7, "", set("success"))
+	if err != nil || !finished || d.Finished != now || !previous.Finished.IsZero() {
+		t.Fatalf("Update() = %+v, %v, previous %+v, %v", d, finished, previous, err)
+	}
+	if d.Requested != now.Add(-2*time.Minute) {
+		t.Fatalf("Requested = %v", d.Requested)
+	}
+	if _, finished, _, _ := deps.Update("org/a", 7, "", set("failure")); finished {
+		t.Fatal("redelivery finished the deployment again")
+	}
+
+	reopened, err := OpenDeployments(path)
+	if err != nil {
+		t.Fatalf("reopen error = %v", err)
+	}
+	reopened.now = func() time.Time { return now }
+	_, finished, previous, err = reopened.Update("org/a", 8, "", set("failure"))
+	if err != nil || !finished || previous.ID != 7 || previous.Ref != "v1" {
+		t.Fatalf("previous = %+v, finished %v, %v", previous, finished, err)
+	}
+
+	// Finished deployments are forgotten after a week.
+	now = now.Add(8 * 24 * time.Hour)
+	if _, _, _, err := reopened.Update("org/b", 1, "", set("queued")); err != nil {
+		t.Fatal(err)
+	}
+	if len(reopened.state.Deployments) != 1 || len(reopened.state.Runs) != 0 {
+		t.Fatalf("after prune: %+v, runs %+v", reopened.state.Deployments, reopened.state.Runs)
+	}
+}
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MaxDigestItems caps the items listed in one digest; further events are
+// only counted.
+const MaxDigestItems = 50
+
+// DigestItem is one buffered event of a digest.
+type DigestItem struct {
+	Event   string    `json:"event"`
+	Action  string    `json:"action,omitempty"`
+	Repo    string    `json:"repo,omitempty"`
+	RepoURL string    `json:"repo_url,omitempty"`
+	Sender  string    `json:"sender,omitempty"`
+	Title   string    `json:"title,omitempty"`
+	URL     string    `json:"url,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// DigestBuffer holds the events buffered for one rule until Due.
+type DigestBuffer struct {
+	Rule    string         `json:"rule"`
+	Targets []string       `json:"targets"`
+	Items   []DigestItem   `json:"items"`
+	Counts  map[string]int `json:"counts"`            // event type -> events buffered
+	Dropped int            `json:"dropped,omitempty"` // events beyond MaxDigestItems
+	Start   time.Time      `json:"start"`
+	Due     time.Time      `json:"due"`
+}
+
+// Digests is the persisted set of open digest buffers, keyed by rule
+// pattern. It is safe for concurrent use; every change is written through to
+// disk so buffered events survive a restart.
+type Digests struct {
+	path    string
+	mu      sync.Mutex
+	buffers map[string]*DigestBuffer
+	now     func() time.Time
+}
+
+// OpenDigests loads the digest buffers from path (a missing file starts with
+// none).
+func OpenDigests(path string) (*Digests, error) {
+	d := &Digests{path: path, buffers: map[string]*DigestBuffer{}, now: time.Now}
+	if err := LoadJSON(path, &d.buffers); err != nil {
+		return nil, err
+	}
+	if d.buffers == nil {
+		d.buffers = map[string]*DigestBuffer{}
+	}
+	return d, nil
+}
+
+// Add buffers item for rule, to be sent to targets. The first item of a
+// buffer starts its window; the buffer is due window later.
+func (d *Digests) Add(rule string, window time.Duration, targets []string, item DigestItem) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	b, ok := d.buffers[rule]
+	if !ok {
+		now := d.now()
+		b = &DigestBuffer{Rule: rule, Counts: map[string]int{}, Start: now, Due: now.Add(window)}
+		d.buffers[rule] = b
+	}
+	for _, t := range targets {
+		if !slices.Contains(b.Targets, t) {
+			b.Targets = append(b.Targets, t)
+		}
+	}
+	b.Append(item)
+	return SaveJSON(d.path, d.buffers)
+}
+
+// Append counts item and lists it, unless MaxDigestItems are listed already.
+func (b *DigestBuffer) Append(item DigestItem) {
+	if b.Counts == nil {
+		b.Counts = map[string]int{}
+	}
+	b.Counts[item.Event]++
+	if len(b.Items) < MaxDigestItems {
+		b.Items = append(b.Items, item)
+	} else {
+		b.Dropped++
+	}
+}
+
+// TakeDue removes and returns the buffers due at now, ordered by rule.
+func (d *Digests) TakeDue(now time.Time) ([]DigestBuffer, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var due []DigestBuffer
+	for rule, b := range d.buffers {
+		if !now.Before(b.Due) {
+			due = append(due, *b)
+			delete(d.buffers, rule)
+		}
+	}
+	if len(due) == 0 {
+		return nil, nil
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].Rule < due[j].Rule })
+	return due, SaveJSON(d.path, d.buffers)
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDigestsBufferAndTakeDue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "digests.json")
+	digests, err := OpenDigests(path)
+	if err != nil {
+		t.Fatalf("OpenDigests() error = %v", err)
+	}
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	digests.now = func() time.Time { return start }
+	for i := 0; i < MaxDigestItems+2; i++ {
+		if err := digests.Add("org/*", 15*time.Minute, []string{"dev"}, DigestItem{Event: "star", Repo: "org/a"}); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+	if err := digests.Add("org/*", 15*time.Minute, []string{"dev", "ops"}, DigestItem{Event: "push", Repo: "org/b"}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	// Buffers survive a restart.
+	reopened, err := OpenDigests(path)
+	if err != nil {
+		t.Fatalf("reopen error = %v", err)
+	}
+	if due, _ := reopened.TakeDue(start.Add(14 * time.Minute)); len(due) != 0 {
+		t.Fatalf("TakeDue() before the window = %v", due)
+	}
+	due, err := reopened.TakeDue(start.Add(15 * time.Minute))
+	if err != nil || len(due) != 1 {
+		t.Fatalf("TakeDue() = %v, %v", due, err)
+	}
+	b := due[0]
+	if len(b.Items) != MaxDigestItems || b.Dropped != 3 || b.Counts["star"] != MaxDigestItems+2 || b.Counts["push"] != 1 {
+		t.Fatalf("buffer items=%d dropped=%d counts=%v", len(b.Items), b.Dropped, b.Counts)
+	}
+	if len(b.Targets) != 2 {
+		t.Fatalf("targets = %v", b.Targets)
+	}
+	if due, _ := reopened.TakeDue(start.Add(time.Hour)); len(due) != 0 {
+		t.Fatalf("buffer not removed: %v", due)
+	}
+}
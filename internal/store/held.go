@@ -0,0 +1,95 @@
+package store
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// MaxHeld caps the deliveries held for quiet hours; the oldest are dropped
+// first.
+const MaxHeld = 1000
+
+// HeldDelivery is a delivery held back during quiet hours. Held deliveries
+// are sent as one summary per target, so only the summary line is kept.
+type HeldDelivery struct {
+	DigestItem
+	Targets []string `json:"targets"`
+}
+
+// Held is the persisted list of deliveries held back during their targets'
+// quiet hours. It is safe for concurrent use; every change is written
+// through to disk.
+type Held struct {
+	path  string
+	mu    sync.Mutex
+	queue []HeldDelivery // oldest first
+	now   func() time.Time
+}
+
+// OpenHeld loads the held deliveries from path (a missing file starts with
+// none).
+func OpenHeld(path string) (*Held, error) {
+	h := &Held{path: path, now: time.Now}
+	if err := LoadJSON(path, &h.queue); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Hold adds d. It reports whether an older delivery had to be dropped to
+// make room.
+func (h *Held) Hold(d HeldDelivery) (bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if d.At.IsZero() {
+		d.At = h.now()
+	}
+	h.queue = append(h.queue, d)
+	dropped := false
+	if over := len(h.queue) - MaxHeld; over > 0 {
+		h.queue = slices.Delete(h.queue, 0, over)
+		dropped = true
+	}
+	return dropped, SaveJSON(h.path, h.queue)
+}
+
+// Len returns the number of held deliveries.
+func (h *Held) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.queue)
+}
+
+// Release removes and returns, oldest first, the held deliveries narrowed to
+// the targets for which open reports true; targets still closed stay held.
+func (h *Held) Release(open func(d HeldDelivery, target string) bool) ([]HeldDelivery, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var released []HeldDelivery
+	kept := h.queue[:0]
+	for _, d := range h.queue {
+		var ready, waiting []string
+		for _, target := range d.Targets {
+			if open(d, target) {
+				ready = append(ready, target)
+			} else {
+				waiting = append(waiting, target)
+			}
+		}
+		if len(ready) > 0 {
+			r := d
+			r.Targets = ready
+			released = append(released, r)
+		}
+		if len(waiting) > 0 {
+			d.Targets = waiting
+			kept = append(kept, d)
+		}
+	}
+	h.queue = kept
+	if len(released) == 0 {
+		return nil, nil
+	}
+	return released, SaveJSON(h.path, h.queue)
+}
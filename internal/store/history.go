@@ -0,0 +1,183 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultHistoryRetention is how long recorded events are kept when no
+// retention is configured; it covers a monthly report.
+const DefaultHistoryRetention = 35 * 24 * time.Hour
+
+// History event kinds.
+const (
+	KindPROpened       = "pr_opened"
+	KindPRMerged       = "pr_merged"
+	KindPRClosed       = "pr_closed" // closed without merging
+	KindIssueOpened    = "issue_opened"
+	KindIssueClosed    = "issue_closed"
+	KindRelease        = "release"
+	KindWorkflowFailed = "workflow_failed"
+	KindPush           = "push"
+)
+
+// HistoryEvent is the compact record of one event kept for reports.
+type HistoryEvent struct {
+	Kind    string    `json:"kind"`
+	Repo    string    `json:"repo"`
+	Actor   string    `json:"actor,omitempty"` // the contributor credited: PR/issue author, pusher, publisher
+	Number  int       `json:"number,omitempty"`
+	Title   string    `json:"title,omitempty"`
+	URL     string    `json:"url,omitempty"`
+	Commits int       `json:"commits,omitempty"` // push only
+	Labels  []string  `json:"labels,omitempty"`  // pull requests only
+	Tag     string    `json:"tag,omitempty"`     // release only
+	At      time.Time `json:"at"`
+}
+
+// History is the persisted log of recent events that scheduled reports
+// aggregate. It is safe for concurrent use. The file holds one JSON event
+// per line: new events are appended, and the file is only rewritten once
+// the events dropped after the retention outnumber the live ones.
+type History struct {
+	path      string
+	mu        sync.Mutex
+	retention time.Duration
+	events    []HistoryEvent
+	stale     int // expired events still in the file
+	now       func() time.Time
+}
+
+// OpenHistory loads the event log from path (a missing file starts an empty
+// log). A zero retention uses DefaultHistoryRetention.
+func OpenHistory(path string, retention time.Duration) (*History, error) {
+	h := &History{path: path, now: time.Now}
+	h.SetRetention(retention)
+	if err := h.load(); err != nil {
+		return nil, err
+	}
+	if h.stale = h.prune(); h.stale > 0 {
+		if err := h.compact(); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// load reads the event lines of h.path. A last line cut short by a crash
+// is ignored.
+func (h *History) load() error {
+	data, err := os.ReadFile(h.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var ev HistoryEvent
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			if !bytes.HasSuffix(data, []byte("\n")) && bytes.HasSuffix(data, scanner.Bytes()) {
+				break
+			}
+			return fmt.Errorf("decode %s line %d: %w", filepath.Base(h.path), line, err)
+		}
+		h.events = append(h.events, ev)
+	}
+	return scanner.Err()
+}
+
+// SetRetention changes how long events are kept.
+func (h *History) SetRetention(retention time.Duration) {
+	if retention <= 0 {
+		retention = DefaultHistoryRetention
+	}
+	h.mu.Lock()
+	h.retention = retention
+	h.mu.Unlock()
+}
+
+// Record appends ev, stamping it with the current time if At is zero.
+func (h *History) Record(ev HistoryEvent) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if ev.At.IsZero() {
+		ev.At = h.now()
+	}
+	h.events = append(h.events, ev)
+	h.stale += h.prune()
+	if h.stale > len(h.events) {
+		return h.compact()
+	}
+	line, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("marshal json: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o755); err != nil {
+		return fmt.Errorf("create directory: %w", err)
+	}
+	f, err := os.OpenFile(h.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// compact rewrites the file with the live events only; h.mu must be held
+// (or h not yet shared).
+func (h *History) compact() error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, ev := range h.events {
+		if err := enc.Encode(ev); err != nil {
+			return fmt.Errorf("marshal json: %w", err)
+		}
+	}
+	if err := WriteFileAtomic(h.path, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	h.stale = 0
+	return nil
+}
+
+// Between returns the events recorded in [from, to).
+func (h *History) Between(from, to time.Time) []HistoryEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var out []HistoryEvent
+	for _, ev := range h.events {
+		if !ev.At.Before(from) && ev.At.Before(to) {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+// prune drops events older than the retention and returns how many it
+// dropped; h.mu must be held (or h not yet shared).
+func (h *History) prune() int {
+	cutoff := h.now().Add(-h.retention)
+	i := 0
+	for i < len(h.events) && h.events[i].At.Before(cutoff) {
+		i++
+	}
+	if i > 0 {
+		h.events = append([]HistoryEvent(nil), h.events[i:]...)
+	}
+	return i
+}
@@ -0,0 +1,104 @@
+package store
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistoryRecordAndPrune(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	history, err := OpenHistory(path, 7*24*time.Hour)
+	if err != nil {
+		t.Fatalf("OpenHistory() error = %v", err)
+	}
+	start := time.Now().Add(-3 * 24 * time.Hour).Truncate(time.Hour)
+	now := start
+	history.now = func() time.Time { return now }
+	for i := 0; i < 3; i++ {
+		if err := history.Record(HistoryEvent{Kind: KindPROpened, Repo: "org/a", Number: i + 1}); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+		now = now.Add(24 * time.Hour)
+	}
+
+	// Events survive a restart.
+	reopened, err := OpenHistory(path, 7*24*time.Hour)
+	if err != nil {
+		t.Fatalf("reopen error = %v", err)
+	}
+	if got := reopened.Between(start, start.Add(48*time.Hour)); len(got) != 2 || got[1].Number != 2 {
+		t.Fatalf("Between() = %v", got)
+	}
+
+	// Recording later drops the events older than the retention.
+	now = start.Add(7*24*time.Hour + time.Hour)
+	reopened.now = func() time.Time { return now }
+	if err := reopened.Record(HistoryEvent{Kind: KindPush, Repo: "org/a", Commits: 2}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	got := reopened.Between(start, now.Add(time.Second))
+	if len(got) != 3 || got[0].Number != 2 || got[2].Kind != KindPush {
+		t.Fatalf("after prune = %v", got)
+	}
+}
+
+func TestHistoryAppendsAndCompacts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	history, err := OpenHistory(path, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("OpenHistory() error = %v", err)
+	}
+	now := time.Now().Add(-40 * time.Hour)
+	history.now = func() time.Time { return now }
+	lines := func() int {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bytes.Count(data, []byte("\n"))
+	}
+	record := func(at time.Time) {
+		t.Helper()
+		now = at
+		if err := history.Record(HistoryEvent{Kind: KindPush, Repo: "org/a"}); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+	start := now
+	record(start)
+	record(start)
+	record(start.Add(12 * time.Hour))
+	record(start.Add(12 * time.Hour))
+	if n := lines(); n != 4 {
+		t.Fatalf("file has %d lines, want 4", n)
+	}
+
+	// Expired events stay in the file until they outnumber the live ones.
+	record(start.Add(25 * time.Hour))
+	if n := lines(); n != 5 {
+		t.Fatalf("file has %d lines, want 5", n)
+	}
+	record(start.Add(37 * time.Hour))
+	if n := lines(); n != 2 {
+		t.Fatalf("file has %d lines after compaction, want 2", n)
+	}
+
+	// A line cut short by a crash is ignored.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"kind":"pu`)
+	_ = f.Close()
+	reopened, err := OpenHistory(path, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("reopen error = %v", err)
+	}
+	if got := reopened.Between(start, now.Add(time.Hour)); len(got) != 2 {
+		t.Fatalf("Between() = %v", got)
+	}
+}
@@ -0,0 +1,130 @@
+package store
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// PullRequest is the review state of one open pull request, as seen in the
+// webhook stream.
+type PullRequest struct {
+	Repo      string    `json:"repo"`
+	RepoURL   string    `json:"repo_url,omitempty"`
+	Number    int       `json:"number"`
+	Title     string    `json:"title,omitempty"`
+	URL       string    `json:"url,omitempty"`
+	Author    string    `json:"author,omitempty"`
+	Draft     bool      `json:"draft,omitempty"`
+	Reviewers []string  `json:"reviewers,omitempty"` // pending requested reviewers (logins)
+	OpenedAt  time.Time `json:"opened_at"`
+	// WaitingSince is when the pull request started waiting for review; it
+	// is zero once it has been reviewed and nobody is requested any more.
+	WaitingSince time.Time `json:"waiting_since"`
+	LastReminder time.Time `json:"last_reminder"`
+	Reminders    int       `json:"reminders,omitempty"` // reminders sent since WaitingSince
+	SnoozedUntil time.Time `json:"snoozed_until"`
+}
+
+// Waiting reports whether the pull request is waiting for review.
+func (p *PullRequest) Waiting() bool {
+	return !p.Draft && !p.WaitingSince.IsZero()
+}
+
+// PullRequests is the persisted set of open pull requests, keyed by
+// "owner/repo#number". It is safe for concurrent use; every change is
+// written through to disk. Closing a pull request removes it.
+type PullRequests struct {
+	path  string
+	mu    sync.Mutex
+	pulls map[string]*PullRequest
+}
+
+// OpenPullRequests loads the pull requests from path (a missing file starts
+// with none).
+func OpenPullRequests(path string) (*PullRequests, error) {
+	p := &PullRequests{path: path, pulls: map[string]*PullRequest{}}
+	if err := LoadJSON(path, &p.pulls); err != nil {
+		return nil, err
+	}
+	if p.pulls == nil {
+		p.pulls = map[string]*PullRequest{}
+	}
+	return p, nil
+}
+
+// Get returns a copy of the pull request stored under key.
+func (p *PullRequests) Get(key string) (PullRequest, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pr, ok := p.pulls[key]
+	if !ok {
+		return PullRequest{}, false
+	}
+	return pr.clone(), true
+}
+
+// Update applies fn to the pull request under key, creating it (with
+// created set) if it is not tracked yet, and saves the result.
+func (p *PullRequests) Update(key string, fn func(pr *PullRequest, created bool)) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pr, ok := p.pulls[key]
+	if !ok {
+		pr = &PullRequest{}
+		p.pulls[key] = pr
+	}
+	fn(pr, !ok)
+	return SaveJSON(p.path, p.pulls)
+}
+
+// Modify applies fn to the pull request under key if it is tracked, and
+// reports whether it was.
+func (p *PullRequests) Modify(key string, fn func(pr *PullRequest)) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pr, ok := p.pulls[key]
+	if !ok {
+		return false, nil
+	}
+	fn(pr)
+	return true, SaveJSON(p.path, p.pulls)
+}
+
+// Snooze holds the reminders of the pull request under key until until, and
+// reports whether it is tracked.
+func (p *PullRequests) Snooze(key string, until time.Time) (bool, error) {
+	return p.Modify(key, func(pr *PullRequest) { pr.SnoozedUntil = until })
+}
+
+// Remove forgets the pull request under key.
+func (p *PullRequests) Remove(key string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.pulls[key]; !ok {
+		return nil
+	}
+	delete(p.pulls, key)
+	return SaveJSON(p.path, p.pulls)
+}
+
+// List returns copies of all tracked pull requests with their keys, ordered
+// by key.
+func (p *PullRequests) List() (keys []string, pulls []PullRequest) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key := range p.pulls {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		pulls = append(pulls, p.pulls[key].clone())
+	}
+	return keys, pulls
+}
+
+func (pr *PullRequest) clone() PullRequest {
+	cp := *pr
+	cp.Reviewers = append([]string(nil), pr.Reviewers...)
+	return cp
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPullRequestsPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pulls.json")
+	pulls, err := OpenPullRequests(path)
+	if err != nil {
+		t.Fatalf("OpenPullRequests() error = %v", err)
+	}
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	err = pulls.Update("org/a#1", func(pr *PullRequest, created bool) {
+		if !created {
+			t.Error("first Update should create the pull request")
+		}
+		pr.Repo, pr.Number, pr.WaitingSince = "org/a", 1, now
+		pr.Reviewers = []string{"bob"}
+	})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if ok, err := pulls.Modify("org/a#2", func(pr *PullRequest) {}); ok || err != nil {
+		t.Fatalf("Modify() of an untracked pull request = %v, %v", ok, err)
+	}
+
+	reopened, err := OpenPullRequests(path)
+	if err != nil {
+		t.Fatalf("reopen error = %v", err)
+	}
+	pr, ok := reopened.Get("org/a#1")
+	if !ok || !pr.Waiting() || len(pr.Reviewers) != 1 {
+		t.Fatalf("Get() = %+v, %v", pr, ok)
+	}
+	pr.Reviewers[0] = "changed" // copies do not alias the store
+	if keys, list := reopened.List(); len(keys) != 1 || list[0].Reviewers[0] != "bob" {
+		t.Fatalf("List() = %v, %+v", keys, list)
+	}
+	if err := reopened.Remove("org/a#1"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if _, ok := reopened.Get("org/a#1"); ok {
+		t.Fatal("pull request not removed")
+	}
+}
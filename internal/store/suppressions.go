@@ -0,0 +1,233 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Caps of the suppression log (entries) and the maintenance queue (bytes of
+// JSON, as queued deliveries carry the whole webhook payload); older entries
+// are dropped first.
+const (
+	MaxSuppressedLog = 200
+	MaxQueuedBytes   = 4 << 20
+)
+
+// Suppression silences deliveries until Until. Every non-empty field must
+// match: Repo is a repo glob ("acme/*"), Event an event type and Target a bot
+// alias (or webhook URL).
+type Suppression struct {
+	ID      string    `json:"id"`
+	Repo    string    `json:"repo,omitempty"`
+	Event   string    `json:"event,omitempty"`
+	Target  string    `json:"target,omitempty"`
+	Reason  string    `json:"reason,omitempty"`
+	Until   time.Time `json:"until"`
+	Created time.Time `json:"created"`
+}
+
+// SuppressedDelivery records a delivery dropped by a suppression.
+type SuppressedDelivery struct {
+	At          time.Time `json:"at"`
+	Event       string    `json:"event"`
+	Repo        string    `json:"repo,omitempty"`
+	Target      string    `json:"target"`
+	Suppression string    `json:"suppression"` // ID of the matching suppression
+}
+
+// Maintenance is the global maintenance mode: while it is on, deliveries are
+// queued instead of sent. A zero Until lasts until it is turned off.
+type Maintenance struct {
+	On     bool      `json:"on"`
+	Until  time.Time `json:"until"`
+	Reason string    `json:"reason,omitempty"`
+	Since  time.Time `json:"since"`
+}
+
+// Active reports whether maintenance mode is on at now.
+func (m Maintenance) Active(now time.Time) bool {
+	return m.On && (m.Until.IsZero() || now.Before(m.Until))
+}
+
+// QueuedDelivery is a delivery held back by maintenance mode, with what is
+// needed to send it later.
+type QueuedDelivery struct {
+	At      time.Time      `json:"at"`
+	Event   string         `json:"event"`
+	Payload map[string]any `json:"payload"`
+	Targets []string       `json:"targets"`
+	Extra   map[string]any `json:"extra,omitempty"`
+}
+
+type suppressionState struct {
+	Rules       []Suppression        `json:"rules"`
+	Maintenance Maintenance          `json:"maintenance"`
+	Queue       []QueuedDelivery     `json:"queue,omitempty"`
+	Log         []SuppressedDelivery `json:"log,omitempty"` // oldest first
+}
+
+// Suppressions is the persisted runtime suppression list, maintenance mode,
+// maintenance queue and log of suppressed deliveries. It is safe for
+// concurrent use; every change is written through to disk.
+type Suppressions struct {
+	path   string
+	mu     sync.Mutex
+	state  suppressionState
+	queued int // JSON size of state.Queue
+	now    func() time.Time
+}
+
+// OpenSuppressions loads the suppression state from path (a missing file
+// starts with nothing suppressed).
+func OpenSuppressions(path string) (*Suppressions, error) {
+	s := &Suppressions{path: path, now: time.Now}
+	if err := LoadJSON(path, &s.state); err != nil {
+		return nil, err
+	}
+	for _, d := range s.state.Queue {
+		s.queued += queuedSize(d)
+	}
+	return s, nil
+}
+
+// Add stores a suppression, assigning its ID and creation time, and returns
+// it. At least one of Repo, Event and Target must be set.
+func (s *Suppressions) Add(sup Suppression) (Suppression, error) {
+	if sup.Repo == "" && sup.Event == "" && sup.Target == "" {
+		return Suppression{}, fmt.Errorf("a suppression needs a repo, event or target")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	sup.Created = now
+	sup.ID = fmt.Sprintf("s%d", now.UnixNano())
+	s.state.Rules = append(s.pruned(now), sup)
+	return sup, s.save()
+}
+
+// Remove deletes the suppression with id and reports whether it existed.
+func (s *Suppressions) Remove(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.state.Rules)
+	s.state.Rules = slices.DeleteFunc(s.state.Rules, func(sup Suppression) bool { return sup.ID == id })
+	if len(s.state.Rules) == n {
+		return false, nil
+	}
+	return true, s.save()
+}
+
+// Active returns the suppressions in effect at now, in creation order.
+func (s *Suppressions) Active(now time.Time) []Suppression {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var active []Suppression
+	for _, sup := range s.state.Rules {
+		if now.Before(sup.Until) {
+			active = append(active, sup)
+		}
+	}
+	return active
+}
+
+// pruned drops the expired suppressions.
+func (s *Suppressions) pruned(now time.Time) []Suppression {
+	return slices.DeleteFunc(s.state.Rules, func(sup Suppression) bool { return !now.Before(sup.Until) })
+}
+
+// Record adds d to the log of suppressed deliveries.
+func (s *Suppressions) Record(d SuppressedDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if d.At.IsZero() {
+		d.At = s.now()
+	}
+	s.state.Log = append(s.state.Log, d)
+	if over := len(s.state.Log) - MaxSuppressedLog; over > 0 {
+		s.state.Log = slices.Delete(s.state.Log, 0, over)
+	}
+	return s.save()
+}
+
+// Log returns the suppressed deliveries, newest first.
+func (s *Suppressions) Log() []SuppressedDelivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log := slices.Clone(s.state.Log)
+	slices.Reverse(log)
+	return log
+}
+
+// Maintenance returns the maintenance mode settings.
+func (s *Suppressions) Maintenance() Maintenance {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.Maintenance
+}
+
+// SetMaintenance turns maintenance mode on (until until, or indefinitely
+// when zero) or off. Deliveries queued so far stay queued until TakeQueued.
+func (s *Suppressions) SetMaintenance(on bool, until time.Time, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := Maintenance{On: on}
+	if on {
+		m.Until, m.Reason, m.Since = until, reason, s.now()
+	}
+	s.state.Maintenance = m
+	return s.save()
+}
+
+// Queue holds d until maintenance mode ends. It reports whether a delivery
+// had to be dropped to stay within MaxQueuedBytes (the oldest, or d itself
+// when it alone is larger).
+func (s *Suppressions) Queue(d QueuedDelivery) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if d.At.IsZero() {
+		d.At = s.now()
+	}
+	s.state.Queue = append(s.state.Queue, d)
+	s.queued += queuedSize(d)
+	over := 0
+	for ; s.queued > MaxQueuedBytes && over < len(s.state.Queue); over++ {
+		s.queued -= queuedSize(s.state.Queue[over])
+	}
+	s.state.Queue = slices.Delete(s.state.Queue, 0, over)
+	return over > 0, s.save()
+}
+
+// queuedSize returns the JSON size of a queued delivery.
+func queuedSize(d QueuedDelivery) int {
+	data, _ := json.Marshal(d)
+	return len(data)
+}
+
+// Queued returns the number of queued deliveries.
+func (s *Suppressions) Queued() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.state.Queue)
+}
+
+// TakeQueued removes and returns the queued deliveries, oldest first, once
+// maintenance mode is no longer active at now; otherwise it returns nil.
+func (s *Suppressions) TakeQueued(now time.Time) ([]QueuedDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state.Maintenance.Active(now) || len(s.state.Queue) == 0 {
+		return nil, nil
+	}
+	queue := s.state.Queue
+	s.state.Queue = nil
+	s.queued = 0
+	s.state.Maintenance = Maintenance{}
+	return queue, s.save()
+}
+
+func (s *Suppressions) save() error {
+	return SaveJSON(s.path, s.state)
+}
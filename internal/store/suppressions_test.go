@@ -0,0 +1,78 @@
+package store
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSuppressions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "suppressions.json")
+	s, err := OpenSuppressions(path)
+	if err != nil {
+		t.Fatalf("OpenSuppressions() error = %v", err)
+	}
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	if _, err := s.Add(Suppression{Until: now.Add(time.Hour)}); err == nil {
+		t.Fatal("Add() accepted a suppression matching everything")
+	}
+	short, _ := s.Add(Suppression{Repo: "acme/*", Until: now.Add(time.Hour)})
+	now = now.Add(time.Second)
+	long, _ := s.Add(Suppression{Target: "ops", Until: now.Add(3 * time.Hour)})
+	if active := s.Active(now.Add(2 * time.Hour)); len(active) != 1 || active[0].ID != long.ID {
+		t.Fatalf("Active() after the first expired = %+v", active)
+	}
+	if ok, _ := s.Remove(short.ID); !ok {
+		t.Fatal("Remove() did not find the suppression")
+	}
+
+	for i := 0; i < MaxSuppressedLog+5; i++ {
+		_ = s.Record(SuppressedDelivery{Event: "push", Target: "ops", Suppression: long.ID})
+	}
+	if err := s.SetMaintenance(true, now.Add(time.Hour), "migration"); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = s.Queue(QueuedDelivery{Event: "push", Targets: []string{"ops"}})
+
+	reopened, err := OpenSuppressions(path)
+	if err != nil {
+		t.Fatalf("reopen error = %v", err)
+	}
+	if active := reopened.Active(now); len(active) != 1 || active[0].Target != "ops" {
+		t.Fatalf("Active() after reopen = %+v", active)
+	}
+	if n := len(reopened.Log()); n != MaxSuppressedLog {
+		t.Fatalf("len(Log()) = %d, want %d", n, MaxSuppressedLog)
+	}
+	if queue, _ := reopened.TakeQueued(now); queue != nil {
+		t.Fatalf("TakeQueued() during maintenance = %+v", queue)
+	}
+	queue, _ := reopened.TakeQueued(now.Add(time.Hour))
+	if len(queue) != 1 || reopened.Maintenance().On {
+		t.Fatalf("TakeQueued() after expiry = %+v, maintenance %+v", queue, reopened.Maintenance())
+	}
+}
+
+func TestSuppressionsQueueSizeCap(t *testing.T) {
+	s, err := OpenSuppressions(filepath.Join(t.TempDir(), "suppressions.json"))
+	if err != nil {
+		t.Fatalf("OpenSuppressions() error = %v", err)
+	}
+	big := map[string]any{"body": strings.Repeat("x", MaxQueuedBytes/4)}
+	for i := 0; i < 3; i++ {
+		dropped, err := s.Queue(QueuedDelivery{Event: "push", Payload: big, Targets: []string{"ops"}})
+		if err != nil || dropped {
+			t.Fatalf("Queue() #%d = %v, %v", i+1, dropped, err)
+		}
+	}
+	if dropped, _ := s.Queue(QueuedDelivery{Event: "issues", Payload: big, Targets: []string{"ops"}}); !dropped || s.Queued() != 3 {
+		t.Fatalf("Queue() over the cap dropped %v, queued %d", dropped, s.Queued())
+	}
+	huge := map[string]any{"body": strings.Repeat("x", MaxQueuedBytes)}
+	if dropped, _ := s.Queue(QueuedDelivery{Event: "push", Payload: huge}); !dropped || s.Queued() != 0 {
+		t.Fatalf("Queue() of an oversized delivery dropped %v, queued %d", dropped, s.Queued())
+	}
+}
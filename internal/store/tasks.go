@@ -0,0 +1,56 @@
+package store
+
+import "sync"
+
+// Tasks is the persisted mapping from GitHub issues to the Feishu Tasks
+// created for their assignees: "owner/repo#number" -> GitHub login -> task
+// GUID. It is safe for concurrent use; every change is written through to
+// disk. Entries are never pruned, so a closed issue that is reopened months
+// later still finds its tasks.
+type Tasks struct {
+	path  string
+	mu    sync.Mutex
+	tasks map[string]map[string]string
+}
+
+// OpenTasks loads the task index from path (a missing file starts an empty
+// index).
+func OpenTasks(path string) (*Tasks, error) {
+	t := &Tasks{path: path, tasks: map[string]map[string]string{}}
+	if err := LoadJSON(path, &t.tasks); err != nil {
+		return nil, err
+	}
+	if t.tasks == nil {
+		t.tasks = map[string]map[string]string{}
+	}
+	return t, nil
+}
+
+// Task returns the GUID of the task created for login on issue key, or "".
+func (t *Tasks) Task(key, login string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.tasks[key][login]
+}
+
+// IssueTasks returns a copy of the login -> task GUID map for issue key.
+func (t *Tasks) IssueTasks(key string) map[string]string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make(map[string]string, len(t.tasks[key]))
+	for login, guid := range t.tasks[key] {
+		out[login] = guid
+	}
+	return out
+}
+
+// SetTask records guid as the task for login on issue key.
+func (t *Tasks) SetTask(key, login, guid string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tasks[key] == nil {
+		t.tasks[key] = map[string]string{}
+	}
+	t.tasks[key][login] = guid
+	return SaveJSON(t.path, t.tasks)
+}
@@ -0,0 +1,34 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestTasksPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	tasks, err := OpenTasks(path)
+	if err != nil {
+		t.Fatalf("OpenTasks() error = %v", err)
+	}
+	if err := tasks.SetTask("org/repo#3", "alice", "guid-a"); err != nil {
+		t.Fatalf("SetTask() error = %v", err)
+	}
+	if err := tasks.SetTask("org/repo#3", "bob", "guid-b"); err != nil {
+		t.Fatalf("SetTask() error = %v", err)
+	}
+
+	reopened, err := OpenTasks(path)
+	if err != nil {
+		t.Fatalf("reopen error = %v", err)
+	}
+	if guid := reopened.Task("org/repo#3", "alice"); guid != "guid-a" {
+		t.Fatalf("Task() after reopen = %q, want guid-a", guid)
+	}
+	if got := reopened.IssueTasks("org/repo#3"); len(got) != 2 || got["bob"] != "guid-b" {
+		t.Fatalf("IssueTasks() = %v", got)
+	}
+	if got := reopened.IssueTasks("org/repo#4"); len(got) != 0 {
+		t.Fatalf("IssueTasks() for unknown issue = %v", got)
+	}
+}
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultThreadRetention is how long a thread is kept after its last update
+// when no retention is configured.
+const DefaultThreadRetention = 30 * 24 * time.Hour
+
+// Thread is what the tracker remembers about one PR or issue: its title,
+// latest status and the first card sent for it to each Feishu chat.
+type Thread struct {
+	Title     string            `json:"title,omitempty"`
+	URL       string            `json:"url,omitempty"`
+	State     string            `json:"state,omitempty"`    // open, draft, closed, merged
+	Review    string            `json:"review,omitempty"`   // approved, changes_requested, commented, dismissed
+	CI        string            `json:"ci,omitempty"`       // check_suite conclusion, or pending
+	Messages  map[string]string `json:"messages,omitempty"` // receive target (e.g. "chat_id:oc_xxx") -> message_id
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// Threads is the persisted thread index, keyed by "owner/repo#number". It is
+// safe for concurrent use; every change is written through to disk.
+type Threads struct {
+	path      string
+	mu        sync.Mutex
+	retention time.Duration
+	threads   map[string]*Thread
+	now       func() time.Time
+}
+
+// OpenThreads loads the thread index from path (a missing file starts an
+// empty index). A zero retention uses DefaultThreadRetention.
+func OpenThreads(path string, retention time.Duration) (*Threads, error) {
+	t := &Threads{path: path, threads: map[string]*Thread{}, now: time.Now}
+	t.SetRetention(retention)
+	if err := LoadJSON(path, &t.threads); err != nil {
+		return nil, err
+	}
+	if t.threads == nil {
+		t.threads = map[string]*Thread{}
+	}
+	t.prune()
+	return t, nil
+}
+
+// SetRetention changes how long idle threads are kept.
+func (t *Threads) SetRetention(retention time.Duration) {
+	if retention <= 0 {
+		retention = DefaultThreadRetention
+	}
+	t.mu.Lock()
+	t.retention = retention
+	t.mu.Unlock()
+}
+
+// Get returns a copy of the thread stored under key.
+func (t *Threads) Get(key string) (Thread, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	th, ok := t.threads[key]
+	if !ok {
+		return Thread{}, false
+	}
+	cp := *th
+	cp.Messages = make(map[string]string, len(th.Messages))
+	for k, v := range th.Messages {
+		cp.Messages[k] = v
+	}
+	return cp, true
+}
+
+// Message returns the message_id of the first card sent for key to target,
+// or "" if there is none.
+func (t *Threads) Message(key, target string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if th, ok := t.threads[key]; ok {
+		return th.Messages[target]
+	}
+	return ""
+}
+
+// SetMessage records messageID as the card for key in target.
+func (t *Threads) SetMessage(key, target, messageID string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	th := t.thread(key)
+	if th.Messages == nil {
+		th.Messages = map[string]string{}
+	}
+	th.Messages[target] = messageID
+	return t.save()
+}
+
+// UpdateStatus merges the non-empty fields of status into the thread for key
+// and returns the result.
+func (t *Threads) UpdateStatus(key string, status Thread) (Thread, error) {
+	t.mu.Lock()
+	th := t.thread(key)
+	if status.Title != "" {
+		th.Title = status.Title
+	}
+	if status.URL != "" {
+		th.URL = status.URL
+	}
+	if status.State != "" {
+		th.State = status.State
+	}
+	if status.Review != "" {
+		th.Review = status.Review
+	}
+	if status.CI != "" {
+		th.CI = status.CI
+	}
+	err := t.save()
+	t.mu.Unlock()
+	out, _ := t.Get(key)
+	return out, err
+}
+
+// thread returns the thread for key, creating it, and marks it updated.
+// Callers must hold t.mu.
+func (t *Threads) thread(key string) *Thread {
+	th, ok := t.threads[key]
+	if !ok {
+		th = &Thread{}
+		t.threads[key] = th
+	}
+	th.UpdatedAt = t.now()
+	return th
+}
+
+// prune drops threads idle for longer than the retention. Callers must hold
+// t.mu or own t exclusively.
+func (t *Threads) prune() {
+	cutoff := t.now().Add(-t.retention)
+	for key, th := range t.threads {
+		if th.UpdatedAt.Before(cutoff) {
+			delete(t.threads, key)
+		}
+	}
+}
+
+// save prunes and writes the index. Callers must hold t.mu.
+func (t *Threads) save() error {
+	t.prune()
+	return SaveJSON(t.path, t.threads)
+}
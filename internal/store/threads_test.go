@@ -0,0 +1,60 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestThreadsPersistAndMerge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "threads.json")
+	threads, err := OpenThreads(path, 0)
+	if err != nil {
+		t.Fatalf("OpenThreads() error = %v", err)
+	}
+	if err := threads.SetMessage("org/repo#1", "chat_id:oc_1", "om_1"); err != nil {
+		t.Fatalf("SetMessage() error = %v", err)
+	}
+	if _, err := threads.UpdateStatus("org/repo#1", Thread{State: "open"}); err != nil {
+		t.Fatalf("UpdateStatus() error = %v", err)
+	}
+	got, err := threads.UpdateStatus("org/repo#1", Thread{Review: "approved"})
+	if err != nil {
+		t.Fatalf("UpdateStatus() error = %v", err)
+	}
+	if got.State != "open" || got.Review != "approved" {
+		t.Fatalf("status not merged: %+v", got)
+	}
+
+	reopened, err := OpenThreads(path, 0)
+	if err != nil {
+		t.Fatalf("reopen error = %v", err)
+	}
+	if id := reopened.Message("org/repo#1", "chat_id:oc_1"); id != "om_1" {
+		t.Fatalf("Message() after reopen = %q, want om_1", id)
+	}
+	if id := reopened.Message("org/repo#1", "chat_id:oc_2"); id != "" {
+		t.Fatalf("Message() for another chat = %q, want empty", id)
+	}
+}
+
+func TestThreadsRetention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "threads.json")
+	threads, err := OpenThreads(path, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("OpenThreads() error = %v", err)
+	}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	threads.now = func() time.Time { return now }
+
+	_ = threads.SetMessage("org/repo#1", "chat_id:oc_1", "om_old")
+	now = now.Add(25 * time.Hour)
+	_ = threads.SetMessage("org/repo#2", "chat_id:oc_1", "om_new")
+
+	if _, ok := threads.Get("org/repo#1"); ok {
+		t.Fatal("expected idle thread to be pruned")
+	}
+	if id := threads.Message("org/repo#2", "chat_id:oc_1"); id != "om_new" {
+		t.Fatalf("Message() = %q, want om_new", id)
+	}
+}
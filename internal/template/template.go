@@ -9,6 +9,7 @@ import (
 
 	"github.com/hnrobert/feishu-github-tracker/internal/config"
 	"github.com/hnrobert/feishu-github-tracker/internal/logger"
+	"github.com/hnrobert/feishu-github-tracker/internal/matcher"
 )
 
 // SelectTemplate selects the appropriate template based on event type and tags.
@@ -162,6 +163,21 @@ func replacePlaceholdersInString(s string, data map[string]any) string {
 	})
 }
 
+// singlePlaceholder matches a string that is exactly one {{expr}}.
+var singlePlaceholder = regexp.MustCompile(`^\{\{\s*([^#/].*?)\s*\}\}$`)
+
+// RenderValue fills a single template string, such as a Bitable field
+// mapping. A string that is exactly one placeholder keeps the value's type
+// (e.g. a number stays a number); anything else renders to a string. ok is
+// false when a placeholder could not be resolved.
+func RenderValue(s string, data map[string]any) (any, bool) {
+	if m := singlePlaceholder.FindStringSubmatch(strings.TrimSpace(s)); m != nil && !strings.Contains(m[1], "}}") {
+		return evalExpression(m[1], data)
+	}
+	out := replacePlaceholdersInString(s, data)
+	return out, !strings.Contains(out, "{{")
+}
+
 // processIfBlocks evaluates and expands simple {{#if expr}}...{{/if}} blocks.
 // It supports non-nested blocks and will recursively process inner blocks after
 // a block is kept. If the condition is falsy, the entire block is removed.
@@ -515,6 +531,32 @@ func DetermineTags(eventType string, payload map[string]any) []string {
 			}
 		}
 
+	case "dependabot_alert", "code_scanning_alert", "secret_scanning_alert", "repository_vulnerability_alert":
+		// Security alerts: normalized severity (severity:critical, ...) and,
+		// for Dependabot, the package ecosystem (ecosystem:npm, ...)
+		if severity := matcher.AlertSeverity(eventType, payload); severity != "" {
+			tags = append(tags, "severity:"+severity)
+		}
+		if eco := matcher.AlertEcosystem(eventType, payload); eco != "" {
+			tags = append(tags, "ecosystem:"+eco)
+		}
+
+	case "commit_status":
+		// Per-commit check summary: success, failure or pending (timed out)
+		if cs, ok := payload["commit_status"].(map[string]any); ok {
+			if concl, ok := cs["conclusion"].(string); ok && concl != "" {
+				tags = append(tags, concl)
+			}
+		}
+
+	case "deployment_lifecycle":
+		// Deployment summary: success, failure, error or rejected
+		if dl, ok := payload["deployment_lifecycle"].(map[string]any); ok {
+			if state, ok := dl["state"].(string); ok && state != "" {
+				tags = append(tags, state)
+			}
+		}
+
 	case "check_suite":
 		// Similar to check_run
 		if cs, ok := payload["check_suite"].(map[string]any); ok {
@@ -32,6 +32,32 @@ func TestFillTemplate_NestedPlaceholder(t *testing.T) {
 	}
 }
 
+func TestRenderValue(t *testing.T) {
+	data := map[string]any{
+		"pr_number": float64(42),
+		"pr_title":  "Fix login",
+		"labels":    []any{"bug"},
+	}
+	cases := []struct {
+		in     string
+		want   any
+		wantOK bool
+	}{
+		{"{{pr_number}}", float64(42), true},
+		{" {{ labels | length }} ", 1, true},
+		{"#{{pr_number}} {{pr_title}}", "#42 Fix login", true},
+		{"{{pr_state | default('open')}}", "open", true},
+		{"{{pr_state}}", nil, false},
+		{"{{pr_title}} ({{pr_state}})", "Fix login ({{pr_state}})", false},
+	}
+	for _, tc := range cases {
+		got, ok := RenderValue(tc.in, data)
+		if ok != tc.wantOK || (ok && !reflect.DeepEqual(got, tc.want)) {
+			t.Errorf("RenderValue(%q) = %#v, %v; want %#v, %v", tc.in, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
+
 // issueTemplates builds a small issue template set mirroring the real
 // templates.jsonc ordering (bug-specific payloads first, then generic ones).
 func issueTemplates() config.TemplatesConfig {
@@ -113,3 +139,18 @@ func TestSelectTemplate_ClosedBugBeatsGenericClosed(t *testing.T) {
 		t.Fatalf("closed bug should use BUG-CLOSED; got %q", titleOf(got))
 	}
 }
+
+func TestDetermineTags_SecuritySeverity(t *testing.T) {
+	payload := map[string]any{
+		"action": "created",
+		"alert": map[string]any{
+			"security_advisory": map[string]any{"severity": "critical"},
+			"dependency":        map[string]any{"package": map[string]any{"ecosystem": "npm"}},
+		},
+	}
+	got := DetermineTags("dependabot_alert", payload)
+	want := []string{"dependabot_alert", "created", "severity:critical", "ecosystem:npm"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("DetermineTags() = %v, want %v", got, want)
+	}
+}